@@ -4,15 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dfkdream/gallery-plugin/config"
 	"github.com/dfkdream/gallery-plugin/database"
 
 	"github.com/dfkdream/hugocms/plugin"
 	"github.com/gorilla/mux"
+	"github.com/nfnt/resize"
 )
 
 func atou(s string) (uint64, error) {
@@ -24,11 +27,21 @@ func atou(s string) (uint64, error) {
 }
 
 type API struct {
-	db *database.Database
+	db            *database.Database
+	legacy        bool
+	heartbeat     time.Duration
+	interpolation resize.InterpolationFunction
+	quality       int
 }
 
-func New(db *database.Database) *API {
-	return &API{db: db}
+func New(db *database.Database, cfg *config.Config) *API {
+	return &API{
+		db:            db,
+		legacy:        cfg.LegacyResponses,
+		heartbeat:     defaultHeartbeat,
+		interpolation: cfg.Interpolation,
+		quality:       cfg.Quality,
+	}
 }
 
 // GET: get galleries
@@ -36,18 +49,23 @@ func New(db *database.Database) *API {
 func (a *API) galleriesHandler(res http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case "GET":
-		r, err := a.db.GetGalleries()
+		page, paged, err := parsePage(req)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
-		err = json.NewEncoder(res).Encode(r)
+
+		// unlisted galleries are listed to admins, and to users with role on them
+		if u := plugin.GetUser(req); u != nil {
+			page.Unlisted, page.UserId = u.HasPermission(adminPermission), u.Id
+		}
+
+		r, next, err := a.db.GetGalleriesPage(page)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
+		writePage(res, r, next, paged)
 	case "POST":
 		var values struct {
 			Title string `json:"title"`
@@ -55,197 +73,309 @@ func (a *API) galleriesHandler(res http.ResponseWriter, req *http.Request) {
 
 		err := json.NewDecoder(req.Body).Decode(&values)
 		if err != nil {
-			http.Error(res, "Bad Request", http.StatusBadRequest)
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+
+		gid, err := a.db.CreateGallery(values.Title, plugin.GetUser(req).Id)
+		if err != nil {
+			writeError(res, err)
+			return
 		}
 
-		gid, err := a.db.CreateGallery(values.Title)
+		g, err := a.db.GetGallery(gid)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
 
-		_, _ = res.Write([]byte(string(gid)))
+		a.audit(req, database.AuditEntry{Action: actionGalleryCreate, GalleryId: gid, After: auditJSON(g)})
+		a.created(res, path.Join(req.URL.Path, strconv.FormatUint(gid, 10)), gid, g)
 	default:
-		http.Error(res, "Method Not Allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(res, "GET", "POST")
 	}
 }
 
 // GET: get gallery
 // POST: set gallery title
+// PATCH: update given fields of gallery
+// PUT: replace every editable field of gallery
 // DELETE: delete gallery
 func (a *API) galleryHandler(res http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	gid, err := atou(vars["gid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 
 	g, err := a.db.GetGallery(gid)
 	if err != nil {
-		if err == database.ErrGalleryNotFound {
-			http.Error(res, "Not Found", http.StatusNotFound)
-			return
-		} else {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+		writeError(res, err)
+		return
 	}
 
 	switch req.Method {
 	case "GET":
-		err := json.NewEncoder(res).Encode(g)
+		res.Header().Set("ETag", etag(g))
+		writeJSON(res, http.StatusOK, g)
+	case "PATCH", "PUT":
+		if !authorize(res, req, database.RoleEditor) {
+			return
+		}
+
+		var patch database.GalleryPatch
+		err := json.NewDecoder(req.Body).Decode(&patch)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+		patch.Replace = req.Method == "PUT"
+
+		var before database.Gallery
+		g, err := a.db.UpdateGallery(gid, patch, func(current database.Gallery) error {
+			before = current
+			return ifMatch(req, current)
+		})
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
+
+		a.audit(req, database.AuditEntry{Action: actionGalleryUpdate, GalleryId: gid, Before: auditJSON(before), After: auditJSON(g)})
+
+		res.Header().Set("ETag", etag(g))
+		a.respond(res, gid, g)
 	case "POST":
+		if !authorize(res, req, database.RoleEditor) {
+			return
+		}
+
 		var values struct {
 			Title string `json:"title"`
 		}
 
 		err := json.NewDecoder(req.Body).Decode(&values)
 		if err != nil {
-			http.Error(res, "Bad Request", http.StatusBadRequest)
+			writeError(res, ErrInvalidJSON)
 			return
 		}
 
 		err = a.db.SetGalleryTitle(gid, values.Title)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
 
-		_, _ = res.Write([]byte(string(gid)))
+		before := g
+		g.Title = values.Title
+		a.audit(req, database.AuditEntry{Action: actionGalleryUpdate, GalleryId: gid, Before: auditJSON(before), After: auditJSON(g)})
+
+		a.respond(res, gid, g)
 	case "DELETE":
+		if !authorize(res, req, database.RoleOwner) {
+			return
+		}
+
 		err := a.db.DeleteGallery(gid)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
-		_, _ = res.Write([]byte(string(gid)))
+
+		a.audit(req, database.AuditEntry{Action: actionGalleryDelete, GalleryId: gid, Before: auditJSON(g)})
+		a.respond(res, gid, g)
 	default:
-		http.Error(res, "Method Not Allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(res, "GET", "POST", "PUT", "PATCH", "DELETE")
 	}
 }
 
 // GET: get albums
-// POST: create album
+// POST: create album, or smart album if query is given
 func (a *API) albumsHandler(res http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	gid, err := atou(vars["gid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 
 	switch req.Method {
 	case "GET":
-		a, err := a.db.GetAlbums(gid)
+		page, paged, err := parsePage(req)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
 
-		err = json.NewEncoder(res).Encode(a)
+		page.Unlisted = getGrant(req).Role.Includes(database.RoleViewer)
+
+		a, next, err := a.db.GetAlbumsPage(gid, page)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
+
+		writePage(res, a, next, paged)
 	case "POST":
+		if !authorize(res, req, database.RoleEditor) {
+			return
+		}
+
 		var values struct {
-			Title string `json:"title"`
+			Title string               `json:"title"`
+			Query *database.SmartQuery `json:"query"`
 		}
 
 		err := json.NewDecoder(req.Body).Decode(&values)
 		if err != nil {
-			http.Error(res, "Bad Request", http.StatusBadRequest)
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+		if !a.authorizeQuery(res, req, gid, values.Query) {
+			return
+		}
+
+		var aid uint64
+		if values.Query != nil {
+			aid, err = a.db.CreateSmartAlbum(gid, values.Title, plugin.GetUser(req).Id, *values.Query)
+		} else {
+			aid, err = a.db.CreateAlbum(gid, values.Title, plugin.GetUser(req).Id)
+		}
+		if err != nil {
+			writeError(res, err)
 			return
 		}
 
-		aid, err := a.db.CreateAlbum(gid, values.Title)
+		album, err := a.db.GetAlbum(gid, aid)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
 
-		_, _ = res.Write([]byte(string(aid)))
+		a.audit(req, database.AuditEntry{Action: actionAlbumCreate, GalleryId: gid, AlbumId: aid, After: auditJSON(album)})
+		a.created(res, path.Join(path.Dir(req.URL.Path), "album", strconv.FormatUint(aid, 10)), aid, album)
 	default:
-		http.Error(res, "Method Not Allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(res, "GET", "POST")
 	}
 }
 
 // GET: get album
-// POST: set album title
+// POST: set album title, and query of smart album
+// PATCH: update given fields of album
+// PUT: replace every editable field of album
 // DELETE: delete album
 func (a *API) albumHandler(res http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	gid, err := atou(vars["gid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 	aid, err := atou(vars["aid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 
 	album, err := a.db.GetAlbum(gid, aid)
 	if err != nil {
-		if err == database.ErrGalleryNotFound || err == database.ErrAlbumNotFound {
-			http.Error(res, "Not Found", http.StatusNotFound)
-			return
-		} else {
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+		writeError(res, err)
+		return
 	}
 
 	switch req.Method {
 	case "GET":
-		err := json.NewEncoder(res).Encode(album)
+		res.Header().Set("ETag", etag(album))
+		writeJSON(res, http.StatusOK, album)
+	case "PATCH", "PUT":
+		if !authorize(res, req, database.RoleEditor) {
+			return
+		}
+
+		var patch database.AlbumPatch
+		err := json.NewDecoder(req.Body).Decode(&patch)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+		if !a.authorizeQuery(res, req, gid, patch.Query) {
+			return
+		}
+		patch.Replace = req.Method == "PUT"
+
+		var before database.Album
+		album, err := a.db.UpdateAlbum(gid, aid, patch, func(current database.Album) error {
+			before = current
+			return ifMatch(req, current)
+		})
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
+
+		a.audit(req, database.AuditEntry{Action: actionAlbumUpdate, GalleryId: gid, AlbumId: aid, Before: auditJSON(before), After: auditJSON(album)})
+
+		res.Header().Set("ETag", etag(album))
+		a.respond(res, aid, album)
 	case "POST":
+		if !authorize(res, req, database.RoleEditor) {
+			return
+		}
+
 		var values struct {
-			Title string `json:"title"`
+			Title string               `json:"title"`
+			Query *database.SmartQuery `json:"query"`
 		}
 
 		err := json.NewDecoder(req.Body).Decode(&values)
 		if err != nil {
-			http.Error(res, "Bad Request", http.StatusBadRequest)
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+
+		if !a.authorizeQuery(res, req, gid, values.Query) {
 			return
 		}
 
+		if values.Query != nil {
+			err = a.db.SetSmartQuery(gid, aid, *values.Query)
+			if err != nil {
+				writeError(res, err)
+				return
+			}
+		}
+
 		err = a.db.SetAlbumTitle(gid, aid, values.Title)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
+			return
+		}
+
+		before := album
+		album, err = a.db.GetAlbum(gid, aid)
+		if err != nil {
+			writeError(res, err)
 			return
 		}
-		_, _ = res.Write([]byte(string(gid)))
+
+		a.audit(req, database.AuditEntry{Action: actionAlbumUpdate, GalleryId: gid, AlbumId: aid, Before: auditJSON(before), After: auditJSON(album)})
+		a.respond(res, aid, album)
 	case "DELETE":
+		if !authorize(res, req, database.RoleEditor) {
+			return
+		}
+
 		err := a.db.DeleteAlbum(gid, aid)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
-		_, _ = res.Write([]byte(string(aid)))
+
+		a.audit(req, database.AuditEntry{Action: actionAlbumDelete, GalleryId: gid, AlbumId: aid, Before: auditJSON(album)})
+		a.respond(res, aid, album)
 	default:
-		http.Error(res, "Method Not Allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(res, "GET", "POST", "PUT", "PATCH", "DELETE")
 	}
 }
 
@@ -255,142 +385,267 @@ func (a *API) imagesHandler(res http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	gid, err := atou(vars["gid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 	aid, err := atou(vars["aid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 
-	i, err := a.db.GetImages(gid, aid)
-	if err != nil {
-		if err == database.ErrGalleryNotFound || err == database.ErrAlbumNotFound {
-			http.Error(res, "Not Found", http.StatusNotFound)
-			return
-		} else {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+	switch req.Method {
+	case "GET":
+		page, paged, err := parsePage(req)
+		if err != nil {
+			writeError(res, err)
 			return
 		}
-	}
 
-	switch req.Method {
-	case "GET":
-		err := json.NewEncoder(res).Encode(i)
+		page.Unlisted = getGrant(req).Role.Includes(database.RoleViewer)
+
+		i, next, err := a.db.GetImagesPage(gid, aid, page)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
+
+		writePage(res, i, next, paged)
 	case "POST":
-		iid, err := a.db.AddImage(gid, aid, req.Body)
+		if !getGrant(req).CanContributeTo(aid) {
+			writeError(res, ErrForbidden)
+			return
+		}
+
+		iid, err := a.db.AddImage(gid, aid, plugin.GetUser(req).Id, req.Body)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		i, err := a.db.GetImageInfo(gid, aid, iid)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, err.Error(), http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
-		_, _ = res.Write([]byte(string(iid)))
+
+		a.audit(req, database.AuditEntry{Action: actionImageCreate, GalleryId: gid, AlbumId: aid, ImageId: iid, After: auditJSON(i)})
+
+		dup, err := a.db.GetDuplicates(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		if len(dup) > 0 {
+			res.Header().Set("Warning", fmt.Sprintf(`299 - "%d near-duplicate images in gallery"`, len(dup)))
+		}
+		u := uploadedImage{Image: i, Duplicates: dup}
+
+		location := path.Join(path.Dir(req.URL.Path), "image", strconv.FormatUint(iid, 10))
+		if i.Status == database.ImagePending && !a.legacy {
+			// image is processed by background job
+			res.Header().Set("Location", location)
+			writeJSON(res, http.StatusAccepted, u)
+			return
+		}
+		a.created(res, location, iid, u)
 	default:
-		http.Error(res, "Method Not Allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(res, "GET", "POST")
 	}
 }
 
-// GET: get image
+// GET: get image, or metadata of image if JSON is accepted
 // POST: set image description
+// PATCH: update given fields of image
+// PUT: replace every editable field of image
 // DELETE: delete image
 func (a *API) imageHandler(res http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	gid, err := atou(vars["gid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 	aid, err := atou(vars["aid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 	iid, err := atou(vars["iid"])
 	if err != nil {
-		http.Error(res, "Bad Request", http.StatusBadRequest)
+		writeError(res, ErrInvalidId)
 		return
 	}
 
 	var img []byte = nil
 	var timestamp time.Time
 
-	if req.URL.Query().Get("thumb") != "" {
+	// renditions without watermark are only served to admins
+	thumb, unwatermarked := req.URL.Query().Get("thumb") != "", req.URL.Query().Get("unwatermarked") != ""
+	if unwatermarked && !requireAdmin(res, req) {
+		return
+	}
+
+	switch {
+	case thumb && unwatermarked:
+		img, timestamp, err = a.db.GetUnwatermarkedThumbnail(gid, aid, iid)
+	case thumb:
 		img, timestamp, err = a.db.GetThumbnail(gid, aid, iid)
-	} else {
+	case unwatermarked:
+		img, timestamp, err = a.db.GetUnwatermarkedImage(gid, aid, iid)
+	default:
 		img, timestamp, err = a.db.GetImage(gid, aid, iid)
 	}
 
-	if err != nil {
-		if err == database.ErrAlbumNotFound || err == database.ErrGalleryNotFound || err == database.ErrImageNotFound {
-			http.Error(res, "Not Found", http.StatusNotFound)
-			return
-		} else {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
+	// metadata of image being processed can be read and modified
+	if err != nil && err != database.ErrImageNotReady {
+		writeError(res, err)
+		return
 	}
 
 	switch req.Method {
 	case "GET":
+		if strings.Contains(req.Header.Get("Accept"), "application/json") {
+			i, err := a.db.GetImageInfo(gid, aid, iid)
+			if err != nil {
+				writeError(res, err)
+				return
+			}
+			res.Header().Set("ETag", etag(i))
+			writeJSON(res, http.StatusOK, i)
+			return
+		}
+
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
 		filename := fmt.Sprintf("%d_%d_%d.jpg", gid, aid, iid)
 		res.Header().Set("Content-Type", "image/jpeg")
 		http.ServeContent(res, req, filename, timestamp, bytes.NewReader(img))
 	case "POST":
+		if !a.canModifyImage(res, req, gid, aid, iid) {
+			return
+		}
+
 		var values struct {
 			Description string `json:"description"`
 		}
 
 		err := json.NewDecoder(req.Body).Decode(&values)
 		if err != nil {
-			http.Error(res, "Bad Request", http.StatusBadRequest)
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+
+		before, err := a.db.GetImageInfo(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
 			return
 		}
 
 		err = a.db.SetImageDescription(gid, aid, iid, values.Description)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
+			return
+		}
+
+		i := before
+		i.Description = values.Description
+		a.audit(req, database.AuditEntry{Action: actionImageUpdate, GalleryId: gid, AlbumId: aid, ImageId: iid, Before: auditJSON(before), After: auditJSON(i)})
+
+		a.respond(res, iid, i)
+	case "PATCH", "PUT":
+		if !a.canModifyImage(res, req, gid, aid, iid) {
 			return
 		}
-		_, _ = res.Write([]byte(string(iid)))
+
+		var patch database.ImagePatch
+		err := json.NewDecoder(req.Body).Decode(&patch)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+		patch.Replace = req.Method == "PUT"
+
+		var before database.Image
+		i, err := a.db.UpdateImage(gid, aid, iid, patch, func(current database.Image) error {
+			before = current
+			return ifMatch(req, current)
+		})
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		a.audit(req, database.AuditEntry{Action: actionImageUpdate, GalleryId: gid, AlbumId: aid, ImageId: iid, Before: auditJSON(before), After: auditJSON(i)})
+
+		res.Header().Set("ETag", etag(i))
+		a.respond(res, iid, i)
 	case "DELETE":
-		err := a.db.DeleteImage(gid, aid, iid)
+		if !a.canModifyImage(res, req, gid, aid, iid) {
+			return
+		}
+
+		before, err := a.db.GetImageInfo(gid, aid, iid)
 		if err != nil {
-			log.Println(err)
-			http.Error(res, "Internal Server Error", http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
-		_, _ = res.Write([]byte(string(iid)))
+
+		err = a.db.DeleteImage(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		a.audit(req, database.AuditEntry{Action: actionImageDelete, GalleryId: gid, AlbumId: aid, ImageId: iid, Before: auditJSON(before)})
+		a.respond(res, iid, before)
 	default:
-		http.Error(res, "Method Not Allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(res, "GET", "POST", "PUT", "PATCH", "DELETE")
 	}
 }
 
 func (a *API) SetupHandlers(r *mux.Router) {
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
-			if req.Method != "GET" {
-				if plugin.GetUser(req) == nil {
-					http.Error(res, "Forbidden", http.StatusForbidden)
-					return
-				}
-			}
-			next.ServeHTTP(res, req)
-		})
-	})
+	r.Use(a.authMiddleware)
 
 	r.HandleFunc("/", a.galleriesHandler)
+	r.HandleFunc("/openapi.json", a.openAPIHandler)
+	r.HandleFunc("/audit", a.auditHandler)
+	r.HandleFunc("/search", a.searchHandler)
+	r.HandleFunc("/events", a.eventsHandler)
+	r.HandleFunc("/oembed", a.oEmbedHandler)
+	r.HandleFunc("/webhooks", a.webhooksHandler)
+	r.HandleFunc("/webhook/{wid}", a.webhookHandler)
+	r.HandleFunc("/webhook/{wid}/deliveries", a.deliveriesHandler)
+	r.HandleFunc("/jobs", a.jobsHandler)
+	r.HandleFunc("/job/{jid}", a.jobHandler)
+	r.HandleFunc("/job/{jid}/retry", a.jobRetryHandler)
+	r.HandleFunc("/duplicates", a.duplicatesHandler)
 	r.HandleFunc("/{gid}", a.galleryHandler)
+	r.HandleFunc("/{gid}/batch", a.batchHandler)
+	r.HandleFunc("/{gid}/grants", a.grantsHandler)
+	r.HandleFunc("/{gid}/grant/{uid}", a.grantHandler)
+	r.HandleFunc("/{gid}/watermark", a.watermarkHandler)
+	r.HandleFunc("/{gid}/tags", a.tagsHandler)
+	r.HandleFunc("/{gid}/tag/{tag}", a.tagHandler)
+	r.HandleFunc("/{gid}/feed.atom", a.feedHandler)
+	r.HandleFunc("/{gid}/feed.rss", a.feedHandler)
 	r.HandleFunc("/{gid}/albums", a.albumsHandler)
 	r.HandleFunc("/{gid}/album/{aid}", a.albumHandler)
+	r.HandleFunc("/{gid}/album/{aid}/feed.atom", a.feedHandler)
+	r.HandleFunc("/{gid}/album/{aid}/feed.rss", a.feedHandler)
+	r.HandleFunc("/{gid}/album/{aid}/manifest.json", a.manifestHandler)
+	r.HandleFunc("/{gid}/album/{aid}/embed", a.embedHandler)
 	r.HandleFunc("/{gid}/album/{aid}/images", a.imagesHandler)
 	r.HandleFunc("/{gid}/album/{aid}/image/{iid}", a.imageHandler)
+	r.HandleFunc("/{gid}/album/{aid}/image/{iid}/tags", a.imageTagsHandler)
+	r.HandleFunc("/{gid}/album/{aid}/image/{iid}/tag/{tag}", a.imageTagHandler)
+	r.HandleFunc("/{gid}/album/{aid}/image/{iid}/edits", a.imageEditsHandler)
+	r.HandleFunc("/{gid}/album/{aid}/image/{iid}/tiles.dzi", a.deepZoomHandler)
+	r.HandleFunc("/{gid}/album/{aid}/image/{iid}/tiles_files/{level}/{col}_{row}.jpg", a.tileHandler)
+	r.HandleFunc("/{gid}/album/{aid}/image/{iid}/iiif", a.iiifHandler)
+	r.HandleFunc("/{gid}/album/{aid}/image/{iid}/iiif/info.json", a.iiifInfoHandler)
+	r.HandleFunc("/{gid}/album/{aid}/image/{iid}/iiif/{region}/{size}/{rotation}/{quality}.{format}", a.iiifImageHandler)
 }
@@ -34,6 +34,25 @@ func createTestImage() *bytes.Buffer {
 	return &b
 }
 
+// processedTestImage returns i with size, placeholder and files of image created by createTestImage, once processed
+func processedTestImage(i database.Image) database.Image {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	iid, err := db.AddImage(gid, aid, "test-user", createTestImage())
+	if err != nil {
+		panic(err)
+	}
+	p, err := db.GetImageInfo(gid, aid, iid)
+	if err != nil {
+		panic(err)
+	}
+
+	i.Width, i.Height, i.Files = p.Width, p.Height, p.Files
+	i.BlurHash, i.LQIP, i.Color = p.BlurHash, p.LQIP, p.Color
+	return i
+}
+
 func mustMarshalJSON(msg interface{}) []byte {
 	res, err := json.Marshal(msg)
 	if err != nil {
@@ -71,7 +90,7 @@ func createTestDB() *database.Database {
 }
 
 func createTestAPI() *API {
-	return New(createTestDB())
+	return New(createTestDB(), &config.Config{})
 }
 
 func TestAPI_SetupHandlers(t *testing.T) {
@@ -84,16 +103,16 @@ func TestAPI_SetupHandlers(t *testing.T) {
 		{
 			{
 				req:  newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))),
-				code: 200,
+				code: 201,
 				resp: nil,
 			}, {
 				req:  newAuthenticatedRequest("GET", "/", nil),
 				code: 200,
-				resp: mustMarshalJSON([]database.Gallery{{Id: 1, Title: "hello"}}),
+				resp: mustMarshalJSON([]database.Gallery{{Id: 1, Title: "hello", Owner: "hello"}}),
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1", nil),
 				code: 200,
-				resp: mustMarshalJSON(database.Gallery{Id: 1, Title: "hello"}),
+				resp: mustMarshalJSON(database.Gallery{Id: 1, Title: "hello", Owner: "hello"}),
 			}, {
 				req:  newAuthenticatedRequest("POST", "/1", bytes.NewReader([]byte(`{"title":"world"}`))),
 				code: 200,
@@ -101,7 +120,7 @@ func TestAPI_SetupHandlers(t *testing.T) {
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1", nil),
 				code: 200,
-				resp: mustMarshalJSON(database.Gallery{Id: 1, Title: "world"}),
+				resp: mustMarshalJSON(database.Gallery{Id: 1, Title: "world", Owner: "hello"}),
 			}, {
 				req:  newAuthenticatedRequest("DELETE", "/1", nil),
 				code: 200,
@@ -118,7 +137,7 @@ func TestAPI_SetupHandlers(t *testing.T) {
 		}, {
 			{
 				req:  newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))),
-				code: 200,
+				code: 201,
 				resp: nil,
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1/albums", nil),
@@ -126,16 +145,16 @@ func TestAPI_SetupHandlers(t *testing.T) {
 				resp: mustMarshalJSON([]database.Album{}),
 			}, {
 				req:  newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"hello"}`))),
-				code: 200,
+				code: 201,
 				resp: nil,
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1/albums", nil),
 				code: 200,
-				resp: mustMarshalJSON([]database.Album{{Id: 1, Title: "hello", Cover: 0}}),
+				resp: mustMarshalJSON([]database.Album{{Id: 1, Title: "hello", Cover: 0, Owner: "hello"}}),
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1/album/1", nil),
 				code: 200,
-				resp: mustMarshalJSON(database.Album{Id: 1, Title: "hello", Cover: 0}),
+				resp: mustMarshalJSON(database.Album{Id: 1, Title: "hello", Cover: 0, Owner: "hello"}),
 			}, {
 				req:  newAuthenticatedRequest("POST", "/1/album/1", bytes.NewReader([]byte(`{"title":"world"}`))),
 				code: 200,
@@ -143,7 +162,7 @@ func TestAPI_SetupHandlers(t *testing.T) {
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1/album/1", nil),
 				code: 200,
-				resp: mustMarshalJSON(database.Album{Id: 1, Title: "world", Cover: 0}),
+				resp: mustMarshalJSON(database.Album{Id: 1, Title: "world", Cover: 0, Owner: "hello"}),
 			}, {
 				req:  newAuthenticatedRequest("DELETE", "/1/album/1", nil),
 				code: 200,
@@ -160,11 +179,11 @@ func TestAPI_SetupHandlers(t *testing.T) {
 		}, {
 			{
 				req:  newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))),
-				code: 200,
+				code: 201,
 				resp: nil,
 			}, {
 				req:  newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"hello"}`))),
-				code: 200,
+				code: 201,
 				resp: nil,
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1/album/1/images", nil),
@@ -172,12 +191,12 @@ func TestAPI_SetupHandlers(t *testing.T) {
 				resp: mustMarshalJSON([]database.Image{}),
 			}, {
 				req:  newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()),
-				code: 200,
+				code: 201,
 				resp: nil,
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1/album/1/images", nil),
 				code: 200,
-				resp: mustMarshalJSON([]database.Image{{Id: 1, Description: ""}}),
+				resp: mustMarshalJSON([]database.Image{processedTestImage(database.Image{Id: 1, Description: "", Owner: "hello"})}),
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1/album/1/image/1", nil),
 				code: 200,
@@ -211,7 +230,7 @@ func TestAPI_SetupHandlers(t *testing.T) {
 			}, {
 				req:  newAuthenticatedRequest("GET", "/1/album/1/images", nil),
 				code: 200,
-				resp: mustMarshalJSON([]database.Image{{Id: 1, Description: "world"}}),
+				resp: mustMarshalJSON([]database.Image{processedTestImage(database.Image{Id: 1, Description: "world", Owner: "hello"})}),
 			}, {
 				req:  newAuthenticatedRequest("DELETE", "/1/album/1/image/1", nil),
 				code: 200,
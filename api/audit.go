@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/dfkdream/hugocms/plugin"
+)
+
+const (
+	actionGalleryCreate   = "gallery.create"
+	actionGalleryUpdate   = "gallery.update"
+	actionGalleryDelete   = "gallery.delete"
+	actionAlbumCreate     = "album.create"
+	actionAlbumUpdate     = "album.update"
+	actionAlbumDelete     = "album.delete"
+	actionImageCreate     = "image.create"
+	actionImageUpdate     = "image.update"
+	actionImageDelete     = "image.delete"
+	actionImageTag        = "image.tag"
+	actionImageUntag      = "image.untag"
+	actionImageMove       = "image.move"
+	actionImageEdit       = "image.edit"
+	actionImageRevert     = "image.revert"
+	actionGrantSet        = "grant.set"
+	actionGrantDelete     = "grant.delete"
+	actionWatermarkSet    = "watermark.set"
+	actionWatermarkDelete = "watermark.delete"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// audit records mutation performed by requesting user.
+// Failure is logged and does not fail the request, since mutation already succeeded.
+func (a *API) audit(req *http.Request, entry database.AuditEntry) {
+	if u := plugin.GetUser(req); u != nil {
+		entry.Actor = u.Id
+	}
+
+	_, err := a.db.AppendAudit(entry)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// auditJSON returns JSON of v to record as before or after state
+func auditJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return string(b)
+}
+
+// auditTags returns JSON of tags to record as before or after state, empty tags being []
+func auditTags(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	return auditJSON(tags)
+}
+
+// GET: get audit log
+// Admins can read every entry. Gallery owners can read entries of their gallery.
+func (a *API) auditHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	u := plugin.GetUser(req)
+	if u == nil {
+		writeError(res, ErrLoginRequired)
+		return
+	}
+
+	q := req.URL.Query()
+	filter := database.AuditFilter{
+		Actor:  q.Get("actor"),
+		Action: q.Get("action"),
+		Limit:  defaultAuditLimit,
+	}
+
+	var err error
+	for _, p := range []struct {
+		key   string
+		value *uint64
+	}{
+		{"gallery", &filter.GalleryId},
+		{"album", &filter.AlbumId},
+		{"image", &filter.ImageId},
+		{"before", &filter.Before},
+	} {
+		if v := q.Get(p.key); v != "" {
+			*p.value, err = atou(v)
+			if err != nil {
+				writeError(res, ErrInvalidId)
+				return
+			}
+		}
+	}
+
+	for _, p := range []struct {
+		key   string
+		value *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		if v := q.Get(p.key); v != "" {
+			*p.value, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(res, ErrInvalidParameter)
+				return
+			}
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		l, err := atou(v)
+		if err != nil || l == 0 || l > maxAuditLimit {
+			writeError(res, ErrInvalidParameter)
+			return
+		}
+		filter.Limit = int(l)
+	}
+
+	if !u.HasPermission(adminPermission) {
+		if filter.GalleryId == 0 {
+			writeError(res, ErrForbidden)
+			return
+		}
+
+		g, err := a.grantOf(req, filter.GalleryId)
+		if err != nil && err != database.ErrGalleryNotFound {
+			writeError(res, err)
+			return
+		}
+		if err == database.ErrGalleryNotFound || !g.Role.Includes(database.RoleOwner) {
+			writeError(res, ErrForbidden)
+			return
+		}
+	}
+
+	entries, err := a.db.GetAuditLog(filter)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	var result struct {
+		Entries []database.AuditEntry `json:"entries"`
+		Next    uint64                `json:"next,omitempty"`
+	}
+	result.Entries = entries
+	if len(entries) == filter.Limit {
+		result.Next = entries[len(entries)-1].Id
+	}
+
+	writeJSON(res, http.StatusOK, result)
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/dfkdream/hugocms/plugin"
+	"github.com/dfkdream/hugocms/user"
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Audit(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for _, req := range []struct {
+		method, target, body string
+		code                 int
+	}{
+		{"POST", "/", `{"title":"hello"}`, 201},
+		{"POST", "/1", `{"title":"world"}`, 200},
+		{"POST", "/1/albums", `{"title":"album"}`, 201},
+		{"DELETE", "/1/album/1", ``, 200},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, newUserRequest("owner", req.method, req.target, bytes.NewReader([]byte(req.body))))
+		if res.Code != req.code {
+			t.Error(req.method, req.target, "code not matches:", res.Code, "!=", req.code)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newUserRequest("stranger", "GET", "/audit?gallery=1", nil))
+	if res.Code != 403 {
+		t.Error("code not matches:", res.Code, "!=", 403)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newUserRequest("owner", "GET", "/audit", nil))
+	if res.Code != 403 {
+		t.Error("code not matches:", res.Code, "!=", 403)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newUserRequest("owner", "GET", "/audit?gallery=1&limit=3", nil))
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+
+	var page struct {
+		Entries []database.AuditEntry `json:"entries"`
+		Next    uint64                `json:"next"`
+	}
+	err := json.NewDecoder(res.Body).Decode(&page)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(page.Entries) != 3 || page.Next != 2 {
+		t.Errorf("Assertion Failed: %+v", page)
+	}
+	if e := page.Entries[1]; e.Action != actionAlbumCreate || e.Actor != "owner" || e.After != `{"id":1,"title":"album","cover":0,"owner":"owner"}` {
+		t.Errorf("Assertion Failed: %+v", e)
+	}
+	if e := page.Entries[2]; e.Action != actionGalleryUpdate || e.Before != `{"id":1,"title":"hello","owner":"owner"}` || e.After != `{"id":1,"title":"world","owner":"owner"}` {
+		t.Errorf("Assertion Failed: %+v", e)
+	}
+
+	req := httptest.NewRequest("GET", "/audit?action=album.delete", nil)
+	req = req.WithContext(context.WithValue(req.Context(), plugin.ContextKeyUser, &user.User{Id: "admin", Permissions: []string{adminPermission}}))
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+	err = json.NewDecoder(res.Body).Decode(&page)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(page.Entries) != 1 || page.Entries[0].Before != `{"id":1,"title":"album","cover":0,"owner":"owner"}` {
+		t.Errorf("Assertion Failed: %+v", page)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	batchStatusOK         = "ok"
+	batchStatusFailed     = "failed"
+	batchStatusRolledBack = "rolled_back"
+	batchStatusSkipped    = "skipped"
+)
+
+type batchResult struct {
+	Index  int             `json:"index"`
+	Status string          `json:"status"`
+	Image  *database.Image `json:"image,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// batchFailure is error response of failed batch
+type batchFailure struct {
+	*Error
+	Index   int           `json:"index"`
+	Results []batchResult `json:"results"`
+}
+
+// checkBatchOperation allows editors, and contributors to modify their own uploads
+// in albums they can contribute to.
+func checkBatchOperation(g database.Grant) func(database.BatchOperation, database.Image) error {
+	return func(op database.BatchOperation, i database.Image) error {
+		if g.Role.Includes(database.RoleEditor) {
+			return nil
+		}
+		if !g.CanContributeTo(op.AlbumId) || i.Owner != g.UserId {
+			return ErrForbidden
+		}
+		if op.Op == database.BatchMove && !g.CanContributeTo(op.ToAlbumId) {
+			return ErrForbidden
+		}
+		return nil
+	}
+}
+
+// POST: apply operations on images of gallery, all or nothing
+func (a *API) batchHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "POST" {
+		methodNotAllowed(res, "POST")
+		return
+	}
+
+	var values struct {
+		Operations []database.BatchOperation `json:"operations"`
+	}
+
+	err = json.NewDecoder(req.Body).Decode(&values)
+	if err != nil {
+		writeError(res, ErrInvalidJSON)
+		return
+	}
+
+	r, err := a.db.Batch(gid, values.Operations, checkBatchOperation(getGrant(req)))
+	if e, ok := err.(*database.BatchError); ok {
+		f := batchFailure{Error: toError(e.Err), Index: e.Index, Results: make([]batchResult, len(values.Operations))}
+		for idx := range f.Results {
+			f.Results[idx] = batchResult{Index: idx, Status: batchStatusSkipped}
+			if idx < e.Index {
+				f.Results[idx].Status = batchStatusRolledBack
+			}
+		}
+		f.Results[e.Index] = batchResult{Index: e.Index, Status: batchStatusFailed, Error: f.Error}
+
+		writeJSON(res, f.Status, f)
+		return
+	}
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	result := make([]batchResult, len(r))
+	for idx, op := range values.Operations {
+		result[idx] = batchResult{Index: idx, Status: batchStatusOK, Image: r[idx].Image}
+		a.auditBatchOperation(req, gid, op, r[idx])
+	}
+
+	writeJSON(res, http.StatusOK, struct {
+		Results []batchResult `json:"results"`
+	}{result})
+}
+
+func (a *API) auditBatchOperation(req *http.Request, gid uint64, op database.BatchOperation, r database.BatchResult) {
+	entry := database.AuditEntry{GalleryId: gid, AlbumId: op.AlbumId, ImageId: op.ImageId}
+
+	switch op.Op {
+	case database.BatchDelete:
+		entry.Action = actionImageDelete
+		entry.Before = auditJSON(r.Before)
+	case database.BatchDescribe, database.BatchReorder:
+		entry.Action = actionImageUpdate
+		entry.Before = auditJSON(r.Before)
+		entry.After = auditJSON(r.Image)
+	case database.BatchTag:
+		entry.Action = actionImageTag
+		entry.Before = auditTags(r.Before.Tags)
+		entry.After = auditTags(r.Image.Tags)
+	case database.BatchUntag:
+		entry.Action = actionImageUntag
+		entry.Before = auditTags(r.Before.Tags)
+		entry.After = auditTags(r.Image.Tags)
+	case database.BatchMove:
+		entry.Action = actionImageMove
+		entry.Before = auditJSON(r.Before)
+		entry.After = auditJSON(r.Image)
+	}
+
+	a.audit(req, entry)
+}
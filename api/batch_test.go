@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Batch(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	serve := func(uid, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, newUserRequest(uid, "POST", "/1/batch", bytes.NewReader([]byte(body))))
+		return res
+	}
+
+	for _, req := range []struct {
+		target string
+		body   []byte
+	}{
+		{"/", []byte(`{"title":"hello"}`)},
+		{"/1/albums", []byte(`{"title":"first"}`)},
+		{"/1/albums", []byte(`{"title":"second"}`)},
+		{"/1/grants", []byte(`{"userId":"contributor","role":"contributor","albums":[1]}`)},
+		{"/1/album/1/images", createTestImage().Bytes()},
+		{"/1/album/1/images", createTestImage().Bytes()},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, newUserRequest("owner", "POST", req.target, bytes.NewReader(req.body)))
+		if res.Code >= 300 {
+			t.Fatal(req.target, res.Code, res.Body.String())
+		}
+	}
+
+	res := serve("owner", `{"operations":[
+		{"op":"describe","albumId":1,"imageId":1,"description":"first"},
+		{"op":"tag","albumId":1,"imageId":1,"tags":["sea"]},
+		{"op":"move","albumId":1,"imageId":1,"toAlbumId":2},
+		{"op":"delete","albumId":1,"imageId":2}
+	]}`)
+	if res.Code != 200 {
+		t.Fatal("code not matches:", res.Code, res.Body.String())
+	}
+
+	var result struct {
+		Results []batchResult `json:"results"`
+	}
+	_ = json.NewDecoder(res.Body).Decode(&result)
+	if len(result.Results) != 4 || result.Results[3].Status != batchStatusOK || result.Results[3].Image != nil {
+		t.Errorf("Assertion Failed: %+v", result)
+	}
+	if i := result.Results[2].Image; i == nil || i.AlbumId != 2 || i.Id != 1 || i.Description != "first" {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newUserRequest("owner", "GET", "/audit?gallery=1&action=image.move", nil))
+	if !bytes.Contains(res.Body.Bytes(), []byte(`"action":"image.move"`)) {
+		t.Error("move not audited:", res.Body.String())
+	}
+
+	// second operation fails, first is rolled back
+	res = serve("owner", `{"operations":[
+		{"op":"describe","albumId":2,"imageId":1,"description":"rolled back"},
+		{"op":"untag","albumId":2,"imageId":1,"tags":["missing"]},
+		{"op":"delete","albumId":2,"imageId":1}
+	]}`)
+	var failure batchFailure
+	_ = json.NewDecoder(res.Body).Decode(&failure)
+	if res.Code != 404 || failure.Error == nil || failure.Code != "tag_not_found" || failure.Index != 1 {
+		t.Errorf("Assertion Failed: %d %+v", res.Code, failure)
+	}
+	for idx, status := range []string{batchStatusRolledBack, batchStatusFailed, batchStatusSkipped} {
+		if len(failure.Results) != 3 || failure.Results[idx].Status != status {
+			t.Errorf("Assertion Failed: %+v", failure.Results)
+			break
+		}
+	}
+	res = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/1/album/2/image/1", nil)
+	req.Header.Set("Accept", "application/json")
+	m.ServeHTTP(res, req)
+	if !bytes.Contains(res.Body.Bytes(), []byte(`"description":"first"`)) {
+		t.Error("batch not rolled back:", res.Body.String())
+	}
+
+	// contributor can not touch images of others
+	for idx, body := range []string{
+		`{"operations":[{"op":"describe","albumId":2,"imageId":1,"description":"mine"}]}`,
+		`{"operations":[]}`,
+		`{"operations":[{"op":"rename","albumId":2,"imageId":1}]}`,
+	} {
+		code := 403
+		if idx > 0 {
+			code = 400
+		}
+		if res := serve("contributor", body); res.Code != code {
+			t.Error(idx, "code not matches:", res.Code, "!=", code)
+		}
+	}
+}
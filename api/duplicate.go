@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+// uploadedImage is uploaded image, with its near-duplicates in the same gallery
+type uploadedImage struct {
+	database.Image
+	Duplicates []database.Duplicate `json:"duplicates,omitempty"`
+}
+
+// GET: get clusters of near-duplicate images across every gallery
+func (a *API) duplicatesHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	if !requireAdmin(res, req) {
+		return
+	}
+
+	c, err := a.db.GetDuplicateClusters()
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, c)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+func TestAPI_Duplicates(t *testing.T) {
+	m := createFixtureAPI()
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()))
+	var u struct {
+		Id         uint64               `json:"id"`
+		Duplicates []database.Duplicate `json:"duplicates"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &u); err != nil || res.Code != 201 || u.Id != 2 {
+		t.Fatalf("Assertion Failed: %d %s %v", res.Code, res.Body.String(), err)
+	}
+	if len(u.Duplicates) != 1 || u.Duplicates[0] != (database.Duplicate{GalleryId: 1, AlbumId: 1, ImageId: 1}) {
+		t.Errorf("Assertion Failed: %+v", u.Duplicates)
+	}
+	if w := res.Header().Get("Warning"); w != `299 - "1 near-duplicate images in gallery"` {
+		t.Errorf("Assertion Failed: %q", w)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("GET", "/duplicates", nil))
+	if res.Code != 403 {
+		t.Error("code not matches:", res.Code, "!=", 403)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("GET", "/duplicates", nil))
+	var c [][]database.Duplicate
+	if err := json.Unmarshal(res.Body.Bytes(), &c); err != nil || res.Code != 200 {
+		t.Fatalf("Assertion Failed: %d %s %v", res.Code, res.Body.String(), err)
+	}
+	if len(c) != 1 || len(c[0]) != 2 || c[0][1].ImageId != 2 {
+		t.Errorf("Assertion Failed: %+v", c)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+// GET: get edit list of image
+// PUT: replace edit list of image, and render image again from its original
+// DELETE: revert image to its original
+func (a *API) imageEditsHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	aid, err := atou(vars["aid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	iid, err := atou(vars["iid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	edits := make([]database.Edit, 0)
+	action := actionImageEdit
+
+	switch req.Method {
+	case "GET":
+		e, err := a.db.GetImageEdits(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, e)
+		return
+	case "PUT":
+		if !a.canModifyImage(res, req, gid, aid, iid) {
+			return
+		}
+
+		err := json.NewDecoder(req.Body).Decode(&edits)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+	case "DELETE":
+		if !a.canModifyImage(res, req, gid, aid, iid) {
+			return
+		}
+		action = actionImageRevert
+	default:
+		methodNotAllowed(res, "GET", "PUT", "DELETE")
+		return
+	}
+
+	before, err := a.db.GetImageEdits(gid, aid, iid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	err = a.db.SetImageEdits(gid, aid, iid, edits)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	// setting edits image already has is not recorded
+	if b, e := auditJSON(before), auditJSON(edits); b != e {
+		a.audit(req, database.AuditEntry{Action: action, GalleryId: gid, AlbumId: aid, ImageId: iid, Before: b, After: e})
+	}
+
+	i, err := a.db.GetImageInfo(gid, aid, iid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, i)
+}
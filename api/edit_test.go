@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_ImageEdits(t *testing.T) {
+	a := createTestAPI()
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+		body string
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, ""},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, ""},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201, ""},
+		{httptest.NewRequest("GET", "/1/album/1/image/1/edits", nil), 200, "[]\n"},
+		{httptest.NewRequest("PUT", "/1/album/1/image/1/edits", bytes.NewReader([]byte(`[{"op":"rotate","angle":90}]`))), 403, ""},
+		{newAuthenticatedRequest("PUT", "/1/album/1/image/1/edits", bytes.NewReader([]byte(`{"op":"rotate"}`))), 400, ""},
+		{newAuthenticatedRequest("PUT", "/1/album/1/image/1/edits", bytes.NewReader([]byte(`[{"op":"rotate","angle":45}]`))), 400, ""},
+		{newAuthenticatedRequest("PUT", "/1/album/1/image/2/edits", bytes.NewReader([]byte(`[]`))), 404, ""},
+		{newAuthenticatedRequest("PUT", "/1/album/1/image/1/edits", bytes.NewReader([]byte(`[{"op":"rotate","angle":90},{"op":"crop","x":0,"y":0,"width":100,"height":50}]`))), 200, ""},
+		{httptest.NewRequest("GET", "/1/album/1/image/1/edits", nil), 200, `[{"op":"rotate","angle":90},{"op":"crop","width":100,"height":50}]` + "\n"},
+		{newAuthenticatedRequest("POST", "/1/album/1/image/1/edits", nil), 405, ""},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code, res.Body.String())
+		}
+		if r.body != "" && res.Body.String() != r.body {
+			t.Error(idx, "body not matches:", res.Body.String(), "!=", r.body)
+		}
+	}
+
+	info := func() database.Image {
+		var i database.Image
+		res := httptest.NewRecorder()
+		req := newAuthenticatedRequest("GET", "/1/album/1/image/1", nil)
+		req.Header.Set("Accept", "application/json")
+		m.ServeHTTP(res, req)
+		if err := json.NewDecoder(res.Body).Decode(&i); err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+
+	if i := info(); i.Width != 100 || i.Height != 50 || len(i.Edits) != 2 {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("DELETE", "/1/album/1/image/1/edits", nil))
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+	if i := info(); i.Edits != nil || i.Width == 100 {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	// reverting unedited image is not audited
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("DELETE", "/1/album/1/image/1/edits", nil))
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+
+	entries, _ := a.db.GetAuditLog(database.AuditFilter{GalleryId: 1})
+	if len(entries) < 3 || entries[0].Action != actionImageRevert || entries[1].Action != actionImageEdit || entries[2].Action == actionImageRevert {
+		t.Errorf("Assertion Failed: %+v", entries)
+	}
+}
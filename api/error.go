@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+// Error is JSON error response.
+// Code is stable machine-readable identifier, Message is for humans.
+type Error struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrInvalidId        = &Error{http.StatusBadRequest, "invalid_id", "invalid id"}
+	ErrInvalidJSON      = &Error{http.StatusBadRequest, "invalid_json", "invalid JSON body"}
+	ErrInvalidPage      = &Error{http.StatusBadRequest, "invalid_page", "invalid limit or after"}
+	ErrInvalidParameter = &Error{http.StatusBadRequest, "invalid_parameter", "invalid query parameter"}
+	ErrLoginRequired    = &Error{http.StatusForbidden, "login_required", "login required"}
+	ErrForbidden        = &Error{http.StatusForbidden, "forbidden", "permission denied"}
+	ErrMethodNotAllowed = &Error{http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed"}
+	ErrPrecondition     = &Error{http.StatusPreconditionFailed, "precondition_failed", "entity changed since If-Match ETag"}
+	ErrInternal         = &Error{http.StatusInternalServerError, "internal_error", "internal server error"}
+)
+
+// databaseErrors maps database sentinel errors to API errors
+var databaseErrors = map[error]*Error{
+	database.ErrGalleryNotFound:   {http.StatusNotFound, "gallery_not_found", database.ErrGalleryNotFound.Error()},
+	database.ErrAlbumNotFound:     {http.StatusNotFound, "album_not_found", database.ErrAlbumNotFound.Error()},
+	database.ErrImageNotFound:     {http.StatusNotFound, "image_not_found", database.ErrImageNotFound.Error()},
+	database.ErrGrantNotFound:     {http.StatusNotFound, "grant_not_found", database.ErrGrantNotFound.Error()},
+	database.ErrTagNotFound:       {http.StatusNotFound, "tag_not_found", database.ErrTagNotFound.Error()},
+	database.ErrInvalidRole:       {http.StatusBadRequest, "invalid_role", database.ErrInvalidRole.Error()},
+	database.ErrLastOwner:         {http.StatusBadRequest, "last_owner", database.ErrLastOwner.Error()},
+	database.ErrInvalidTag:        {http.StatusBadRequest, "invalid_tag", database.ErrInvalidTag.Error()},
+	database.ErrInvalidQuery:      {http.StatusBadRequest, "invalid_query", database.ErrInvalidQuery.Error()},
+	database.ErrSmartAlbum:        {http.StatusBadRequest, "smart_album", database.ErrSmartAlbum.Error()},
+	database.ErrNotSmartAlbum:     {http.StatusBadRequest, "not_smart_album", database.ErrNotSmartAlbum.Error()},
+	database.ErrUnsupportedFormat: {http.StatusUnsupportedMediaType, "unsupported_image_format", database.ErrUnsupportedFormat.Error()},
+	database.ErrInvalidImage:      {http.StatusBadRequest, "invalid_image", database.ErrInvalidImage.Error()},
+	database.ErrInvalidVisibility: {http.StatusBadRequest, "invalid_visibility", database.ErrInvalidVisibility.Error()},
+	database.ErrInvalidMetadata:   {http.StatusBadRequest, "invalid_metadata", database.ErrInvalidMetadata.Error()},
+	database.ErrInvalidCover:      {http.StatusBadRequest, "invalid_cover", database.ErrInvalidCover.Error()},
+	database.ErrInvalidBatch:      {http.StatusBadRequest, "invalid_batch", database.ErrInvalidBatch.Error()},
+	database.ErrWebhookNotFound:   {http.StatusNotFound, "webhook_not_found", database.ErrWebhookNotFound.Error()},
+	database.ErrInvalidWebhook:    {http.StatusBadRequest, "invalid_webhook", database.ErrInvalidWebhook.Error()},
+	database.ErrTilesNotFound:     {http.StatusNotFound, "tiles_not_found", database.ErrTilesNotFound.Error()},
+	database.ErrTileNotFound:      {http.StatusNotFound, "tile_not_found", database.ErrTileNotFound.Error()},
+	database.ErrImageNotReady:     {http.StatusConflict, "image_not_ready", database.ErrImageNotReady.Error()},
+	database.ErrJobNotFound:       {http.StatusNotFound, "job_not_found", database.ErrJobNotFound.Error()},
+	database.ErrJobNotFailed:      {http.StatusConflict, "job_not_failed", database.ErrJobNotFailed.Error()},
+	database.ErrWatermarkNotFound: {http.StatusNotFound, "watermark_not_found", database.ErrWatermarkNotFound.Error()},
+	database.ErrInvalidWatermark:  {http.StatusBadRequest, "invalid_watermark", database.ErrInvalidWatermark.Error()},
+	database.ErrInvalidEdit:       {http.StatusBadRequest, "invalid_edit", database.ErrInvalidEdit.Error()},
+	database.ErrOriginalNotFound:  {http.StatusConflict, "original_not_found", database.ErrOriginalNotFound.Error()},
+}
+
+// toError converts err to API error.
+// Unknown errors are logged, and hidden behind ErrInternal.
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	if e, ok := databaseErrors[err]; ok {
+		return e
+	}
+	log.Println(err)
+	return ErrInternal
+}
+
+// methodNotAllowed writes 405 response with allowed methods.
+func methodNotAllowed(res http.ResponseWriter, allow ...string) {
+	res.Header().Set("Allow", strings.Join(allow, ", "))
+	writeError(res, ErrMethodNotAllowed)
+}
+
+// writeError writes err as JSON error response.
+func writeError(res http.ResponseWriter, err error) {
+	e := toError(err)
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(e.Status)
+
+	if err := json.NewEncoder(res).Encode(e); err != nil {
+		log.Println(err)
+	}
+}
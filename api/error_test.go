@@ -0,0 +1,63 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Errors(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+		err  string
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, ""},
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":`))), 400, "invalid_json"},
+		{newAuthenticatedRequest("GET", "/abc", nil), 400, "invalid_id"},
+		{newAuthenticatedRequest("GET", "/2", nil), 404, "gallery_not_found"},
+		{newAuthenticatedRequest("GET", "/1/album/1", nil), 404, "album_not_found"},
+		{newAuthenticatedRequest("PATCH", "/", nil), 405, "method_not_allowed"},
+		{newAuthenticatedRequest("GET", "/?limit=0", nil), 400, "invalid_page"},
+		{httptest.NewRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"first"}`))), 403, "login_required"},
+		{newUserRequest("stranger", "POST", "/1/albums", bytes.NewReader([]byte(`{"title":"first"}`))), 403, "forbidden"},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"first"}`))), 201, ""},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", bytes.NewReader([]byte("GIF89a"))), 400, "invalid_image"},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", bytes.NewReader([]byte("not an image"))), 415, "unsupported_image_format"},
+		{newAuthenticatedRequest("GET", "/1/album/1/image/1", nil), 404, "image_not_found"},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code)
+		}
+
+		if r.err == "" {
+			continue
+		}
+
+		if ct := res.Header().Get("Content-Type"); ct != "application/json" {
+			t.Error(idx, "content type not matches:", ct)
+		}
+
+		var e Error
+		err := json.NewDecoder(res.Body).Decode(&e)
+		if err != nil {
+			t.Error(idx, err)
+			continue
+		}
+		if e.Code != r.err || e.Status != r.code {
+			t.Error(idx, "error not matches:", e, "!=", r.err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/dfkdream/hugocms/plugin"
+)
+
+const defaultHeartbeat = 15 * time.Second
+
+// GET: stream change events as Server-Sent Events.
+// Events of single gallery are streamed to its viewers if gallery is given,
+// and events of every gallery to admins otherwise.
+func (a *API) eventsHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	u := plugin.GetUser(req)
+	if u == nil {
+		writeError(res, ErrLoginRequired)
+		return
+	}
+
+	var gid uint64
+	if v := req.URL.Query().Get("gallery"); v != "" {
+		var err error
+		gid, err = atou(v)
+		if err != nil {
+			writeError(res, ErrInvalidId)
+			return
+		}
+
+		g, err := a.grantOf(req, gid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		if !g.Role.Includes(database.RoleViewer) {
+			writeError(res, ErrForbidden)
+			return
+		}
+		if _, err := a.db.GetGallery(gid); err != nil {
+			writeError(res, err)
+			return
+		}
+	} else if !u.HasPermission(adminPermission) {
+		writeError(res, ErrForbidden)
+		return
+	}
+
+	f, ok := res.(http.Flusher)
+	if !ok {
+		writeError(res, ErrInternal)
+		return
+	}
+
+	events, cancel := a.db.Subscribe(gid)
+	defer cancel()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	_, err := fmt.Fprint(res, "retry: 3000\n\n")
+	if err != nil {
+		return
+	}
+	f.Flush()
+
+	heartbeat := time.NewTicker(a.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(res, ": heartbeat\n\n")
+		case e, ok := <-events:
+			if !ok {
+				// subscriber fell behind. client reconnects and reloads state.
+				return
+			}
+
+			var b []byte
+			b, err = json.Marshal(e)
+			if err == nil {
+				_, err = fmt.Fprintf(res, "id: %d\ndata: %s\n\n", e.Id, b)
+			}
+		}
+		if err != nil {
+			return
+		}
+		f.Flush()
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/dfkdream/hugocms/plugin"
+	"github.com/dfkdream/hugocms/user"
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Events(t *testing.T) {
+	a := createTestAPI()
+	a.heartbeat = 50 * time.Millisecond
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	s := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		u := &user.User{Id: req.Header.Get("X-User")}
+		m.ServeHTTP(res, req.WithContext(context.WithValue(req.Context(), plugin.ContextKeyUser, u)))
+	}))
+	defer s.Close()
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		return res
+	}
+
+	serve(newUserRequest("owner", "POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))))
+	serve(newUserRequest("owner", "POST", "/", bytes.NewReader([]byte(`{"title":"world"}`))))
+
+	for idx, r := range []struct {
+		target string
+		code   int
+	}{
+		{"/events", 403},
+		{"/events?gallery=1", 403},
+		{"/events?gallery=x", 400},
+	} {
+		if res := serve(newUserRequest("stranger", "GET", r.target, nil)); res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code)
+		}
+	}
+	if res := serve(httptest.NewRequest("GET", "/events?gallery=1", nil)); res.Code != 403 {
+		t.Error("code not matches:", res.Code, "!=", 403)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequest("GET", s.URL+"/events?gallery=1", nil)
+	req.Header.Set("X-User", "owner")
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("stream not opened:", res.Status, res.Header)
+	}
+
+	serve(newUserRequest("owner", "POST", "/2/albums", bytes.NewReader([]byte(`{"title":"other"}`))))
+	serve(newUserRequest("owner", "POST", "/1/albums", bytes.NewReader([]byte(`{"title":"album"}`))))
+
+	var heartbeat bool
+	var e database.Event
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			line := sc.Text()
+			if line == ": heartbeat" {
+				heartbeat = true
+			}
+			if strings.HasPrefix(line, "data: ") {
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e)
+			}
+			if heartbeat && e.Id != 0 {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not streamed")
+	}
+	if e.Entity != database.EntityAlbum || e.Action != database.ActionCreate || e.GalleryId != 1 || e.AlbumId != 1 {
+		t.Errorf("Assertion Failed: %+v", e)
+	}
+}
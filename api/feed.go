@@ -0,0 +1,271 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	feedLimit          = 50
+	maxFeedTitleLength = 80
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Href   string `xml:"href,attr"`
+	Length int    `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Id        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Links     []atomLink `xml:"link"`
+	Content   *atomText  `xml:"content,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	Guid        string        `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Description string        `xml:"description,omitempty"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// feedItem is format independent entry of feed
+type feedItem struct {
+	database.FeedEntry
+	Link      string
+	Thumbnail string
+}
+
+// baseURL returns scheme and host request was sent to
+func baseURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	if p := req.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+	return scheme + "://" + req.Host
+}
+
+// feedTitle returns first line of s, shortened to maxFeedTitleLength runes
+func feedTitle(s, fallback string) string {
+	s = strings.TrimSpace(strings.SplitN(s, "\n", 2)[0])
+	if s == "" {
+		return fallback
+	}
+	if utf8.RuneCountInString(s) > maxFeedTitleLength {
+		s = string([]rune(s)[:maxFeedTitleLength-1]) + "…"
+	}
+	return s
+}
+
+func renderAtom(self, title, author string, updated time.Time, items []feedItem) interface{} {
+	f := atomFeed{
+		Id:      self,
+		Title:   title,
+		Updated: updated.Format(time.RFC3339),
+		Author:  atomAuthor{Name: author},
+		Links:   []atomLink{{Rel: "self", Type: "application/atom+xml", Href: self}},
+		Entries: make([]atomEntry, 0, len(items)),
+	}
+
+	for _, i := range items {
+		e := atomEntry{
+			Id:        i.Link,
+			Title:     i.Title,
+			Published: i.Published.Format(time.RFC3339),
+			Updated:   i.Published.Format(time.RFC3339),
+			Links:     []atomLink{{Rel: "alternate", Href: i.Link}},
+		}
+		if i.Thumbnail != "" {
+			e.Links = append(e.Links, atomLink{Rel: "enclosure", Type: "image/jpeg", Href: i.Thumbnail, Length: i.ThumbnailSize})
+		}
+		if i.Content != "" {
+			e.Content = &atomText{Type: "text", Body: i.Content}
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	return f
+}
+
+func renderRSS(self, title string, updated time.Time, items []feedItem) interface{} {
+	f := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        self,
+			Description: title,
+			Items:       make([]rssItem, 0, len(items)),
+		},
+	}
+	if !updated.IsZero() {
+		f.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+
+	for _, i := range items {
+		item := rssItem{
+			Title:       i.Title,
+			Link:        i.Link,
+			Guid:        i.Link,
+			PubDate:     i.Published.Format(time.RFC1123Z),
+			Description: i.Content,
+		}
+		if i.Thumbnail != "" {
+			item.Enclosure = &rssEnclosure{URL: i.Thumbnail, Length: i.ThumbnailSize, Type: "image/jpeg"}
+		}
+		f.Channel.Items = append(f.Channel.Items, item)
+	}
+	return f
+}
+
+// GET: get Atom or RSS feed of new albums of gallery, or new images of album
+func (a *API) feedHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	ext := path.Ext(req.URL.Path)
+	base := baseURL(req)
+	self := base + req.URL.Path
+
+	var title, author string
+	var entries []database.FeedEntry
+	// entries of smart album may belong to other galleries
+	var galleriesPath string
+
+	if v, ok := vars["aid"]; ok {
+		aid, err := atou(v)
+		if err != nil {
+			writeError(res, ErrInvalidId)
+			return
+		}
+
+		var album database.Album
+		album, entries, err = a.db.GetAlbumFeed(gid, aid, feedLimit)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		title, author = album.Title, album.Owner
+		galleriesPath = path.Dir(path.Dir(path.Dir(path.Dir(req.URL.Path))))
+	} else {
+		var gallery database.Gallery
+		gallery, entries, err = a.db.GetGalleryFeed(gid, feedLimit)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		title, author = gallery.Title, gallery.Owner
+		galleriesPath = path.Dir(path.Dir(req.URL.Path))
+	}
+
+	items := make([]feedItem, len(entries))
+	var updated time.Time
+	for idx, e := range entries {
+		albumPath := fmt.Sprintf("%s/%d/album/%d", strings.TrimSuffix(galleriesPath, "/"), e.GalleryId, e.AlbumId)
+		i := feedItem{FeedEntry: e}
+
+		if _, ok := vars["aid"]; ok {
+			i.Title = feedTitle(e.Content, fmt.Sprintf("Image %d", e.ImageId))
+			i.Link = fmt.Sprintf("%s%s/image/%d", base, albumPath, e.ImageId)
+		} else {
+			i.Title = feedTitle(e.Title, fmt.Sprintf("Album %d", e.AlbumId))
+			i.Link = base + albumPath + "/feed" + ext
+		}
+		if e.ImageId != 0 && e.ThumbnailSize != 0 {
+			i.Thumbnail = fmt.Sprintf("%s%s/image/%d?thumb=1", base, albumPath, e.ImageId)
+		}
+
+		if e.Published.After(updated) {
+			updated = e.Published
+		}
+		items[idx] = i
+	}
+
+	var feed interface{}
+	if ext == ".rss" {
+		res.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		feed = renderRSS(self, title, updated, items)
+	} else {
+		res.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		feed = renderAtom(self, title, author, updated, items)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		res.Header().Del("Content-Type")
+		writeError(res, err)
+		return
+	}
+
+	// ServeContent answers If-None-Match and If-Modified-Since with 304
+	res.Header().Set("ETag", etag(feed))
+	http.ServeContent(res, req, "", updated.Truncate(time.Second), bytes.NewReader(b.Bytes()))
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Feeds(t *testing.T) {
+	m := createFixtureAPI()
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("POST", "/1/album/1/image/1", strings.NewReader(`{"description":"sunset\nat the beach"}`)))
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "http://example.com/1/album/1/feed.atom", nil))
+	if res.Code != 200 || res.Header().Get("Content-Type") != "application/atom+xml; charset=utf-8" {
+		t.Fatal("feed not served:", res.Code, res.Header())
+	}
+
+	var atom atomFeed
+	if err := xml.Unmarshal(res.Body.Bytes(), &atom); err != nil {
+		t.Fatal(err)
+	}
+	if atom.Title != "hello" || atom.Id != "http://example.com/1/album/1/feed.atom" || len(atom.Entries) != 1 {
+		t.Fatalf("Assertion Failed: %+v", atom)
+	}
+	e := atom.Entries[0]
+	if e.Title != "sunset" || e.Id != "http://example.com/1/album/1/image/1" || e.Content == nil || e.Content.Body != "sunset\nat the beach" {
+		t.Errorf("Assertion Failed: %+v", e)
+	}
+	if len(e.Links) != 2 || e.Links[1].Href != "http://example.com/1/album/1/image/1?thumb=1" || e.Links[1].Length == 0 {
+		t.Errorf("Assertion Failed: %+v", e.Links)
+	}
+
+	tag, modified := res.Header().Get("ETag"), res.Header().Get("Last-Modified")
+	if tag == "" || modified == "" {
+		t.Error("validators not set:", res.Header())
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/1/album/1/feed.atom", nil)
+	req.Header.Set("If-None-Match", tag)
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	if res.Code != 304 {
+		t.Error("code not matches:", res.Code, "!=", 304)
+	}
+
+	req = httptest.NewRequest("GET", "http://example.com/1/feed.rss", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+
+	var rss rssFeed
+	if err := xml.Unmarshal(res.Body.Bytes(), &rss); err != nil {
+		t.Fatal(err)
+	}
+	if rss.Version != "2.0" || len(rss.Channel.Items) != 1 {
+		t.Fatalf("Assertion Failed: %+v", rss)
+	}
+	i := rss.Channel.Items[0]
+	if i.Title != "hello" || i.Link != "https://example.com/1/album/1/feed.rss" || i.Enclosure == nil || i.Enclosure.Type != "image/jpeg" {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	// unlisted album is left out of gallery feed
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("PATCH", "/1/album/1", strings.NewReader(`{"visibility":"unlisted"}`)))
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/feed.rss", nil))
+	if strings.Contains(res.Body.String(), "<item>") {
+		t.Error("unlisted album in feed:", res.Body.String())
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/2/feed.atom", nil))
+	if res.Code != 404 {
+		t.Error("code not matches:", res.Code, "!=", 404)
+	}
+}
+
+func TestAPI_SmartAlbumFeed(t *testing.T) {
+	a := createTestAPI()
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for _, r := range []struct {
+		target string
+		body   io.Reader
+	}{
+		{"/", strings.NewReader(`{"title":"hello"}`)},
+		{"/", strings.NewReader(`{"title":"world"}`)},
+		{"/2/albums", strings.NewReader(`{"title":"beach"}`)},
+		{"/2/album/1/images", createTestImage()},
+		{"/2/album/1/image/1/tags", strings.NewReader(`{"tags":["sunset"]}`)},
+		{"/1/albums", strings.NewReader(`{"title":"smart","query":{"tags":["sunset"],"galleries":[1,2]}}`)},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, newAdminRequest("POST", r.target, r.body))
+		if res.Code >= 300 {
+			t.Fatal(r.target, res.Code, res.Body.String())
+		}
+	}
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "http://example.com/1/album/1/feed.atom", nil))
+
+	var atom atomFeed
+	if err := xml.Unmarshal(res.Body.Bytes(), &atom); err != nil {
+		t.Fatal(res.Code, err)
+	}
+	if len(atom.Entries) != 1 {
+		t.Fatalf("Assertion Failed: %+v", atom)
+	}
+	e := atom.Entries[0]
+	if e.Id != "http://example.com/2/album/1/image/1" || len(e.Links) != 2 || e.Links[1].Href != "http://example.com/2/album/1/image/1?thumb=1" || e.Links[1].Length == 0 {
+		t.Errorf("Assertion Failed: %+v", e)
+	}
+}
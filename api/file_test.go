@@ -0,0 +1,32 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+func TestAPI_ImageFiles(t *testing.T) {
+	m := createFixtureAPI()
+
+	req := newAuthenticatedRequest("GET", "/1/album/1/image/1", nil)
+	req.Header.Set("Accept", "application/json")
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+
+	var i database.Image
+	if err := json.Unmarshal(res.Body.Bytes(), &i); err != nil || res.Code != 200 {
+		t.Fatalf("Assertion Failed: %d %s %v", res.Code, res.Body.String(), err)
+	}
+	if i.Width != 1280 || i.Height != 1280 || i.Files == nil || i.Files.Original == nil || i.Files.Display == nil || i.Files.Thumbnail == nil {
+		t.Fatalf("Assertion Failed: %+v", i)
+	}
+	if o := i.Files.Original; o.MimeType != "image/jpeg" || o.Format != "jpeg" || o.Bytes != createTestImage().Len() {
+		t.Errorf("Assertion Failed: %+v", o)
+	}
+	if th := i.Files.Thumbnail; th.Width != 360 || th.Height != 360 {
+		t.Errorf("Assertion Failed: %+v", th)
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/dfkdream/gallery-plugin/iiif"
+
+	"github.com/gorilla/mux"
+)
+
+// iiifErrors maps iiif errors to API errors
+var iiifErrors = map[error]*Error{
+	iiif.ErrInvalidRequest: {http.StatusBadRequest, "invalid_iiif_request", iiif.ErrInvalidRequest.Error()},
+	iiif.ErrNotImplemented: {http.StatusNotImplemented, "iiif_not_implemented", iiif.ErrNotImplemented.Error()},
+}
+
+// imageVars parses gallery, album and image id of request
+func imageVars(req *http.Request) (gid, aid, iid uint64, err error) {
+	vars := mux.Vars(req)
+	if gid, err = atou(vars["gid"]); err != nil {
+		return 0, 0, 0, ErrInvalidId
+	}
+	if aid, err = atou(vars["aid"]); err != nil {
+		return 0, 0, 0, ErrInvalidId
+	}
+	if iid, err = atou(vars["iid"]); err != nil {
+		return 0, 0, 0, ErrInvalidId
+	}
+	return gid, aid, iid, nil
+}
+
+// writeLinkedData writes IIIF document v of context.
+// It is sent as JSON-LD when client accepts it, and as JSON otherwise.
+func writeLinkedData(res http.ResponseWriter, req *http.Request, context string, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	if strings.Contains(req.Header.Get("Accept"), "application/ld+json") {
+		res.Header().Set("Content-Type", fmt.Sprintf(`application/ld+json;profile="%s"`, context))
+	} else {
+		res.Header().Set("Content-Type", "application/json")
+	}
+	res.Header().Set("Access-Control-Allow-Origin", "*")
+	res.Header().Set("ETag", etag(v))
+	http.ServeContent(res, req, "", time.Time{}, bytes.NewReader(b))
+}
+
+// GET: redirect IIIF image service base URI to image information
+func (a *API) iiifHandler(res http.ResponseWriter, req *http.Request) {
+	gid, aid, iid, err := imageVars(req)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	if _, err := a.db.GetImageInfo(gid, aid, iid); err != nil {
+		writeError(res, err)
+		return
+	}
+
+	res.Header().Set("Access-Control-Allow-Origin", "*")
+	http.Redirect(res, req, req.URL.Path+"/info.json", http.StatusSeeOther)
+}
+
+// GET: get IIIF image information of image
+func (a *API) iiifInfoHandler(res http.ResponseWriter, req *http.Request) {
+	gid, aid, iid, err := imageVars(req)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	size, thumb, err := a.db.GetImageSizes(gid, aid, iid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	info := iiif.NewInfo(baseURL(req)+path.Dir(req.URL.Path), size.Width, size.Height,
+		iiif.Size(thumb), iiif.Size(size))
+	writeLinkedData(res, req, iiif.ImageContext, info)
+}
+
+// GET: get region of image, scaled, rotated and encoded as requested by IIIF image request
+func (a *API) iiifImageHandler(res http.ResponseWriter, req *http.Request) {
+	gid, aid, iid, err := imageVars(req)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	size, thumb, err := a.db.GetImageSizes(gid, aid, iid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	vars := mux.Vars(req)
+	r, err := iiif.Parse(vars["region"], vars["size"], vars["rotation"], vars["quality"], vars["format"], size.Width, size.Height)
+	if e, ok := iiifErrors[err]; ok {
+		err = e
+	}
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	// stored image and thumbnail are served as is
+	stored := r.Region == image.Rect(0, 0, size.Width, size.Height) && r.Rotation == 0 && !r.Mirror &&
+		(r.Quality == iiif.QualityDefault || r.Quality == iiif.QualityColor) && r.Format == "jpg"
+
+	var b []byte
+	img, timestamp, err := a.db.GetImage(gid, aid, iid)
+	switch {
+	case err != nil:
+	case stored && r.Width == size.Width && r.Height == size.Height:
+		b = img
+	case stored && r.Width == thumb.Width && r.Height == thumb.Height:
+		b, timestamp, err = a.db.GetThumbnail(gid, aid, iid)
+	default:
+		b, err = a.renderIIIF(img, r)
+	}
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", iiif.ContentType(r.Format))
+	res.Header().Set("Access-Control-Allow-Origin", "*")
+	res.Header().Set("Link", fmt.Sprintf(`<%s>;rel="profile"`, iiif.Protocol+"/3/"+iiif.Profile+".json"))
+	http.ServeContent(res, req, "", timestamp, bytes.NewReader(b))
+}
+
+// renderIIIF decodes img and returns result of r encoded
+func (a *API) renderIIIF(img []byte, r iiif.Request) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(img))
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	err = iiif.Encode(&b, r.Apply(src, a.interpolation), r.Format, a.quality)
+	return b.Bytes(), err
+}
+
+// GET: get IIIF Presentation API manifest of album
+func (a *API) manifestHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	aid, err := atou(vars["aid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	album, err := a.db.GetAlbum(gid, aid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	images, err := a.db.GetImages(gid, aid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	base := baseURL(req)
+	galleriesPath := strings.TrimSuffix(path.Dir(path.Dir(path.Dir(path.Dir(req.URL.Path)))), "/")
+
+	m := iiif.NewManifest(base+req.URL.Path, album.Title)
+
+	keys := make([]string, 0, len(album.Metadata))
+	for k := range album.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		m.Metadata = append(m.Metadata, iiif.MetadataEntry{Label: iiif.Label(k), Value: iiif.Label(album.Metadata[k])})
+	}
+
+	for _, i := range images {
+		// images of smart album are stored in other albums, possibly of other galleries
+		imageGallery, imageAlbum := gid, aid
+		if i.AlbumId != 0 {
+			imageGallery, imageAlbum = i.GalleryId, i.AlbumId
+		}
+
+		// images being processed, or failed, have no canvas
+		size, thumb, err := a.db.GetImageSizes(imageGallery, imageAlbum, i.Id)
+		if err == database.ErrImageNotReady {
+			continue
+		}
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		imageURL := fmt.Sprintf("%s%s/%d/album/%d/image/%d", base, galleriesPath, imageGallery, imageAlbum, i.Id)
+		thumbnail := iiif.Resource{Id: imageURL + "?thumb=1", Type: "Image", Format: iiif.ContentType("jpg"), Width: thumb.Width, Height: thumb.Height}
+
+		c := iiif.NewCanvas(imageURL+"/canvas", feedTitle(i.Description, ""), imageURL+"/iiif", size.Width, size.Height)
+		c.Thumbnail = []iiif.Resource{thumbnail}
+		m.Items = append(m.Items, c)
+
+		if i.Id == album.Cover && len(m.Thumbnail) == 0 {
+			m.Thumbnail = []iiif.Resource{thumbnail}
+		}
+	}
+
+	writeLinkedData(res, req, iiif.PresentationContext, m)
+}
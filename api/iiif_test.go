@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"image"
+	_ "image/png"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/iiif"
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_IIIF(t *testing.T) {
+	m := createFixtureAPI()
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/1/image/1/iiif", nil))
+	if res.Code != 303 || res.Header().Get("Location") != "/1/album/1/image/1/iiif/info.json" {
+		t.Error("not redirected:", res.Code, res.Header())
+	}
+
+	req := httptest.NewRequest("GET", "http://example.com/1/album/1/image/1/iiif/info.json", nil)
+	req.Header.Set("Accept", "application/ld+json")
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	if !strings.HasPrefix(res.Header().Get("Content-Type"), "application/ld+json") || res.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("headers not matches:", res.Header())
+	}
+
+	var info iiif.Info
+	if err := json.Unmarshal(res.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != "http://example.com/1/album/1/image/1/iiif" || info.Width != 1280 || info.Height != 1280 || info.Type != "ImageService3" || len(info.Sizes) != 2 || info.MaxArea != iiif.MaxArea {
+		t.Errorf("Assertion Failed: %+v", info)
+	}
+
+	tests := []struct {
+		target      string
+		code        int
+		contentType string
+		width       int
+		height      int
+	}{
+		{"/1/album/1/image/1/iiif/full/max/0/default.jpg", 200, "image/jpeg", 1280, 1280},
+		{"/1/album/1/image/1/iiif/full/360,/0/default.jpg", 200, "image/jpeg", 360, 360},
+		{"/1/album/1/image/1/iiif/0,0,640,320/pct:50/!90/gray.png", 200, "image/png", 160, 320},
+		{"/1/album/1/image/1/iiif/0,0,640,640/^!2000,2000/0/default.gif", 200, "image/gif", 1280, 1280},
+		{"/1/album/1/image/1/iiif/square/^2000,2000/0/default.gif", 400, "application/json", 0, 0},
+		{"/1/album/1/image/1/iiif/full/2000,/0/default.jpg", 400, "application/json", 0, 0},
+		{"/1/album/1/image/1/iiif/full/max/45/default.jpg", 501, "application/json", 0, 0},
+		{"/1/album/1/image/9/iiif/full/max/0/default.jpg", 404, "application/json", 0, 0},
+	}
+
+	for _, test := range tests {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, httptest.NewRequest("GET", test.target, nil))
+		if res.Code != test.code || res.Header().Get("Content-Type") != test.contentType {
+			t.Error("response not matches:", test.target, res.Code, res.Header().Get("Content-Type"))
+			continue
+		}
+		if test.code != 200 {
+			continue
+		}
+		c, _, err := image.DecodeConfig(res.Body)
+		if err != nil {
+			t.Error(test.target, err)
+			continue
+		}
+		if c.Width != test.width || c.Height != test.height {
+			t.Error("size not matches:", test.target, c.Width, c.Height, "!=", test.width, test.height)
+		}
+	}
+}
+
+func TestAPI_Manifest(t *testing.T) {
+	m := createFixtureAPI()
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("PATCH", "/1/album/1", strings.NewReader(`{"metadata":{"place":"beach"}}`)))
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "http://example.com/1/album/1/manifest.json", nil))
+	if res.Code != 200 || res.Header().Get("Content-Type") != "application/json" {
+		t.Fatal("manifest not served:", res.Code, res.Header())
+	}
+
+	var manifest iiif.Manifest
+	if err := json.Unmarshal(res.Body.Bytes(), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Context != iiif.PresentationContext || manifest.Id != "http://example.com/1/album/1/manifest.json" || manifest.Label["none"][0] != "hello" {
+		t.Errorf("Assertion Failed: %+v", manifest)
+	}
+	if len(manifest.Metadata) != 1 || manifest.Metadata[0].Value["none"][0] != "beach" || len(manifest.Thumbnail) != 1 {
+		t.Errorf("Assertion Failed: %+v", manifest)
+	}
+	if len(manifest.Items) != 1 {
+		t.Fatalf("Assertion Failed: %+v", manifest.Items)
+	}
+
+	c := manifest.Items[0]
+	body := c.Items[0].Items[0].Body
+	if c.Width != 1280 || c.Items[0].Items[0].Target != c.Id || body.Service[0].Id != "http://example.com/1/album/1/image/1/iiif" {
+		t.Errorf("Assertion Failed: %+v", c)
+	}
+
+	// painted image resolves
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", strings.TrimPrefix(body.Id, "http://example.com"), nil))
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/9/manifest.json", nil))
+	if res.Code != 404 {
+		t.Error("code not matches:", res.Code, "!=", 404)
+	}
+}
+
+func TestAPI_SmartAlbumManifest(t *testing.T) {
+	a := createTestAPI()
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for _, r := range []struct {
+		target string
+		body   io.Reader
+	}{
+		{"/", strings.NewReader(`{"title":"hello"}`)},
+		{"/", strings.NewReader(`{"title":"world"}`)},
+		{"/2/albums", strings.NewReader(`{"title":"beach"}`)},
+		{"/2/album/1/images", createTestImage()},
+		{"/2/album/1/image/1/tags", strings.NewReader(`{"tags":["sunset"]}`)},
+		{"/1/albums", strings.NewReader(`{"title":"smart","query":{"tags":["sunset"],"galleries":[1,2]}}`)},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, newAdminRequest("POST", r.target, r.body))
+		if res.Code >= 300 {
+			t.Fatal(r.target, res.Code, res.Body.String())
+		}
+	}
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "http://example.com/1/album/1/manifest.json", nil))
+
+	var manifest iiif.Manifest
+	if err := json.Unmarshal(res.Body.Bytes(), &manifest); err != nil {
+		t.Fatal(res.Code, err)
+	}
+	if len(manifest.Items) != 1 {
+		t.Fatalf("Assertion Failed: %+v", manifest)
+	}
+	c := manifest.Items[0]
+	if c.Id != "http://example.com/2/album/1/image/1/canvas" || c.Width != 1280 || c.Thumbnail[0].Id != "http://example.com/2/album/1/image/1?thumb=1" {
+		t.Errorf("Assertion Failed: %+v", c)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+// GET: get queued, running and failed jobs, optionally filtered by status
+// POST: queue job regenerating images of album, gallery or every gallery
+func (a *API) jobsHandler(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		if !requireAdmin(res, req) {
+			return
+		}
+
+		status := req.URL.Query().Get("status")
+		switch status {
+		case "", database.JobQueued, database.JobRunning, database.JobFailed:
+		default:
+			writeError(res, ErrInvalidParameter)
+			return
+		}
+
+		j, err := a.db.GetJobs(status)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, j)
+	case "POST":
+		if !requireAdmin(res, req) {
+			return
+		}
+
+		var values database.Job
+
+		err := json.NewDecoder(req.Body).Decode(&values)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+
+		if values.Kind != database.JobRegenerateImages || values.ImageId != 0 {
+			writeError(res, ErrInvalidParameter)
+			return
+		}
+
+		j, err := a.db.RegenerateImages(values.GalleryId, values.AlbumId)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		res.Header().Set("Location", path.Join(path.Dir(req.URL.Path), "job", strconv.FormatUint(j.Id, 10)))
+		writeJSON(res, http.StatusAccepted, j)
+	default:
+		methodNotAllowed(res, "GET", "POST")
+	}
+}
+
+// GET: get job
+func (a *API) jobHandler(res http.ResponseWriter, req *http.Request) {
+	jid, err := atou(mux.Vars(req)["jid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	if !requireAdmin(res, req) {
+		return
+	}
+
+	j, err := a.db.GetJob(jid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, j)
+}
+
+// POST: queue failed job again
+func (a *API) jobRetryHandler(res http.ResponseWriter, req *http.Request) {
+	jid, err := atou(mux.Vars(req)["jid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "POST" {
+		methodNotAllowed(res, "POST")
+		return
+	}
+
+	if !requireAdmin(res, req) {
+		return
+	}
+
+	j, err := a.db.RetryJob(jid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	writeJSON(res, http.StatusOK, j)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/dfkdream/gallery-plugin/iiif"
+
+	"github.com/gorilla/mux"
+	"github.com/nfnt/resize"
+)
+
+func TestAPI_Jobs(t *testing.T) {
+	cfg := &config.Config{Interpolation: resize.Lanczos3, Quality: 80, ProcessingWorkers: 1}
+	db, err := database.New(createTestBolt(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := mux.NewRouter()
+	New(db, cfg).SetupHandlers(m)
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("POST", "/", strings.NewReader(`{"title":"hello"}`)))
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("POST", "/1/albums", strings.NewReader(`{"title":"hello"}`)))
+
+	// upload is accepted, and processed later
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()))
+	var i database.Image
+	if err := json.Unmarshal(res.Body.Bytes(), &i); err != nil || res.Code != 202 || i.Status != database.ImagePending || res.Header().Get("Location") != "/1/album/1/image/1" {
+		t.Fatalf("Assertion Failed: %d %+v %v", res.Code, i, err)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/1/image/1", nil))
+	if res.Code != 409 {
+		t.Error("code not matches:", res.Code, "!=", 409)
+	}
+
+	// metadata is available while processing
+	req := httptest.NewRequest("GET", "/1/album/1/image/1", nil)
+	req.Header.Set("Accept", "application/json")
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+
+	b := createTestImage().Bytes()
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("POST", "/1/album/1/images", bytes.NewReader(b[:len(b)/2])))
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("GET", "/jobs", nil))
+	if res.Code != 403 {
+		t.Error("code not matches:", res.Code, "!=", 403)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("GET", "/jobs?status=queued", nil))
+	var jobs []database.Job
+	if err := json.Unmarshal(res.Body.Bytes(), &jobs); err != nil || len(jobs) != 2 {
+		t.Fatalf("Assertion Failed: %s %v", res.Body.String(), err)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("GET", "/jobs?status=done", nil))
+	if res.Code != 400 {
+		t.Error("code not matches:", res.Code, "!=", 400)
+	}
+
+	for {
+		j, ok, _ := db.ClaimJob()
+		if !ok {
+			break
+		}
+		_ = db.CompleteJob(j.Id, db.RunJob(j))
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/1/image/1", nil))
+	if res.Code != 200 || res.Header().Get("Content-Type") != "image/jpeg" {
+		t.Error("image not served:", res.Code)
+	}
+
+	// failed image is left out of manifest
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/1/manifest.json", nil))
+	var manifest iiif.Manifest
+	if err := json.Unmarshal(res.Body.Bytes(), &manifest); err != nil || res.Code != 200 || len(manifest.Items) != 1 {
+		t.Errorf("Assertion Failed: %d %s %v", res.Code, res.Body.String(), err)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("GET", "/job/2", nil))
+	var j database.Job
+	if err := json.Unmarshal(res.Body.Bytes(), &j); err != nil || j.Status != database.JobFailed || j.ImageId != 2 {
+		t.Fatalf("Assertion Failed: %+v %v", j, err)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("POST", "/job/2/retry", nil))
+	if err := json.Unmarshal(res.Body.Bytes(), &j); err != nil || res.Code != 200 || j.Status != database.JobQueued {
+		t.Errorf("Assertion Failed: %d %+v %v", res.Code, j, err)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("POST", "/job/2/retry", nil))
+	if res.Code != 409 {
+		t.Error("code not matches:", res.Code, "!=", 409)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("GET", "/job/1", nil))
+	if res.Code != 404 {
+		t.Error("code not matches:", res.Code, "!=", 404)
+	}
+}
+
+func TestAPI_RegenerateImages(t *testing.T) {
+	m := createFixtureAPI()
+
+	for _, c := range []struct {
+		req  *http.Request
+		code int
+	}{
+		{newAuthenticatedRequest("POST", "/jobs", strings.NewReader(`{"kind":"images.regenerate"}`)), 403},
+		{newAdminRequest("POST", "/jobs", strings.NewReader(`{`)), 400},
+		{newAdminRequest("POST", "/jobs", strings.NewReader(`{"kind":"image.process","galleryId":1,"albumId":1,"imageId":1}`)), 400},
+		{newAdminRequest("POST", "/jobs", strings.NewReader(`{"kind":"images.regenerate","galleryId":2}`)), 404},
+		{newAdminRequest("POST", "/jobs", strings.NewReader(`{"kind":"images.regenerate","galleryId":1,"albumId":2}`)), 404},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, c.req)
+		if res.Code != c.code {
+			t.Error("code not matches:", c.req.Method, c.req.URL, res.Code, "!=", c.code)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("POST", "/jobs", strings.NewReader(`{"kind":"images.regenerate","galleryId":1}`)))
+	var j database.Job
+	if err := json.Unmarshal(res.Body.Bytes(), &j); err != nil || res.Code != 202 || res.Header().Get("Location") != "/job/1" {
+		t.Fatalf("Assertion Failed: %d %s %v", res.Code, res.Body.String(), err)
+	}
+	if j.Kind != database.JobRegenerateImages || j.GalleryId != 1 || j.Status != database.JobQueued || j.Progress == nil || j.Progress.Total != 1 {
+		t.Errorf("Assertion Failed: %+v", j)
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/gorilla/mux"
+)
+
+const (
+	providerName = "Gallery"
+
+	defaultEmbedWidth  = 800
+	defaultEmbedHeight = 600
+)
+
+var ErrFormatNotImplemented = &Error{http.StatusNotImplemented, "format_not_implemented", "only json format is implemented"}
+
+// oEmbedPath matches path of album or image URL, optionally under API prefix
+var oEmbedPath = regexp.MustCompile(`/(\d+)/album/(\d+)(?:/image/(\d+))?/?$`)
+
+// embedFrame is HTML of rich oEmbed, framing embed page of album
+var embedFrame = template.Must(template.New("frame").Parse(
+	`<iframe src="{{.URL}}" width="{{.Width}}" height="{{.Height}}" frameborder="0" allowfullscreen></iframe>`))
+
+// embedPage is equivalent of shortcodes/gallery.html, opening album
+var embedPage = template.Must(template.New("embed").Parse(
+	`<!DOCTYPE html>` + "\n" +
+		`<meta charset="utf-8">` + "\n" +
+		`<link rel="stylesheet" href="{{.API}}/assets/gallery.bundle.css" />` + "\n" +
+		`<div class="gallery" id="app" data-api="{{.API}}" data-gid="{{.GalleryId}}" data-aid="{{.AlbumId}}"></div>` + "\n" +
+		`<script src="{{.API}}/assets/gallery.bundle.js"></script>`))
+
+type oEmbed struct {
+	Version         string `json:"version"`
+	Type            string `json:"type"`
+	Title           string `json:"title,omitempty"`
+	AuthorName      string `json:"author_name,omitempty"`
+	ProviderName    string `json:"provider_name"`
+	URL             string `json:"url,omitempty"`
+	HTML            string `json:"html,omitempty"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"`
+	ThumbnailWidth  int    `json:"thumbnail_width,omitempty"`
+	ThumbnailHeight int    `json:"thumbnail_height,omitempty"`
+}
+
+// parseMax parses maxwidth or maxheight parameter. Absent parameter is zero.
+func parseMax(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i <= 0 {
+		return 0, ErrInvalidParameter
+	}
+	return i, nil
+}
+
+// GET: get oEmbed of album or image URL
+func (a *API) oEmbedHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	q := req.URL.Query()
+	if f := q.Get("format"); f != "" && f != "json" {
+		writeError(res, ErrFormatNotImplemented)
+		return
+	}
+
+	maxWidth, err := parseMax(q.Get("maxwidth"))
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	maxHeight, err := parseMax(q.Get("maxheight"))
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	u, err := url.Parse(q.Get("url"))
+	if err != nil {
+		writeError(res, ErrInvalidParameter)
+		return
+	}
+	m := oEmbedPath.FindStringSubmatch(u.Path)
+	if m == nil {
+		writeError(res, database.ErrAlbumNotFound)
+		return
+	}
+
+	gid, _ := atou(m[1])
+	aid, _ := atou(m[2])
+
+	// path of API router, where assets are served
+	apiPath := strings.TrimSuffix(req.URL.Path, "/oembed")
+	galleryURL := fmt.Sprintf("%s%s/%d", baseURL(req), apiPath, gid)
+
+	album, err := a.db.GetAlbum(gid, aid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	result := oEmbed{Version: "1.0", ProviderName: providerName}
+
+	if m[3] != "" {
+		iid, _ := atou(m[3])
+
+		i, err := a.db.GetImageInfo(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		size, thumb, err := a.db.GetImageSizes(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		imageURL := fmt.Sprintf("%s/album/%d/image/%d", galleryURL, aid, iid)
+		result.Type = "photo"
+		result.Title = feedTitle(i.Description, "")
+		result.AuthorName = i.Owner
+		result.ThumbnailURL = imageURL + "?thumb=1"
+		result.ThumbnailWidth, result.ThumbnailHeight = thumb.Width, thumb.Height
+
+		// full image if it fits, thumbnail displayed scaled down otherwise
+		switch {
+		case size.Fits(maxWidth, maxHeight):
+			result.URL = imageURL
+		case thumb.Fits(maxWidth, maxHeight):
+			result.URL, size = result.ThumbnailURL, thumb
+		default:
+			result.URL, size = result.ThumbnailURL, thumb.Fit(maxWidth, maxHeight)
+		}
+		result.Width, result.Height = size.Width, size.Height
+
+		writeJSON(res, http.StatusOK, result)
+		return
+	}
+
+	size := database.Size{Width: defaultEmbedWidth, Height: defaultEmbedHeight}
+	if maxWidth > 0 && maxWidth < size.Width {
+		size.Width = maxWidth
+	}
+	if maxHeight > 0 && maxHeight < size.Height {
+		size.Height = maxHeight
+	}
+
+	var html strings.Builder
+	err = embedFrame.Execute(&html, struct {
+		URL string
+		database.Size
+	}{fmt.Sprintf("%s/album/%d/embed", galleryURL, aid), size})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	result.Type = "rich"
+	result.Title = album.Title
+	result.AuthorName = album.Owner
+	result.HTML = html.String()
+	result.Width, result.Height = size.Width, size.Height
+
+	if album.Cover != 0 {
+		_, thumb, err := a.db.GetImageSizes(gid, aid, album.Cover)
+		if err == nil {
+			result.ThumbnailURL = fmt.Sprintf("%s/album/%d/image/%d?thumb=1", galleryURL, aid, album.Cover)
+			result.ThumbnailWidth, result.ThumbnailHeight = thumb.Width, thumb.Height
+		}
+	}
+
+	writeJSON(res, http.StatusOK, result)
+}
+
+// GET: get page embedding gallery, opening album
+func (a *API) embedHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	aid, err := atou(vars["aid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	if _, err := a.db.GetAlbum(gid, aid); err != nil {
+		writeError(res, err)
+		return
+	}
+
+	// path of API router, which the page fetches from
+	apiPath := strings.TrimSuffix(path.Dir(path.Dir(path.Dir(path.Dir(req.URL.Path)))), "/")
+
+	var b bytes.Buffer
+	err = embedPage.Execute(&b, struct {
+		API       string
+		GalleryId uint64
+		AlbumId   uint64
+	}{apiPath, gid, aid})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = res.Write(b.Bytes())
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAPI_OEmbed(t *testing.T) {
+	m := createFixtureAPI()
+
+	get := func(u, query string) (int, oEmbed) {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, httptest.NewRequest("GET", "http://example.com/oembed?url="+url.QueryEscape(u)+query, nil))
+		var result oEmbed
+		_ = json.Unmarshal(res.Body.Bytes(), &result)
+		return res.Code, result
+	}
+
+	code, photo := get("http://example.com/1/album/1/image/1", "")
+	if code != 200 || photo.Type != "photo" || photo.Version != "1.0" || photo.URL != "http://example.com/1/album/1/image/1" || photo.Width == 0 || photo.Height == 0 {
+		t.Fatalf("Assertion Failed: %d %+v", code, photo)
+	}
+	if photo.ThumbnailURL != "http://example.com/1/album/1/image/1?thumb=1" || photo.ThumbnailWidth == 0 {
+		t.Errorf("Assertion Failed: %+v", photo)
+	}
+
+	// thumbnail is scaled down to fit in bounds
+	code, small := get("http://example.com/1/album/1/image/1", "&maxwidth=10&maxheight=10")
+	if code != 200 || small.URL != photo.ThumbnailURL || small.Width > 10 || small.Height > 10 || small.Width == 0 {
+		t.Errorf("Assertion Failed: %d %+v", code, small)
+	}
+
+	code, rich := get("http://example.com/1/album/1", "&maxwidth=400")
+	if code != 200 || rich.Type != "rich" || rich.Title != "hello" || rich.Width != 400 || rich.Height != defaultEmbedHeight {
+		t.Fatalf("Assertion Failed: %d %+v", code, rich)
+	}
+	if rich.HTML != `<iframe src="http://example.com/1/album/1/embed" width="400" height="600" frameborder="0" allowfullscreen></iframe>` {
+		t.Errorf("Assertion Failed: %s", rich.HTML)
+	}
+
+	// framed page resolves, opening album
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "http://example.com/1/album/1/embed", nil))
+	if res.Code != 200 || res.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatal("embed not served:", res.Code, res.Header())
+	}
+	if body := res.Body.String(); !strings.Contains(body, `data-api="" data-gid="1" data-aid="1"`) || !strings.Contains(body, `src="/assets/gallery.bundle.js"`) {
+		t.Errorf("Assertion Failed: %s", body)
+	}
+	if rich.ThumbnailURL != photo.ThumbnailURL {
+		t.Errorf("Assertion Failed: %+v", rich)
+	}
+
+	tests := []struct {
+		url   string
+		query string
+		code  int
+	}{
+		{"http://example.com/1/album/1", "&format=xml", 501},
+		{"http://example.com/1/album/1", "&maxwidth=wide", 400},
+		{"http://example.com/1", "", 404},
+		{"http://example.com/1/album/9", "", 404},
+		{"http://example.com/1/album/1/image/9", "", 404},
+	}
+	for _, test := range tests {
+		if code, _ := get(test.url, test.query); code != test.code {
+			t.Error("code not matches:", test.url, test.query, code, "!=", test.code)
+		}
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/9/embed", nil))
+	if res.Code != 404 {
+		t.Error("code not matches:", res.Code, "!=", 404)
+	}
+}
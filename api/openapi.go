@@ -0,0 +1,1134 @@
+package api
+
+import (
+	"net/http"
+)
+
+// openAPISpec describes every route registered in SetupHandlers.
+// Paths are relative to server url, where API router is mounted.
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Gallery Plugin API",
+    "version": "1"
+  },
+  "servers": [{"url": "/api/gallery"}],
+  "paths": {
+    "/": {
+      "get": {
+        "operationId": "listGalleries",
+        "summary": "List galleries",
+        "parameters": [{"$ref": "#/components/parameters/limit"}, {"$ref": "#/components/parameters/after"}],
+        "responses": {
+          "200": {"description": "Galleries, wrapped with cursor when paged", "content": {"application/json": {"schema": {"oneOf": [{"type": "array", "items": {"$ref": "#/components/schemas/Gallery"}}, {"$ref": "#/components/schemas/GalleryPage"}]}}}},
+          "400": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "createGallery",
+        "summary": "Create gallery",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Title"}}}},
+        "responses": {
+          "201": {"description": "Created gallery", "headers": {"Location": {"$ref": "#/components/headers/Location"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Gallery"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "operationId": "getOpenAPI",
+        "summary": "Get this document",
+        "responses": {
+          "200": {"description": "OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    },
+    "/audit": {
+      "get": {
+        "operationId": "getAuditLog",
+        "summary": "Get audit log, newest first",
+        "description": "Admins can read every entry. Gallery owners can read entries of their gallery.",
+        "parameters": [
+          {"name": "actor", "in": "query", "schema": {"type": "string"}},
+          {"name": "action", "in": "query", "schema": {"type": "string"}},
+          {"name": "gallery", "in": "query", "schema": {"$ref": "#/components/schemas/Id"}},
+          {"name": "album", "in": "query", "schema": {"$ref": "#/components/schemas/Id"}},
+          {"name": "image", "in": "query", "schema": {"$ref": "#/components/schemas/Id"}},
+          {"name": "before", "in": "query", "description": "Return entries older than this entry id", "schema": {"$ref": "#/components/schemas/Id"}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 500, "default": 50}}
+        ],
+        "responses": {
+          "200": {"description": "Audit entries", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AuditLog"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/search": {
+      "get": {
+        "operationId": "search",
+        "summary": "Search galleries, albums and images",
+        "description": "Unlisted galleries and albums, and their images, are found by admins and by users with role on their gallery only.",
+        "parameters": [
+          {"name": "q", "in": "query", "schema": {"type": "string"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}}
+        ],
+        "responses": {
+          "200": {"description": "Results, highest score first", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/SearchResult"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/events": {
+      "get": {
+        "operationId": "streamEvents",
+        "summary": "Stream change events as Server-Sent Events",
+        "description": "Each message carries Event as JSON data, with event id. Comment lines are sent as heartbeat. Stream ends when client falls behind, and client should reload state after reconnecting. Events of every gallery require admin permission.",
+        "parameters": [{"name": "gallery", "in": "query", "description": "Stream events of gallery only. Requires viewer role.", "schema": {"$ref": "#/components/schemas/Id"}}],
+        "responses": {
+          "200": {"description": "Event stream", "content": {"text/event-stream": {"schema": {"$ref": "#/components/schemas/Event"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/oembed": {
+      "get": {
+        "operationId": "getOEmbed",
+        "summary": "Get oEmbed of album or image URL",
+        "description": "Image URL is photo type, which links full image if it fits in maxwidth and maxheight, and thumbnail scaled down otherwise. Album URL is rich type with iframe of embed page of album.",
+        "parameters": [
+          {"name": "url", "in": "query", "required": true, "description": "URL of album or image", "schema": {"type": "string"}},
+          {"name": "maxwidth", "in": "query", "schema": {"type": "integer", "minimum": 1}},
+          {"name": "maxheight", "in": "query", "schema": {"type": "integer", "minimum": 1}},
+          {"name": "format", "in": "query", "description": "Only json is implemented", "schema": {"type": "string", "enum": ["json"], "default": "json"}}
+        ],
+        "responses": {
+          "200": {"description": "oEmbed response", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/OEmbed"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/webhooks": {
+      "get": {
+        "operationId": "listWebhooks",
+        "summary": "List webhooks. Requires admin permission.",
+        "responses": {
+          "200": {"description": "Webhooks without secrets", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Webhook"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "createWebhook",
+        "summary": "Create webhook. Requires admin permission.",
+        "description": "Payload is audit entry of event, signed in X-Gallery-Signature header as sha256= and hex HMAC-SHA256 of body keyed by secret. X-Gallery-Event holds action, and X-Gallery-Delivery holds delivery id which stays the same across retries.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhook"}}}},
+        "responses": {
+          "201": {"description": "Created webhook with secret, which is generated unless given", "headers": {"Location": {"$ref": "#/components/headers/Location"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhook"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/webhook/{wid}": {
+      "parameters": [{"$ref": "#/components/parameters/wid"}],
+      "get": {
+        "operationId": "getWebhook",
+        "summary": "Get webhook. Requires admin permission.",
+        "responses": {
+          "200": {"description": "Webhook without secret", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhook"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteWebhook",
+        "summary": "Delete webhook and its queued deliveries. Requires admin permission.",
+        "responses": {
+          "200": {"description": "Deleted webhook", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Webhook"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/webhook/{wid}/deliveries": {
+      "parameters": [{"$ref": "#/components/parameters/wid"}],
+      "get": {
+        "operationId": "listDeliveries",
+        "summary": "List queued and failed deliveries of webhook. Requires admin permission.",
+        "responses": {
+          "200": {"description": "Deliveries, oldest first", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Delivery"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/jobs": {
+      "get": {
+        "operationId": "listJobs",
+        "summary": "List background jobs in queue order. Requires admin permission.",
+        "description": "Completed jobs are removed from queue.",
+        "parameters": [{"name": "status", "in": "query", "schema": {"type": "string", "enum": ["queued", "running", "failed"]}}],
+        "responses": {
+          "200": {"description": "Jobs", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Job"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "createJob",
+        "summary": "Queue job regenerating thumbnails and renditions from stored originals with current config. Requires admin permission.",
+        "description": "Zero or absent albumId regenerates every album of gallery, and zero or absent galleryId every gallery. Queued or running job of the same scope is returned instead of queueing another one. Progress is reported by the job, which resumes after restart.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {
+          "type": "object",
+          "required": ["kind"],
+          "properties": {
+            "kind": {"type": "string", "enum": ["images.regenerate"]},
+            "galleryId": {"$ref": "#/components/schemas/Id"},
+            "albumId": {"$ref": "#/components/schemas/Id"}
+          }
+        }}}},
+        "responses": {
+          "202": {"description": "Queued job", "headers": {"Location": {"$ref": "#/components/headers/Location"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Job"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/job/{jid}": {
+      "parameters": [{"$ref": "#/components/parameters/jid"}],
+      "get": {
+        "operationId": "getJob",
+        "summary": "Get background job. Requires admin permission.",
+        "responses": {
+          "200": {"description": "Job", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Job"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/job/{jid}/retry": {
+      "parameters": [{"$ref": "#/components/parameters/jid"}],
+      "post": {
+        "operationId": "retryJob",
+        "summary": "Queue failed job again. Requires admin permission.",
+        "responses": {
+          "200": {"description": "Queued job", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Job"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/duplicates": {
+      "get": {
+        "operationId": "listDuplicates",
+        "summary": "List clusters of near-duplicate images across every gallery. Requires admin permission.",
+        "description": "Images are near-duplicates if their perceptual hashes differ in at most 6 bits, and clustered through each other. Distance is to the first image of cluster. Images uploaded before hashes were stored are hashed when regenerated.",
+        "responses": {
+          "200": {"description": "Clusters", "content": {"application/json": {"schema": {"type": "array", "items": {"type": "array", "items": {"$ref": "#/components/schemas/Duplicate"}}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}],
+      "get": {
+        "operationId": "getGallery",
+        "summary": "Get gallery",
+        "responses": {
+          "200": {"description": "Gallery", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Gallery"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "setGalleryTitle",
+        "summary": "Set gallery title",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Title"}}}},
+        "responses": {
+          "200": {"description": "Updated gallery", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Gallery"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "operationId": "patchGallery",
+        "summary": "Update given fields of gallery",
+        "description": "JSON merge patch. Absent fields are left unchanged, and metadata keys set to null are removed.",
+        "parameters": [{"$ref": "#/components/parameters/ifMatch"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GalleryPatch"}}}},
+        "responses": {
+          "200": {"description": "Updated gallery", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Gallery"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "operationId": "replaceGallery",
+        "summary": "Replace every editable field of gallery",
+        "description": "Absent fields are reset.",
+        "parameters": [{"$ref": "#/components/parameters/ifMatch"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/GalleryPatch"}}}},
+        "responses": {
+          "200": {"description": "Updated gallery", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Gallery"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteGallery",
+        "summary": "Delete gallery",
+        "responses": {
+          "200": {"description": "Deleted gallery", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Gallery"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/batch": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}],
+      "post": {
+        "operationId": "batch",
+        "summary": "Apply operations on images of gallery in one transaction",
+        "description": "Either every operation is applied or none is. When an operation fails, response status is that of its error.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Batch"}}}},
+        "responses": {
+          "200": {"description": "Results in order of operations", "content": {"application/json": {"schema": {"type": "object", "properties": {"results": {"type": "array", "items": {"$ref": "#/components/schemas/BatchResult"}}}}}}},
+          "default": {"description": "Failed batch", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/BatchFailure"}}}}
+        }
+      }
+    },
+    "/{gid}/grants": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}],
+      "get": {
+        "operationId": "listGrants",
+        "summary": "List grants of gallery",
+        "responses": {
+          "200": {"description": "Grants", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Grant"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "setGrant",
+        "summary": "Create or replace grant",
+        "description": "Requires owner role. Galleries created before owners were recorded have no grants after upgrade, and are managed by admins only until an admin grants roles on them. The last owner of gallery can not be removed or downgraded.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Grant"}}}},
+        "responses": {
+          "200": {"description": "Grant", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Grant"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/grant/{uid}": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/uid"}],
+      "get": {
+        "operationId": "getGrant",
+        "summary": "Get grant of user",
+        "responses": {
+          "200": {"description": "Grant", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Grant"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteGrant",
+        "summary": "Delete grant of user",
+        "responses": {
+          "200": {"description": "Deleted grant", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Grant"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/watermark": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}],
+      "get": {
+        "operationId": "getWatermark",
+        "summary": "Get watermark of gallery",
+        "responses": {
+          "200": {"description": "Watermark", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Watermark"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "operationId": "setWatermark",
+        "summary": "Set watermark of gallery. Requires owner role.",
+        "description": "Display images, thumbnails and tiles of gallery are watermarked again by queued job. Originals are never watermarked.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Watermark"}}}},
+        "responses": {
+          "200": {"description": "Watermark", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Watermark"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteWatermark",
+        "summary": "Remove watermark of gallery. Requires owner role.",
+        "responses": {
+          "200": {"description": "Removed watermark", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Watermark"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/tags": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}],
+      "get": {
+        "operationId": "listTags",
+        "summary": "List tags used in gallery",
+        "description": "Images of unlisted albums are counted for users with role on gallery only.",
+        "responses": {
+          "200": {"description": "Tags with number of images", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/TagCount"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/tag/{tag}": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/tag"}],
+      "get": {
+        "operationId": "listImagesByTag",
+        "summary": "List images carrying tag, across albums",
+        "description": "Images of unlisted albums are returned to users with role on gallery only.",
+        "responses": {
+          "200": {"description": "Images", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Image"}}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/feed.atom": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}],
+      "get": {
+        "operationId": "getGalleryAtom",
+        "summary": "Atom feed of new albums of gallery",
+        "description": "Newest 50 entries. Unlisted albums are omitted. Responds 304 to If-None-Match and If-Modified-Since.",
+        "responses": {
+          "200": {"description": "Atom feed", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}, "Last-Modified": {"$ref": "#/components/headers/LastModified"}}, "content": {"application/atom+xml": {"schema": {"type": "string"}}}},
+          "304": {"description": "Not modified"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/feed.rss": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}],
+      "get": {
+        "operationId": "getGalleryRSS",
+        "summary": "RSS feed of new albums of gallery",
+        "description": "Newest 50 entries. Unlisted albums are omitted. Responds 304 to If-None-Match and If-Modified-Since.",
+        "responses": {
+          "200": {"description": "RSS 2.0 feed", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}, "Last-Modified": {"$ref": "#/components/headers/LastModified"}}, "content": {"application/rss+xml": {"schema": {"type": "string"}}}},
+          "304": {"description": "Not modified"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/albums": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}],
+      "get": {
+        "operationId": "listAlbums",
+        "summary": "List albums",
+        "parameters": [{"$ref": "#/components/parameters/limit"}, {"$ref": "#/components/parameters/after"}],
+        "responses": {
+          "200": {"description": "Albums, wrapped with cursor when paged", "content": {"application/json": {"schema": {"oneOf": [{"type": "array", "items": {"$ref": "#/components/schemas/Album"}}, {"$ref": "#/components/schemas/AlbumPage"}]}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "createAlbum",
+        "summary": "Create album, or smart album if query is given",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AlbumInput"}}}},
+        "responses": {
+          "201": {"description": "Created album", "headers": {"Location": {"$ref": "#/components/headers/Location"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Album"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}],
+      "get": {
+        "operationId": "getAlbum",
+        "summary": "Get album",
+        "responses": {
+          "200": {"description": "Album", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Album"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "updateAlbum",
+        "summary": "Set album title, and query of smart album",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AlbumInput"}}}},
+        "responses": {
+          "200": {"description": "Updated album", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Album"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "operationId": "patchAlbum",
+        "summary": "Update given fields of album",
+        "description": "JSON merge patch. Absent fields are left unchanged, and metadata keys set to null are removed.",
+        "parameters": [{"$ref": "#/components/parameters/ifMatch"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AlbumPatch"}}}},
+        "responses": {
+          "200": {"description": "Updated album", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Album"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "operationId": "replaceAlbum",
+        "summary": "Replace every editable field of album",
+        "description": "Absent fields are reset.",
+        "parameters": [{"$ref": "#/components/parameters/ifMatch"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AlbumPatch"}}}},
+        "responses": {
+          "200": {"description": "Updated album", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Album"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteAlbum",
+        "summary": "Delete album",
+        "responses": {
+          "200": {"description": "Deleted album", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Album"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/embed": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}],
+      "get": {
+        "operationId": "getAlbumEmbed",
+        "summary": "Page embedding gallery opened at album, framed by rich oEmbed of album",
+        "responses": {
+          "200": {"description": "Embed page", "content": {"text/html": {"schema": {"type": "string"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/feed.atom": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}],
+      "get": {
+        "operationId": "getAlbumAtom",
+        "summary": "Atom feed of new images of album, with thumbnails as enclosures",
+        "description": "Newest 50 entries. Unlisted albums are omitted. Responds 304 to If-None-Match and If-Modified-Since.",
+        "responses": {
+          "200": {"description": "Atom feed", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}, "Last-Modified": {"$ref": "#/components/headers/LastModified"}}, "content": {"application/atom+xml": {"schema": {"type": "string"}}}},
+          "304": {"description": "Not modified"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/feed.rss": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}],
+      "get": {
+        "operationId": "getAlbumRSS",
+        "summary": "RSS feed of new images of album, with thumbnails as enclosures",
+        "description": "Newest 50 entries. Unlisted albums are omitted. Responds 304 to If-None-Match and If-Modified-Since.",
+        "responses": {
+          "200": {"description": "RSS 2.0 feed", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}, "Last-Modified": {"$ref": "#/components/headers/LastModified"}}, "content": {"application/rss+xml": {"schema": {"type": "string"}}}},
+          "304": {"description": "Not modified"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/manifest.json": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}],
+      "get": {
+        "operationId": "getAlbumManifest",
+        "summary": "IIIF Presentation API 3.0 manifest of album",
+        "description": "Each image is a canvas painted by its IIIF image service. Sent as JSON-LD when Accept lists application/ld+json.",
+        "responses": {
+          "200": {"description": "Manifest", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"type": "object"}}, "application/ld+json": {"schema": {"type": "object"}}}},
+          "304": {"description": "Not modified"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/images": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}],
+      "get": {
+        "operationId": "listImages",
+        "summary": "List images",
+        "parameters": [{"$ref": "#/components/parameters/limit"}, {"$ref": "#/components/parameters/after"}],
+        "responses": {
+          "200": {"description": "Images, wrapped with cursor when paged", "content": {"application/json": {"schema": {"oneOf": [{"type": "array", "items": {"$ref": "#/components/schemas/Image"}}, {"$ref": "#/components/schemas/ImagePage"}]}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "addImage",
+        "summary": "Upload image",
+        "description": "With processing workers configured, image is rendered in background. Until then its status is pending, and image data responds 409. Near-duplicates of image in the same gallery are listed, and warned by Warning header.",
+        "requestBody": {"required": true, "content": {"image/*": {"schema": {"type": "string", "format": "binary"}}}},
+        "responses": {
+          "201": {"description": "Created image", "headers": {"Location": {"$ref": "#/components/headers/Location"}, "Warning": {"$ref": "#/components/headers/Warning"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/UploadedImage"}}}},
+          "202": {"description": "Stored image, queued for processing", "headers": {"Location": {"$ref": "#/components/headers/Location"}, "Warning": {"$ref": "#/components/headers/Warning"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/UploadedImage"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"}],
+      "get": {
+        "operationId": "getImage",
+        "summary": "Get image file, or metadata of image if Accept header includes application/json",
+        "parameters": [
+          {"name": "thumb", "in": "query", "description": "Return thumbnail when non-empty", "schema": {"type": "string"}},
+          {"name": "unwatermarked", "in": "query", "description": "Return display image or thumbnail without watermark of gallery when non-empty. Requires admin permission.", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "JPEG image, or metadata with ETag", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"image/jpeg": {"schema": {"type": "string", "format": "binary"}}, "application/json": {"schema": {"$ref": "#/components/schemas/Image"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "setImageDescription",
+        "summary": "Set image description",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"type": "object", "properties": {"description": {"type": "string"}}}}}},
+        "responses": {
+          "200": {"description": "Updated image", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Image"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "operationId": "patchImage",
+        "summary": "Update given fields of image",
+        "description": "JSON merge patch. Absent fields are left unchanged, and metadata keys set to null are removed.",
+        "parameters": [{"$ref": "#/components/parameters/ifMatch"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ImagePatch"}}}},
+        "responses": {
+          "200": {"description": "Updated image", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Image"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "operationId": "replaceImage",
+        "summary": "Replace every editable field of image",
+        "description": "Absent fields are reset.",
+        "parameters": [{"$ref": "#/components/parameters/ifMatch"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ImagePatch"}}}},
+        "responses": {
+          "200": {"description": "Updated image", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Image"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "operationId": "deleteImage",
+        "summary": "Delete image",
+        "responses": {
+          "200": {"description": "Deleted image", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Image"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}/tags": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"}],
+      "get": {
+        "operationId": "getImageTags",
+        "summary": "Get tags of image",
+        "responses": {
+          "200": {"description": "Tags", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Tags"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "addImageTags",
+        "summary": "Add tags to image",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"type": "object", "properties": {"tags": {"$ref": "#/components/schemas/Tags"}}}}}},
+        "responses": {
+          "200": {"description": "Resulting tags", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Tags"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}/tag/{tag}": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"}, {"$ref": "#/components/parameters/tag"}],
+      "delete": {
+        "operationId": "removeImageTag",
+        "summary": "Remove tag from image",
+        "responses": {
+          "200": {"description": "Remaining tags", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Tags"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}/edits": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"}],
+      "get": {
+        "operationId": "getImageEdits",
+        "summary": "Get edit list of image",
+        "responses": {
+          "200": {"description": "Edits", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Edits"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "operationId": "setImageEdits",
+        "summary": "Replace edit list of image",
+        "description": "Display image, thumbnail and tiles are rendered again from the original with edits applied, by queued job if processing is asynchronous. Original is kept unmodified. Images stored before originals were kept respond 409.",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Edits"}}}},
+        "responses": {
+          "200": {"description": "Image", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Image"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "operationId": "revertImage",
+        "summary": "Revert image to its original",
+        "responses": {
+          "200": {"description": "Image", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Image"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}/tiles.dzi": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"}],
+      "get": {
+        "operationId": "getDeepZoom",
+        "summary": "Deep Zoom (DZI) descriptor of image",
+        "description": "Tile pyramid is generated at upload for images larger than configured threshold. Sent as JSON when Accept lists application/json. Galleries with watermark serve watermarked tiles only, which are absent until image is watermarked.",
+        "responses": {
+          "200": {"description": "Descriptor", "content": {"application/xml": {"schema": {"type": "string"}}, "application/json": {"schema": {"$ref": "#/components/schemas/DeepZoom"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "operationId": "generateTiles",
+        "summary": "Generate tile pyramid of image, replacing existing one",
+        "description": "Image is rendered again from its original with edits applied.",
+        "responses": {
+          "200": {"description": "Generated tile pyramid", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DeepZoom"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}/tiles_files/{level}/{col}_{row}.jpg": {
+      "parameters": [
+        {"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"},
+        {"name": "level", "in": "path", "required": true, "description": "Level 0 is single pixel", "schema": {"type": "integer", "minimum": 0}},
+        {"name": "col", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 0}},
+        {"name": "row", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 0}}
+      ],
+      "get": {
+        "operationId": "getTile",
+        "summary": "Deep zoom tile of image, watermarked in galleries with watermark",
+        "responses": {
+          "200": {"description": "Tile", "content": {"image/jpeg": {"schema": {"type": "string", "format": "binary"}}}},
+          "304": {"description": "Not modified"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}/iiif": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"}],
+      "get": {
+        "operationId": "getIIIFService",
+        "summary": "IIIF image service base URI",
+        "responses": {
+          "303": {"description": "Redirect to info.json"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}/iiif/info.json": {
+      "parameters": [{"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"}],
+      "get": {
+        "operationId": "getIIIFInfo",
+        "summary": "IIIF Image API 3.0 image information",
+        "description": "Sent as JSON-LD when Accept lists application/ld+json.",
+        "responses": {
+          "200": {"description": "Image information", "headers": {"ETag": {"$ref": "#/components/headers/ETag"}}, "content": {"application/json": {"schema": {"type": "object"}}, "application/ld+json": {"schema": {"type": "object"}}}},
+          "304": {"description": "Not modified"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/{gid}/album/{aid}/image/{iid}/iiif/{region}/{size}/{rotation}/{quality}.{format}": {
+      "parameters": [
+        {"$ref": "#/components/parameters/gid"}, {"$ref": "#/components/parameters/aid"}, {"$ref": "#/components/parameters/iid"},
+        {"name": "region", "in": "path", "required": true, "description": "full, square, x,y,w,h or pct:x,y,w,h", "schema": {"type": "string"}},
+        {"name": "size", "in": "path", "required": true, "description": "max, w,, ,h, pct:n, w,h or !w,h, prefixed with ^ to allow upscaling up to size of image. Sizes over maxArea of image information are rejected", "schema": {"type": "string"}},
+        {"name": "rotation", "in": "path", "required": true, "description": "Multiple of 90 degrees clockwise, prefixed with ! to mirror", "schema": {"type": "string"}},
+        {"name": "quality", "in": "path", "required": true, "schema": {"type": "string", "enum": ["default", "color", "gray", "bitonal"]}},
+        {"name": "format", "in": "path", "required": true, "schema": {"type": "string", "enum": ["jpg", "png", "gif"]}}
+      ],
+      "get": {
+        "operationId": "getIIIFImage",
+        "summary": "IIIF Image API 3.0 image request",
+        "description": "Arbitrary rotation and formats other than jpg, png and gif respond 501.",
+        "responses": {
+          "200": {"description": "Image", "content": {"image/jpeg": {"schema": {"type": "string", "format": "binary"}}, "image/png": {"schema": {"type": "string", "format": "binary"}}, "image/gif": {"schema": {"type": "string", "format": "binary"}}}},
+          "304": {"description": "Not modified"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "gid": {"name": "gid", "in": "path", "required": true, "schema": {"$ref": "#/components/schemas/Id"}},
+      "aid": {"name": "aid", "in": "path", "required": true, "schema": {"$ref": "#/components/schemas/Id"}},
+      "iid": {"name": "iid", "in": "path", "required": true, "schema": {"$ref": "#/components/schemas/Id"}},
+      "uid": {"name": "uid", "in": "path", "required": true, "schema": {"type": "string"}},
+      "wid": {"name": "wid", "in": "path", "required": true, "schema": {"$ref": "#/components/schemas/Id"}},
+      "jid": {"name": "jid", "in": "path", "required": true, "schema": {"$ref": "#/components/schemas/Id"}},
+      "tag": {"name": "tag", "in": "path", "required": true, "schema": {"type": "string"}},
+      "limit": {"name": "limit", "in": "query", "description": "Page size. Response is wrapped with cursor when limit or after is given.", "schema": {"type": "integer", "minimum": 1, "maximum": 1000, "default": 100}},
+      "after": {"name": "after", "in": "query", "description": "Cursor returned as next by previous page. Cursors are opaque, as they hold order and id of last item.", "schema": {"type": "string"}},
+      "ifMatch": {"name": "If-Match", "in": "header", "description": "Fail with 412 unless entity still has one of these ETags", "schema": {"type": "string"}}
+    },
+    "headers": {
+      "Location": {"description": "Path of created entity", "schema": {"type": "string"}},
+      "ETag": {"description": "Entity tag for If-Match", "schema": {"type": "string"}},
+      "LastModified": {"description": "Time of newest entry", "schema": {"type": "string"}},
+      "Warning": {"description": "Warning with code 299, such as near-duplicates of uploaded image", "schema": {"type": "string"}}
+    },
+    "responses": {
+      "Error": {"description": "Error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+    },
+    "schemas": {
+      "Id": {"type": "integer", "format": "int64", "minimum": 0},
+      "Title": {"type": "object", "properties": {"title": {"type": "string"}}},
+      "Tags": {"type": "array", "items": {"type": "string"}},
+      "Visibility": {"type": "string", "enum": ["public", "unlisted"], "description": "Unlisted entities are omitted from listings for users without role on their gallery, except admins. Absent means public."},
+      "Metadata": {"type": "object", "additionalProperties": {"type": "string"}, "maxProperties": 32},
+      "MetadataPatch": {"type": "object", "additionalProperties": {"type": "string", "nullable": true}},
+      "Error": {
+        "type": "object",
+        "required": ["status", "code", "message"],
+        "properties": {"status": {"type": "integer"}, "code": {"type": "string"}, "message": {"type": "string"}}
+      },
+      "Gallery": {
+        "type": "object",
+        "properties": {
+          "id": {"$ref": "#/components/schemas/Id"},
+          "title": {"type": "string"},
+          "owner": {"type": "string"},
+          "visibility": {"$ref": "#/components/schemas/Visibility"},
+          "order": {"type": "integer", "description": "Listings are sorted by order, then id"},
+          "metadata": {"$ref": "#/components/schemas/Metadata"}
+        }
+      },
+      "GalleryPatch": {
+        "type": "object",
+        "properties": {
+          "title": {"type": "string"},
+          "visibility": {"$ref": "#/components/schemas/Visibility"},
+          "order": {"type": "integer"},
+          "metadata": {"$ref": "#/components/schemas/MetadataPatch"}
+        }
+      },
+      "Album": {
+        "type": "object",
+        "properties": {
+          "id": {"$ref": "#/components/schemas/Id"},
+          "title": {"type": "string"},
+          "cover": {"$ref": "#/components/schemas/Id"},
+          "owner": {"type": "string"},
+          "smart": {"type": "boolean"},
+          "query": {"$ref": "#/components/schemas/SmartQuery"},
+          "visibility": {"$ref": "#/components/schemas/Visibility"},
+          "order": {"type": "integer", "description": "Listings are sorted by order, then id"},
+          "metadata": {"$ref": "#/components/schemas/Metadata"}
+        }
+      },
+      "AlbumPatch": {
+        "type": "object",
+        "properties": {
+          "title": {"type": "string"},
+          "cover": {"$ref": "#/components/schemas/Id"},
+          "visibility": {"$ref": "#/components/schemas/Visibility"},
+          "order": {"type": "integer"},
+          "metadata": {"$ref": "#/components/schemas/MetadataPatch"},
+          "query": {"$ref": "#/components/schemas/SmartQuery"}
+        }
+      },
+      "AlbumInput": {
+        "type": "object",
+        "properties": {"title": {"type": "string"}, "query": {"$ref": "#/components/schemas/SmartQuery"}}
+      },
+      "SmartQuery": {
+        "type": "object",
+        "properties": {
+          "tags": {"$ref": "#/components/schemas/Tags"},
+          "since": {"type": "string", "format": "date-time"},
+          "until": {"type": "string", "format": "date-time"},
+          "camera": {"type": "string"},
+          "galleries": {"type": "array", "description": "Galleries to search, defaulting to gallery of the smart album. Requires viewer role on each other gallery. Unlisted albums of other galleries are not searched.", "items": {"$ref": "#/components/schemas/Id"}}
+        }
+      },
+      "Image": {
+        "type": "object",
+        "properties": {
+          "id": {"$ref": "#/components/schemas/Id"},
+          "galleryId": {"$ref": "#/components/schemas/Id"},
+          "albumId": {"$ref": "#/components/schemas/Id"},
+          "description": {"type": "string"},
+          "owner": {"type": "string"},
+          "tags": {"$ref": "#/components/schemas/Tags"},
+          "capturedAt": {"type": "string", "format": "date-time"},
+          "camera": {"type": "string"},
+          "order": {"type": "integer", "description": "Listings are sorted by order, then id"},
+          "metadata": {"$ref": "#/components/schemas/Metadata"},
+          "tiled": {"type": "boolean", "description": "Deep zoom tiles are available"},
+          "status": {"type": "string", "enum": ["pending", "failed"], "description": "Absent once image is processed"},
+          "width": {"type": "integer", "description": "Width of uploaded image, as edited"},
+          "height": {"type": "integer", "description": "Height of uploaded image, as edited"},
+          "files": {"$ref": "#/components/schemas/Files"},
+          "blurHash": {"type": "string", "description": "BlurHash of 4x3 components"},
+          "lqip": {"type": "string", "format": "uri", "description": "Data URI of tiny JPEG preview"},
+          "color": {"type": "string", "pattern": "^#[0-9a-f]{6}$", "description": "Dominant color"},
+          "edits": {"$ref": "#/components/schemas/Edits"}
+        }
+      },
+      "Edits": {
+        "type": "array",
+        "description": "Edits applied in order to original of image",
+        "maxItems": 16,
+        "items": {"$ref": "#/components/schemas/Edit"}
+      },
+      "Edit": {
+        "type": "object",
+        "required": ["op"],
+        "properties": {
+          "op": {"type": "string", "enum": ["rotate", "flip", "crop"]},
+          "angle": {"type": "integer", "enum": [90, 180, 270], "description": "Clockwise rotation, required by rotate"},
+          "direction": {"type": "string", "enum": ["horizontal", "vertical"], "description": "Required by flip"},
+          "x": {"type": "integer", "minimum": 0, "description": "Crop rectangle in pixels of image edited by preceding edits"},
+          "y": {"type": "integer", "minimum": 0},
+          "width": {"type": "integer", "minimum": 1},
+          "height": {"type": "integer", "minimum": 1}
+        }
+      },
+      "Files": {
+        "type": "object",
+        "description": "Stored files of image. Renditions are those served, watermarked if gallery has watermark, and are absent until image is processed or watermarked.",
+        "properties": {
+          "original": {"$ref": "#/components/schemas/File"},
+          "display": {"$ref": "#/components/schemas/File"},
+          "thumbnail": {"$ref": "#/components/schemas/File"}
+        }
+      },
+      "File": {
+        "type": "object",
+        "properties": {
+          "width": {"type": "integer"},
+          "height": {"type": "integer"},
+          "bytes": {"type": "integer"},
+          "format": {"type": "string", "example": "jpeg"},
+          "mimeType": {"type": "string", "example": "image/jpeg"}
+        }
+      },
+      "Watermark": {
+        "type": "object",
+        "description": "Watermark drawn on display images, thumbnails and tiles of gallery. Either logo or text is required.",
+        "required": ["position", "opacity", "scale"],
+        "properties": {
+          "text": {"type": "string"},
+          "logo": {"type": "string", "format": "byte", "description": "Base64 encoded PNG"},
+          "position": {"type": "string", "enum": ["top-left", "top-right", "bottom-left", "bottom-right", "center"]},
+          "opacity": {"type": "number", "minimum": 0, "exclusiveMinimum": true, "maximum": 1},
+          "scale": {"type": "number", "minimum": 0, "exclusiveMinimum": true, "maximum": 1, "description": "Width of watermark relative to image"}
+        }
+      },
+      "ImagePatch": {
+        "type": "object",
+        "properties": {
+          "description": {"type": "string"},
+          "order": {"type": "integer"},
+          "metadata": {"$ref": "#/components/schemas/MetadataPatch"}
+        }
+      },
+      "Batch": {
+        "type": "object",
+        "required": ["operations"],
+        "properties": {"operations": {"type": "array", "minItems": 1, "maxItems": 500, "items": {"$ref": "#/components/schemas/BatchOperation"}}}
+      },
+      "BatchOperation": {
+        "type": "object",
+        "required": ["op", "albumId", "imageId"],
+        "properties": {
+          "op": {"type": "string", "enum": ["delete", "describe", "tag", "untag", "move", "reorder"]},
+          "albumId": {"$ref": "#/components/schemas/Id"},
+          "imageId": {"$ref": "#/components/schemas/Id"},
+          "description": {"type": "string", "description": "Required by describe"},
+          "tags": {"$ref": "#/components/schemas/Tags"},
+          "toAlbumId": {"$ref": "#/components/schemas/Id"},
+          "order": {"type": "integer", "description": "Required by reorder"}
+        }
+      },
+      "BatchResult": {
+        "type": "object",
+        "properties": {
+          "index": {"type": "integer"},
+          "status": {"type": "string", "enum": ["ok", "failed", "rolled_back", "skipped"]},
+          "image": {"$ref": "#/components/schemas/Image"},
+          "error": {"$ref": "#/components/schemas/Error"}
+        }
+      },
+      "UploadedImage": {
+        "allOf": [
+          {"$ref": "#/components/schemas/Image"},
+          {"type": "object", "properties": {"duplicates": {"type": "array", "items": {"$ref": "#/components/schemas/Duplicate"}}}}
+        ]
+      },
+      "Duplicate": {
+        "type": "object",
+        "properties": {
+          "galleryId": {"$ref": "#/components/schemas/Id"},
+          "albumId": {"$ref": "#/components/schemas/Id"},
+          "imageId": {"$ref": "#/components/schemas/Id"},
+          "distance": {"description": "Number of differing bits of perceptual hashes", "type": "integer"}
+        }
+      },
+      "BatchFailure": {
+        "allOf": [
+          {"$ref": "#/components/schemas/Error"},
+          {"type": "object", "properties": {"index": {"type": "integer"}, "results": {"type": "array", "items": {"$ref": "#/components/schemas/BatchResult"}}}}
+        ]
+      },
+      "GalleryPage": {
+        "type": "object",
+        "properties": {"items": {"type": "array", "items": {"$ref": "#/components/schemas/Gallery"}}, "next": {"type": "string"}}
+      },
+      "AlbumPage": {
+        "type": "object",
+        "properties": {"items": {"type": "array", "items": {"$ref": "#/components/schemas/Album"}}, "next": {"type": "string"}}
+      },
+      "ImagePage": {
+        "type": "object",
+        "properties": {"items": {"type": "array", "items": {"$ref": "#/components/schemas/Image"}}, "next": {"type": "string"}}
+      },
+      "Grant": {
+        "type": "object",
+        "properties": {
+          "userId": {"type": "string"},
+          "role": {"type": "string", "enum": ["viewer", "contributor", "editor", "owner"]},
+          "albums": {"type": "array", "items": {"$ref": "#/components/schemas/Id"}}
+        }
+      },
+      "TagCount": {
+        "type": "object",
+        "properties": {"tag": {"type": "string"}, "count": {"type": "integer"}}
+      },
+      "DeepZoom": {
+        "type": "object",
+        "properties": {
+          "tileSize": {"type": "integer"},
+          "overlap": {"type": "integer"},
+          "format": {"type": "string"},
+          "width": {"type": "integer"},
+          "height": {"type": "integer"}
+        }
+      },
+      "Job": {
+        "type": "object",
+        "properties": {
+          "id": {"$ref": "#/components/schemas/Id"},
+          "kind": {"type": "string", "enum": ["image.process", "images.regenerate", "images.watermark"]},
+          "galleryId": {"$ref": "#/components/schemas/Id"},
+          "albumId": {"$ref": "#/components/schemas/Id"},
+          "imageId": {"$ref": "#/components/schemas/Id"},
+          "status": {"type": "string", "enum": ["queued", "running", "failed"]},
+          "attempts": {"type": "integer"},
+          "error": {"type": "string"},
+          "createdAt": {"type": "string", "format": "date-time"},
+          "updatedAt": {"type": "string", "format": "date-time"},
+          "progress": {"$ref": "#/components/schemas/Progress"},
+          "cursor": {"description": "Gallery, album and image id of the last image job worked on", "type": "array", "items": {"$ref": "#/components/schemas/Id"}}
+        }
+      },
+      "Progress": {
+        "type": "object",
+        "properties": {
+          "total": {"type": "integer"},
+          "processed": {"type": "integer"},
+          "skipped": {"type": "integer"},
+          "failed": {"type": "integer"}
+        }
+      },
+      "OEmbed": {
+        "type": "object",
+        "properties": {
+          "version": {"type": "string", "enum": ["1.0"]},
+          "type": {"type": "string", "enum": ["photo", "rich"]},
+          "title": {"type": "string"},
+          "author_name": {"type": "string"},
+          "provider_name": {"type": "string"},
+          "url": {"type": "string", "description": "Image URL of photo type"},
+          "html": {"type": "string", "description": "Embed HTML of rich type"},
+          "width": {"type": "integer"},
+          "height": {"type": "integer"},
+          "thumbnail_url": {"type": "string"},
+          "thumbnail_width": {"type": "integer"},
+          "thumbnail_height": {"type": "integer"}
+        }
+      },
+      "SearchResult": {
+        "type": "object",
+        "properties": {
+          "type": {"type": "string", "enum": ["gallery", "album", "image"]},
+          "galleryId": {"$ref": "#/components/schemas/Id"},
+          "albumId": {"$ref": "#/components/schemas/Id"},
+          "imageId": {"$ref": "#/components/schemas/Id"},
+          "text": {"type": "string"},
+          "tags": {"$ref": "#/components/schemas/Tags"},
+          "score": {"type": "number"}
+        }
+      },
+      "AuditEntry": {
+        "type": "object",
+        "properties": {
+          "id": {"$ref": "#/components/schemas/Id"},
+          "timestamp": {"type": "string", "format": "date-time"},
+          "actor": {"type": "string"},
+          "action": {"type": "string"},
+          "galleryId": {"$ref": "#/components/schemas/Id"},
+          "albumId": {"$ref": "#/components/schemas/Id"},
+          "imageId": {"$ref": "#/components/schemas/Id"},
+          "userId": {"type": "string"},
+          "before": {"type": "string", "description": "JSON of entity before mutation, or tags of image for tag actions"},
+          "after": {"type": "string", "description": "JSON of entity after mutation, or tags of image for tag actions"}
+        }
+      },
+      "Event": {
+        "type": "object",
+        "properties": {
+          "id": {"$ref": "#/components/schemas/Id"},
+          "timestamp": {"type": "string", "format": "date-time"},
+          "entity": {"type": "string", "enum": ["gallery", "album", "image", "grant"]},
+          "action": {"type": "string", "enum": ["create", "update", "delete"]},
+          "galleryId": {"$ref": "#/components/schemas/Id"},
+          "albumId": {"$ref": "#/components/schemas/Id"},
+          "imageId": {"$ref": "#/components/schemas/Id"},
+          "userId": {"type": "string"}
+        }
+      },
+      "Webhook": {
+        "type": "object",
+        "required": ["url", "events"],
+        "properties": {
+          "id": {"$ref": "#/components/schemas/Id"},
+          "url": {"type": "string", "format": "uri"},
+          "events": {"type": "array", "items": {"type": "string"}, "description": "Audit actions such as image.create, image.* for every image action, or *"},
+          "secret": {"type": "string"},
+          "createdAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Delivery": {
+        "type": "object",
+        "properties": {
+          "id": {"$ref": "#/components/schemas/Id"},
+          "webhookId": {"$ref": "#/components/schemas/Id"},
+          "event": {"type": "string"},
+          "payload": {"$ref": "#/components/schemas/AuditEntry"},
+          "attempts": {"type": "integer"},
+          "nextAttempt": {"type": "string", "format": "date-time"},
+          "lastStatus": {"type": "integer"},
+          "lastError": {"type": "string"},
+          "failed": {"type": "boolean", "description": "Set once retries are exhausted"}
+        }
+      },
+      "AuditLog": {
+        "type": "object",
+        "properties": {
+          "entries": {"type": "array", "items": {"$ref": "#/components/schemas/AuditEntry"}},
+          "next": {"$ref": "#/components/schemas/Id"}
+        }
+      }
+    }
+  }
+}
+`
+
+// GET: get OpenAPI document of this API
+func (a *API) openAPIHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	_, _ = res.Write([]byte(openAPISpec))
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+var openAPIMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// createFixtureAPI returns API holding gallery 1, album 1 and image 1 tagged "a".
+func createFixtureAPI() *mux.Router {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for _, req := range []struct {
+		target string
+		body   []byte
+	}{
+		{"/", []byte(`{"title":"hello"}`)},
+		{"/1/albums", []byte(`{"title":"hello"}`)},
+		{"/1/album/1/images", createTestImage().Bytes()},
+		{"/1/album/1/image/1/tags", []byte(`{"tags":["a"]}`)},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, newAuthenticatedRequest("POST", req.target, bytes.NewReader(req.body)))
+		if res.Code >= 300 {
+			panic(res.Body.String())
+		}
+	}
+
+	return m
+}
+
+func fixturePath(template string) string {
+	return strings.NewReplacer("{gid}", "1", "{aid}", "1", "{iid}", "1", "{uid}", "hello", "{wid}", "1", "{jid}", "1", "{tag}", "a",
+		"{region}", "full", "{size}", "max", "{rotation}", "0", "{quality}", "default", "{format}", "jpg",
+		"{level}", "0", "{col}", "0", "{row}", "0").Replace(template)
+}
+
+func TestAPI_OpenAPI(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err := json.Unmarshal([]byte(openAPISpec), &spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := createFixtureAPI()
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/openapi.json", nil))
+	if res.Code != 200 || res.Body.String() != openAPISpec {
+		t.Error("openapi.json not served:", res.Code)
+	}
+
+	var routes []string
+	err = m.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		routes = append(routes, tpl)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for p := range spec.Paths {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(routes)
+	sort.Strings(paths)
+	if strings.Join(routes, "\n") != strings.Join(paths, "\n") {
+		t.Error("routes not matches spec:", routes, "!=", paths)
+	}
+
+	for _, p := range paths {
+		var allow []string
+		for _, method := range openAPIMethods {
+			if _, ok := spec.Paths[p][strings.ToLower(method)]; ok {
+				allow = append(allow, method)
+			}
+		}
+
+		for _, method := range openAPIMethods {
+			_, documented := spec.Paths[p][strings.ToLower(method)]
+
+			// documented methods may mutate fixture
+			router := m
+			if documented {
+				router = createFixtureAPI()
+			}
+
+			res := httptest.NewRecorder()
+			router.ServeHTTP(res, newAuthenticatedRequest(method, fixturePath(p), bytes.NewReader(nil)))
+
+			if documented && res.Code == 405 {
+				t.Error(method, p, "documented but not allowed")
+			}
+			if !documented && res.Code != 405 {
+				t.Error(method, p, "allowed but not documented:", res.Code)
+			}
+			if res.Code == 405 && res.Header().Get("Allow") != strings.Join(allow, ", ") {
+				t.Error(method, p, "allow header not matches:", res.Header().Get("Allow"), "!=", allow)
+			}
+		}
+	}
+}
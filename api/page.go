@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// parsePage reads ?limit=&after= query.
+// paged is false when neither is given, and whole listing should be returned as before.
+func parsePage(req *http.Request) (page database.Page, paged bool, err error) {
+	q := req.URL.Query()
+	limit, after := q.Get("limit"), q.Get("after")
+	if limit == "" && after == "" {
+		return database.Page{}, false, nil
+	}
+
+	page.Limit = defaultPageLimit
+	if limit != "" {
+		l, err := atou(limit)
+		if err != nil || l == 0 || l > maxPageLimit {
+			return page, true, ErrInvalidPage
+		}
+		page.Limit = int(l)
+	}
+
+	if after != "" {
+		page.After, err = database.ParseCursor(after)
+		if err != nil {
+			return page, true, ErrInvalidPage
+		}
+	}
+
+	return page, true, nil
+}
+
+// writePage writes items, wrapped with next cursor when paged.
+func writePage(res http.ResponseWriter, items interface{}, next database.Cursor, paged bool) {
+	if !paged {
+		writeJSON(res, http.StatusOK, items)
+		return
+	}
+
+	var result struct {
+		Items interface{} `json:"items"`
+		Next  string      `json:"next,omitempty"`
+	}
+	result.Items = items
+	if next != (database.Cursor{}) {
+		result.Next = next.String()
+	}
+	writeJSON(res, http.StatusOK, result)
+}
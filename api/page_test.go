@@ -0,0 +1,56 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Pagination(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	type page struct {
+		Items interface{} `json:"items"`
+		Next  string      `json:"next,omitempty"`
+	}
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+		resp []byte
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, nil},
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"world"}`))), 201, nil},
+		{newAuthenticatedRequest("GET", "/?limit=1", nil), 200, mustMarshalJSON(page{Items: []database.Gallery{{Id: 1, Title: "hello", Owner: "hello"}}, Next: "1"})},
+		{newAuthenticatedRequest("GET", "/?limit=1&after=1", nil), 200, mustMarshalJSON(page{Items: []database.Gallery{{Id: 2, Title: "world", Owner: "hello"}}})},
+		{newAuthenticatedRequest("GET", "/?limit=0", nil), 400, nil},
+		{newAuthenticatedRequest("GET", "/?after=abc", nil), 400, nil},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, nil},
+		{newAuthenticatedRequest("GET", "/1/albums?after=0", nil), 200, mustMarshalJSON(page{Items: []database.Album{{Id: 1, Title: "hello", Owner: "hello"}}})},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201, nil},
+		{newAuthenticatedRequest("GET", "/1/album/1/images?limit=1&after=1", nil), 200, mustMarshalJSON(page{Items: []database.Image{processedTestImage(database.Image{Id: 2, Owner: "hello"})}})},
+		{newAuthenticatedRequest("GET", "/1/album/2/images?limit=1", nil), 404, nil},
+		{newAuthenticatedRequest("POST", "/1/album/2/images", createTestImage()), 404, nil},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code)
+			continue
+		}
+
+		if r.resp != nil && !bytes.Equal(res.Body.Bytes(), r.resp) {
+			t.Error(idx, "response not matches:", string(res.Body.Bytes()), "!=", string(r.resp))
+		}
+	}
+}
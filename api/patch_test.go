@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Patch(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	serve := func(method, target, body, ifMatch string) *httptest.ResponseRecorder {
+		req := newAuthenticatedRequest(method, target, bytes.NewReader([]byte(body)))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		return res
+	}
+
+	serve("POST", "/", `{"title":"hello"}`, "")
+
+	res := serve("GET", "/1", "", "")
+	tag := res.Header().Get("ETag")
+	if tag == "" {
+		t.Fatal("etag not set")
+	}
+
+	res = serve("PATCH", "/1", `{"order":2,"metadata":{"a":"1","b":"2"}}`, tag)
+	if res.Code != 200 {
+		t.Fatal("code not matches:", res.Code, res.Body.String())
+	}
+	var g database.Gallery
+	_ = json.NewDecoder(res.Body).Decode(&g)
+	if !reflect.DeepEqual(g, database.Gallery{Id: 1, Title: "hello", Owner: "hello", Order: 2, Metadata: map[string]string{"a": "1", "b": "2"}}) {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+	if res.Header().Get("ETag") == tag {
+		t.Error("etag not changed")
+	}
+
+	// stale etag
+	res = serve("PATCH", "/1", `{"title":"world"}`, tag)
+	if res.Code != 412 {
+		t.Error("code not matches:", res.Code, "!=", 412)
+	}
+
+	res = serve("PATCH", "/1", `{"title":"world","metadata":{"a":null}}`, "*")
+	g = database.Gallery{}
+	_ = json.NewDecoder(res.Body).Decode(&g)
+	if res.Code != 200 || g.Title != "world" || g.Order != 2 || !reflect.DeepEqual(g.Metadata, map[string]string{"b": "2"}) {
+		t.Errorf("Assertion Failed: %d %+v", res.Code, g)
+	}
+
+	res = serve("PUT", "/1", `{"title":"replaced","visibility":"unlisted"}`, "")
+	g = database.Gallery{}
+	_ = json.NewDecoder(res.Body).Decode(&g)
+	if res.Code != 200 || !reflect.DeepEqual(g, database.Gallery{Id: 1, Title: "replaced", Owner: "hello", Visibility: database.VisibilityUnlisted}) {
+		t.Errorf("Assertion Failed: %d %+v", res.Code, g)
+	}
+
+	// unlisted gallery is listed to admins and users with role on it only
+	for _, req := range []*http.Request{httptest.NewRequest("GET", "/", nil), newUserRequest("stranger", "GET", "/", nil)} {
+		res = httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		if res.Body.String() != "[]\n" {
+			t.Error("unlisted gallery listed:", res.Body.String())
+		}
+	}
+	for _, req := range []*http.Request{newAuthenticatedRequest("GET", "/", nil), newAdminRequest("GET", "/", nil)} {
+		res = httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		if res.Body.String() == "[]\n" {
+			t.Error("unlisted gallery not listed to user")
+		}
+	}
+
+	serve("POST", "/1/albums", `{"title":"album"}`, "")
+	serve("POST", "/1/album/1/images", createTestImage().String(), "")
+
+	res = serve("PATCH", "/1/album/1", `{"cover":5}`, "")
+	if res.Code != 400 {
+		t.Error("code not matches:", res.Code, "!=", 400)
+	}
+
+	req := newAuthenticatedRequest("GET", "/1/album/1/image/1", nil)
+	req.Header.Set("Accept", "application/json")
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	tag = res.Header().Get("ETag")
+	if res.Code != 200 || res.Header().Get("Content-Type") != "application/json" || tag == "" {
+		t.Error("image info not served:", res.Code, res.Header())
+	}
+
+	res = serve("PATCH", "/1/album/1/image/1", `{"description":"desc"}`, tag)
+	var i database.Image
+	_ = json.NewDecoder(res.Body).Decode(&i)
+	if res.Code != 200 || i.Description != "desc" {
+		t.Errorf("Assertion Failed: %d %+v", res.Code, i)
+	}
+
+	res = serve("DELETE", "/search", "", "")
+	if res.Code != 405 || res.Header().Get("Allow") != "GET" {
+		t.Error("allow not matches:", res.Code, res.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/dfkdream/hugocms/plugin"
+	"github.com/gorilla/mux"
+)
+
+// adminPermission grants RoleOwner on every gallery.
+const adminPermission = "gallery:admin"
+
+type contextKey string
+
+var contextKeyGrant = contextKey("grant")
+
+// grantOf resolves role of requesting user on gallery.
+// Users without grant have no role, so galleries without any grant are managed by admins only.
+func (a *API) grantOf(req *http.Request, gid uint64) (database.Grant, error) {
+	u := plugin.GetUser(req)
+	if u == nil {
+		return database.Grant{Role: database.RoleNone}, nil
+	}
+
+	if u.HasPermission(adminPermission) {
+		return database.Grant{UserId: u.Id, Role: database.RoleOwner}, nil
+	}
+
+	g, err := a.db.GetGrant(gid, u.Id)
+	if err == database.ErrGrantNotFound {
+		return database.Grant{UserId: u.Id, Role: database.RoleNone}, nil
+	}
+	return g, err
+}
+
+// getGrant returns grant resolved by authorization middleware
+func getGrant(req *http.Request) database.Grant {
+	if g, ok := req.Context().Value(contextKeyGrant).(database.Grant); ok {
+		return g
+	}
+	return database.Grant{Role: database.RoleNone}
+}
+
+// authorize rejects request with 403 unless user has role on gallery.
+func authorize(res http.ResponseWriter, req *http.Request, role database.Role) bool {
+	if !getGrant(req).Role.Includes(role) {
+		writeError(res, ErrForbidden)
+		return false
+	}
+	return true
+}
+
+// authMiddleware rejects non-GET requests from anonymous users,
+// and from users without any role on requested gallery.
+func (a *API) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" {
+			if plugin.GetUser(req) == nil {
+				writeError(res, ErrLoginRequired)
+				return
+			}
+		}
+
+		if v, ok := mux.Vars(req)["gid"]; ok {
+			gid, err := atou(v)
+			if err != nil {
+				writeError(res, ErrInvalidId)
+				return
+			}
+
+			g, err := a.grantOf(req, gid)
+			if err != nil {
+				writeError(res, err)
+				return
+			}
+
+			if req.Method != "GET" && g.Role == database.RoleNone {
+				writeError(res, ErrForbidden)
+				return
+			}
+
+			req = req.WithContext(context.WithValue(req.Context(), contextKeyGrant, g))
+		}
+
+		next.ServeHTTP(res, req)
+	})
+}
+
+// GET: get grants
+// POST: create or replace grant
+func (a *API) grantsHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		if !authorize(res, req, database.RoleViewer) {
+			return
+		}
+
+		g, err := a.db.GetGrants(gid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		writeJSON(res, http.StatusOK, g)
+	case "POST":
+		if !authorize(res, req, database.RoleOwner) {
+			return
+		}
+
+		var values database.Grant
+
+		err := json.NewDecoder(req.Body).Decode(&values)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+
+		var before string
+		if g, err := a.db.GetGrant(gid, values.UserId); err == nil {
+			before = auditJSON(g)
+		}
+
+		err = a.db.SetGrant(gid, values)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		g, err := a.db.GetGrant(gid, values.UserId)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		a.audit(req, database.AuditEntry{Action: actionGrantSet, GalleryId: gid, UserId: values.UserId, Before: before, After: auditJSON(g)})
+		writeJSON(res, http.StatusOK, g)
+	default:
+		methodNotAllowed(res, "GET", "POST")
+	}
+}
+
+// GET: get grant
+// DELETE: delete grant
+func (a *API) grantHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	uid := vars["uid"]
+
+	switch req.Method {
+	case "GET":
+		if !authorize(res, req, database.RoleViewer) {
+			return
+		}
+
+		g, err := a.db.GetGrant(gid, uid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		writeJSON(res, http.StatusOK, g)
+	case "DELETE":
+		if !authorize(res, req, database.RoleOwner) {
+			return
+		}
+
+		before, err := a.db.GetGrant(gid, uid)
+		if err != nil && err != database.ErrGrantNotFound {
+			writeError(res, err)
+			return
+		}
+
+		err = a.db.DeleteGrant(gid, uid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		a.audit(req, database.AuditEntry{Action: actionGrantDelete, GalleryId: gid, UserId: uid, Before: auditJSON(before)})
+
+		writeJSON(res, http.StatusOK, before)
+	default:
+		methodNotAllowed(res, "GET", "DELETE")
+	}
+}
+
+// authorizeQuery rejects request with 403 unless user has role on every other gallery smart query q searches.
+func (a *API) authorizeQuery(res http.ResponseWriter, req *http.Request, gid uint64, q *database.SmartQuery) bool {
+	if q == nil {
+		return true
+	}
+	for _, id := range q.Galleries {
+		if id == gid {
+			continue
+		}
+		g, err := a.grantOf(req, id)
+		if err != nil {
+			writeError(res, err)
+			return false
+		}
+		if !g.Role.Includes(database.RoleViewer) {
+			writeError(res, ErrForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// canModifyImage allows editors, and contributors to modify their own uploads.
+func (a *API) canModifyImage(res http.ResponseWriter, req *http.Request, gid, aid, iid uint64) bool {
+	g := getGrant(req)
+	if g.Role.Includes(database.RoleEditor) {
+		return true
+	}
+
+	if g.CanContributeTo(aid) {
+		i, err := a.db.GetImageInfo(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
+			return false
+		}
+		if i.Owner == g.UserId {
+			return true
+		}
+	}
+
+	writeError(res, ErrForbidden)
+	return false
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/hugocms/plugin"
+	"github.com/dfkdream/hugocms/user"
+	"github.com/gorilla/mux"
+)
+
+func newUserRequest(uid, method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req = req.WithContext(context.WithValue(req.Context(), plugin.ContextKeyUser, &user.User{Id: uid, Username: uid}))
+	return req
+}
+
+func newAdminRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	return req.WithContext(context.WithValue(req.Context(), plugin.ContextKeyUser, &user.User{Id: "admin", Permissions: []string{adminPermission}}))
+}
+
+func TestAPI_Permissions(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+	}{
+		{newUserRequest("owner", "POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201},
+		{newUserRequest("owner", "POST", "/1/albums", bytes.NewReader([]byte(`{"title":"first"}`))), 201},
+		{newUserRequest("owner", "POST", "/1/albums", bytes.NewReader([]byte(`{"title":"second"}`))), 201},
+		{newUserRequest("stranger", "POST", "/1", bytes.NewReader([]byte(`{"title":"world"}`))), 403},
+		{newUserRequest("stranger", "POST", "/1/grants", bytes.NewReader([]byte(`{"userId":"stranger","role":"owner"}`))), 403},
+		{newUserRequest("owner", "POST", "/1/grants", bytes.NewReader([]byte(`{"userId":"contributor","role":"contributor","albums":[1]}`))), 200},
+		{newUserRequest("owner", "POST", "/1/grants", bytes.NewReader([]byte(`{"userId":"editor","role":"editor"}`))), 200},
+		{newUserRequest("contributor", "POST", "/1/album/1/images", createTestImage()), 201},
+		{newUserRequest("contributor", "POST", "/1/album/2/images", createTestImage()), 403},
+		{newUserRequest("contributor", "POST", "/1/album/1", bytes.NewReader([]byte(`{"title":"world"}`))), 403},
+		{newUserRequest("contributor", "POST", "/1/album/1/image/1", bytes.NewReader([]byte(`{"description":"mine"}`))), 200},
+		{newUserRequest("editor", "POST", "/1/album/1/images", createTestImage()), 201},
+		{newUserRequest("contributor", "DELETE", "/1/album/1/image/2", nil), 403},
+		{newUserRequest("editor", "DELETE", "/1/album/1/image/1", nil), 200},
+		{newUserRequest("editor", "DELETE", "/1", nil), 403},
+		{newUserRequest("editor", "DELETE", "/1/grant/owner", nil), 403},
+		{newUserRequest("owner", "DELETE", "/1/grant/owner", nil), 400},
+		{newUserRequest("owner", "DELETE", "/1/grant/editor", nil), 200},
+		{newUserRequest("editor", "POST", "/1", bytes.NewReader([]byte(`{"title":"world"}`))), 403},
+		{httptest.NewRequest("GET", "/1/album/1/images", nil), 200},
+		{newUserRequest("owner", "DELETE", "/1", nil), 200},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code)
+		}
+	}
+}
+
+func TestAPI_UngrantedGallery(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	// gallery without owner has no grants
+	if _, err := a.db.CreateGallery("hello", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+	}{
+		{newUserRequest("stranger", "POST", "/1/albums", bytes.NewReader([]byte(`{"title":"first"}`))), 403},
+		{newUserRequest("stranger", "POST", "/1/grants", bytes.NewReader([]byte(`{"userId":"stranger","role":"owner"}`))), 403},
+		{httptest.NewRequest("GET", "/1", nil), 200},
+		{newAdminRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"first"}`))), 201},
+		{newAdminRequest("POST", "/1/grants", bytes.NewReader([]byte(`{"userId":"owner","role":"owner"}`))), 200},
+		{newUserRequest("owner", "POST", "/1/albums", bytes.NewReader([]byte(`{"title":"second"}`))), 201},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// writeJSON writes v as JSON response with status.
+func writeJSON(res http.ResponseWriter, status int, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+
+	err := json.NewEncoder(res).Encode(v)
+	if err != nil {
+		// header is already written
+		log.Println(err)
+	}
+}
+
+// created writes 201 Created with created entity, and location of it.
+func (a *API) created(res http.ResponseWriter, location string, id uint64, v interface{}) {
+	res.Header().Set("Location", location)
+	if a.legacy {
+		a.respond(res, id, v)
+		return
+	}
+	writeJSON(res, http.StatusCreated, v)
+}
+
+// respond writes updated or deleted entity.
+// In legacy mode, only decimal id is written as plain text.
+func (a *API) respond(res http.ResponseWriter, id uint64, v interface{}) {
+	if a.legacy {
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = res.Write([]byte(strconv.FormatUint(id, 10)))
+		return
+	}
+	writeJSON(res, http.StatusOK, v)
+}
+
+// etag returns strong entity tag of JSON representation of v.
+func etag(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return fmt.Sprintf(`"%x"`, sum[:8])
+}
+
+// ifMatch returns ErrPrecondition unless If-Match header of request
+// is absent, "*", or lists entity tag of v.
+func ifMatch(req *http.Request, v interface{}) error {
+	h := req.Header.Get("If-Match")
+	if h == "" || strings.TrimSpace(h) == "*" {
+		return nil
+	}
+
+	current := etag(v)
+	for _, t := range strings.Split(h, ",") {
+		if strings.TrimSpace(t) == current {
+			return nil
+		}
+	}
+	return ErrPrecondition
+}
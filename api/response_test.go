@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Responses(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req      *http.Request
+		code     int
+		location string
+		resp     []byte
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, "/1", mustMarshalJSON(database.Gallery{Id: 1, Title: "hello", Owner: "hello"})},
+		{newAuthenticatedRequest("POST", "/1", bytes.NewReader([]byte(`{"title":"world"}`))), 200, "", mustMarshalJSON(database.Gallery{Id: 1, Title: "world", Owner: "hello"})},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"album"}`))), 201, "/1/album/1", mustMarshalJSON(database.Album{Id: 1, Title: "album", Owner: "hello"})},
+		{newAuthenticatedRequest("POST", "/1/album/1", bytes.NewReader([]byte(`{"title":"renamed"}`))), 200, "", mustMarshalJSON(database.Album{Id: 1, Title: "renamed", Owner: "hello"})},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201, "/1/album/1/image/1", mustMarshalJSON(processedTestImage(database.Image{Id: 1, Owner: "hello"}))},
+		{newAuthenticatedRequest("POST", "/1/album/1/image/1", bytes.NewReader([]byte(`{"description":"desc"}`))), 200, "", mustMarshalJSON(processedTestImage(database.Image{Id: 1, Description: "desc", Owner: "hello"}))},
+		{newAuthenticatedRequest("DELETE", "/1/album/1/image/1", nil), 200, "", mustMarshalJSON(processedTestImage(database.Image{Id: 1, Description: "desc", Owner: "hello"}))},
+		{newAuthenticatedRequest("DELETE", "/1/album/1", nil), 200, "", mustMarshalJSON(database.Album{Id: 1, Title: "renamed", Owner: "hello"})},
+		{newAuthenticatedRequest("DELETE", "/1", nil), 200, "", mustMarshalJSON(database.Gallery{Id: 1, Title: "world", Owner: "hello"})},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code)
+			continue
+		}
+
+		if l := res.Header().Get("Location"); l != r.location {
+			t.Error(idx, "location not matches:", l, "!=", r.location)
+		}
+
+		if !bytes.Equal(res.Body.Bytes(), r.resp) {
+			t.Error(idx, "response not matches:", res.Body.String(), "!=", string(r.resp))
+		}
+	}
+}
+
+func TestAPI_LegacyResponses(t *testing.T) {
+	a := New(createTestDB(), &config.Config{LegacyResponses: true})
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req  *http.Request
+		resp string
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), "1"},
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"world"}`))), "2"},
+		{newAuthenticatedRequest("POST", "/2/albums", bytes.NewReader([]byte(`{"title":"album"}`))), "1"},
+		{newAuthenticatedRequest("POST", "/2/album/1", bytes.NewReader([]byte(`{"title":"renamed"}`))), "1"},
+		{newAuthenticatedRequest("DELETE", "/2", nil), "2"},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != 200 {
+			t.Error(idx, "code not matches:", res.Code, "!=", 200)
+		}
+
+		if res.Body.String() != r.resp {
+			t.Error(idx, "response not matches:", res.Body.String(), "!=", r.resp)
+		}
+	}
+}
+
+// endpoints added after JSON responses reply with JSON even in legacy mode
+func TestAPI_LegacyResponsesJSON(t *testing.T) {
+	a := New(createTestDB(), &config.Config{LegacyResponses: true})
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("POST", "/webhooks", bytes.NewReader([]byte(`{"url":"http://example.com/hook","events":["*"]}`))))
+	if res.Code != 201 || res.Header().Get("Location") != "/webhook/1" {
+		t.Fatal("code not matches:", res.Code, res.Header())
+	}
+
+	var w database.Webhook
+	if err := json.Unmarshal(res.Body.Bytes(), &w); err != nil || w.Id != 1 || w.Secret == "" {
+		t.Errorf("Assertion Failed: %+v %v", w, err)
+	}
+
+	for _, r := range []struct {
+		target string
+		body   []byte
+	}{
+		{"/", []byte(`{"title":"hello"}`)},
+		{"/1/albums", []byte(`{"title":"hello"}`)},
+		{"/1/album/1/images", createTestImage().Bytes()},
+	} {
+		res = httptest.NewRecorder()
+		m.ServeHTTP(res, newAdminRequest("POST", r.target, bytes.NewReader(r.body)))
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("POST", "/jobs", bytes.NewReader([]byte(`{"kind":"images.regenerate","galleryId":1}`))))
+	var j database.Job
+	if err := json.Unmarshal(res.Body.Bytes(), &j); err != nil || res.Code != 202 || j.Id == 0 {
+		t.Errorf("Assertion Failed: %d %+v %v", res.Code, j, err)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAdminRequest("POST", "/1/album/1/image/1/tiles.dzi", nil))
+	var z database.DeepZoom
+	if err := json.Unmarshal(res.Body.Bytes(), &z); err != nil || res.Code != 200 || z.Width != 1280 {
+		t.Errorf("Assertion Failed: %d %+v %v", res.Code, z, err)
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/dfkdream/hugocms/plugin"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// GET: search galleries, albums and images by title, description and tags.
+// Unlisted content is found by admins, and by users with role on its gallery.
+func (a *API) searchHandler(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	q := req.URL.Query()
+
+	limit := defaultSearchLimit
+	if v := q.Get("limit"); v != "" {
+		l, err := atou(v)
+		if err != nil || l == 0 || l > maxSearchLimit {
+			writeError(res, ErrInvalidParameter)
+			return
+		}
+		limit = int(l)
+	}
+
+	var scope database.SearchScope
+	if u := plugin.GetUser(req); u != nil {
+		scope = database.SearchScope{UserId: u.Id, Unlisted: u.HasPermission(adminPermission)}
+	}
+
+	r, err := a.db.Search(q.Get("q"), limit, scope)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, r)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Search(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for _, req := range []struct {
+		method, target, body string
+	}{
+		{"POST", "/", `{"title":"hello"}`},
+		{"POST", "/1/albums", `{"title":"hello world"}`},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, newAuthenticatedRequest(req.method, req.target, bytes.NewReader([]byte(req.body))))
+		if res.Code != 201 {
+			t.Error(req.method, req.target, "code not matches:", res.Code, "!=", 201)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/search?q=world", nil))
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+
+	var r []database.SearchResult
+	err := json.NewDecoder(res.Body).Decode(&r)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(r) != 1 || r[0].Type != database.SearchTypeAlbum || r[0].GalleryId != 1 || r[0].AlbumId != 1 {
+		t.Errorf("Assertion Failed: %+v", r)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("PATCH", "/1/album/1", strings.NewReader(`{"visibility":"unlisted"}`)))
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+
+	for idx, v := range []struct {
+		req   *http.Request
+		count int
+	}{
+		{httptest.NewRequest("GET", "/search?q=world", nil), 0},
+		{newUserRequest("stranger", "GET", "/search?q=world", nil), 0},
+		{newAuthenticatedRequest("GET", "/search?q=world", nil), 1},
+		{newAdminRequest("GET", "/search?q=world", nil), 1},
+	} {
+		res = httptest.NewRecorder()
+		m.ServeHTTP(res, v.req)
+		r = nil
+		if err := json.NewDecoder(res.Body).Decode(&r); err != nil || len(r) != v.count {
+			t.Errorf("Test %d failed: %+v %v", idx, r, err)
+		}
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/search?q=hello&limit=0", nil))
+	if res.Code != 400 {
+		t.Error("code not matches:", res.Code, "!=", 400)
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_SmartAlbums(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+		resp []byte
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1/image/2/tags", bytes.NewReader([]byte(`{"tags":["sunset"]}`))), 200, nil},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"smart","query":{"tags":["sunset"]}}`))), 201, nil},
+		{newAuthenticatedRequest("GET", "/1/album/2", nil), 200, mustMarshalJSON(database.Album{Id: 2, Title: "smart", Owner: "hello", Smart: true, Query: &database.SmartQuery{Tags: []string{"sunset"}}})},
+		{newAuthenticatedRequest("GET", "/1/album/2/images", nil), 200, mustMarshalJSON([]database.Image{processedTestImage(database.Image{Id: 2, GalleryId: 1, AlbumId: 1, Owner: "hello", Tags: []string{"sunset"}})})},
+		{newAuthenticatedRequest("POST", "/1/album/2/images", createTestImage()), 400, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1", bytes.NewReader([]byte(`{"title":"hello","query":{}}`))), 400, nil},
+		{newAuthenticatedRequest("POST", "/1/album/2", bytes.NewReader([]byte(`{"title":"smart","query":{"tags":[]}}`))), 200, nil},
+		{newAuthenticatedRequest("GET", "/1/album/2/images", nil), 200, nil},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code)
+			continue
+		}
+
+		if r.resp != nil && !bytes.Equal(res.Body.Bytes(), r.resp) {
+			t.Error(idx, "response not matches:", string(res.Body.Bytes()), "!=", string(r.resp))
+		}
+	}
+}
+
+func TestAPI_SmartAlbumScope(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+		resp []byte
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1/image/1/tags", bytes.NewReader([]byte(`{"tags":["sunset"]}`))), 200, nil},
+		{newUserRequest("other", "POST", "/", bytes.NewReader([]byte(`{"title":"other"}`))), 201, nil},
+		{newUserRequest("other", "POST", "/2/albums", bytes.NewReader([]byte(`{"title":"other"}`))), 201, nil},
+		{newUserRequest("other", "POST", "/2/album/1/images", createTestImage()), 201, nil},
+		{newUserRequest("other", "POST", "/2/album/1/image/1/tags", bytes.NewReader([]byte(`{"tags":["sunset"]}`))), 200, nil},
+		// searching gallery without role on it is forbidden
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"smart","query":{"galleries":[1,2]}}`))), 403, nil},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"smart","query":{"galleries":[1]}}`))), 201, nil},
+		{newAuthenticatedRequest("PATCH", "/1/album/2", bytes.NewReader([]byte(`{"query":{"galleries":[2]}}`))), 403, nil},
+		{newAuthenticatedRequest("POST", "/1/album/2", bytes.NewReader([]byte(`{"title":"smart","query":{"galleries":[2]}}`))), 403, nil},
+		{newAdminRequest("PATCH", "/1/album/2", bytes.NewReader([]byte(`{"query":{"tags":["sunset"],"galleries":[1,2]}}`))), 200, nil},
+		{httptest.NewRequest("GET", "/1/album/2/images", nil), 200, mustMarshalJSON([]database.Image{
+			processedTestImage(database.Image{Id: 1, GalleryId: 1, AlbumId: 1, Owner: "hello", Tags: []string{"sunset"}}),
+			processedTestImage(database.Image{Id: 1, GalleryId: 2, AlbumId: 1, Owner: "other", Tags: []string{"sunset"}}),
+		})},
+		// unlisted albums are left out, unless requested by viewer of the smart album's gallery
+		{newAuthenticatedRequest("PATCH", "/1/album/1", bytes.NewReader([]byte(`{"visibility":"unlisted"}`))), 200, nil},
+		{newUserRequest("other", "PATCH", "/2/album/1", bytes.NewReader([]byte(`{"visibility":"unlisted"}`))), 200, nil},
+		{httptest.NewRequest("GET", "/1/album/2/images", nil), 200, []byte("[]\n")},
+		{newAuthenticatedRequest("GET", "/1/album/2/images", nil), 200, mustMarshalJSON([]database.Image{
+			processedTestImage(database.Image{Id: 1, GalleryId: 1, AlbumId: 1, Owner: "hello", Tags: []string{"sunset"}}),
+		})},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code, res.Body.String())
+			continue
+		}
+
+		if r.resp != nil && !bytes.Equal(res.Body.Bytes(), r.resp) {
+			t.Error(idx, "response not matches:", string(res.Body.Bytes()), "!=", string(r.resp))
+		}
+	}
+}
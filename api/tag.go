@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+// GET: get tags used in gallery with image counts.
+// Images of unlisted albums are counted for users with role on gallery only.
+func (a *API) tagsHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	t, err := a.db.GetTags(gid, getGrant(req).Role.Includes(database.RoleViewer))
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, t)
+}
+
+// GET: get images carrying tag across albums of gallery.
+// Images of unlisted albums are returned to users with role on gallery only.
+func (a *API) tagHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	i, err := a.db.GetImagesByTag(gid, vars["tag"], getGrant(req).Role.Includes(database.RoleViewer))
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, i)
+}
+
+// GET: get image tags
+// POST: add image tags
+func (a *API) imageTagsHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	aid, err := atou(vars["aid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	iid, err := atou(vars["iid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	i, err := a.db.GetImageInfo(gid, aid, iid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		tags := i.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		writeJSON(res, http.StatusOK, tags)
+	case "POST":
+		if !a.canModifyImage(res, req, gid, aid, iid) {
+			return
+		}
+
+		var values struct {
+			Tags []string `json:"tags"`
+		}
+
+		err := json.NewDecoder(req.Body).Decode(&values)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+
+		tags, err := a.db.AddImageTags(gid, aid, iid, values.Tags)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		a.audit(req, database.AuditEntry{Action: actionImageTag, GalleryId: gid, AlbumId: aid, ImageId: iid, Before: auditTags(i.Tags), After: auditTags(tags)})
+
+		writeJSON(res, http.StatusOK, tags)
+	default:
+		methodNotAllowed(res, "GET", "POST")
+	}
+}
+
+// DELETE: remove tag from image
+func (a *API) imageTagHandler(res http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	gid, err := atou(vars["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	aid, err := atou(vars["aid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	iid, err := atou(vars["iid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	tag := vars["tag"]
+
+	if req.Method != "DELETE" {
+		methodNotAllowed(res, "DELETE")
+		return
+	}
+
+	if !a.canModifyImage(res, req, gid, aid, iid) {
+		return
+	}
+
+	before, err := a.db.GetImageInfo(gid, aid, iid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	err = a.db.RemoveImageTag(gid, aid, iid, tag)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	i, err := a.db.GetImageInfo(gid, aid, iid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	tags := i.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	a.audit(req, database.AuditEntry{Action: actionImageUntag, GalleryId: gid, AlbumId: aid, ImageId: iid, Before: auditTags(before.Tags), After: auditTags(tags)})
+
+	writeJSON(res, http.StatusOK, tags)
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Tags(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+		resp []byte
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"hello"}`))), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201, nil},
+		{newAuthenticatedRequest("POST", "/1/album/1/image/1/tags", bytes.NewReader([]byte(`{"tags":["Sunset","바다"]}`))), 200, mustMarshalJSON([]string{"sunset", "바다"})},
+		{newAuthenticatedRequest("POST", "/1/album/1/image/1/tags", bytes.NewReader([]byte(`{"tags":[""]}`))), 400, nil},
+		{newAuthenticatedRequest("GET", "/1/album/1/image/1/tags", nil), 200, mustMarshalJSON([]string{"sunset", "바다"})},
+		{newAuthenticatedRequest("GET", "/1/tags", nil), 200, mustMarshalJSON([]database.TagCount{{Tag: "sunset", Count: 1}, {Tag: "바다", Count: 1}})},
+		{newAuthenticatedRequest("GET", "/1/tag/sunset", nil), 200, mustMarshalJSON([]database.Image{processedTestImage(database.Image{Id: 1, AlbumId: 1, Owner: "hello", Tags: []string{"sunset", "바다"}})})},
+		{newAuthenticatedRequest("PATCH", "/1/album/1", bytes.NewReader([]byte(`{"visibility":"unlisted"}`))), 200, nil},
+		{httptest.NewRequest("GET", "/1/tags", nil), 200, mustMarshalJSON([]database.TagCount{})},
+		{httptest.NewRequest("GET", "/1/tag/sunset", nil), 200, mustMarshalJSON([]database.Image{})},
+		{newAuthenticatedRequest("GET", "/1/tags", nil), 200, mustMarshalJSON([]database.TagCount{{Tag: "sunset", Count: 1}, {Tag: "바다", Count: 1}})},
+		{newUserRequest("stranger", "DELETE", "/1/album/1/image/1/tag/sunset", nil), 403, nil},
+		{newAuthenticatedRequest("DELETE", "/1/album/1/image/1/tag/sunset", nil), 200, nil},
+		{newAuthenticatedRequest("DELETE", "/1/album/1/image/1/tag/sunset", nil), 404, nil},
+		{newAuthenticatedRequest("GET", "/1/tag/sunset", nil), 200, mustMarshalJSON([]database.Image{})},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code)
+			continue
+		}
+
+		if r.resp != nil && !bytes.Equal(res.Body.Bytes(), r.resp) {
+			t.Error(idx, "response not matches:", string(res.Body.Bytes()), "!=", string(r.resp))
+		}
+	}
+}
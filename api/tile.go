@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const deepZoomNamespace = "http://schemas.microsoft.com/deepzoom/2008"
+
+type dziImage struct {
+	XMLName  xml.Name `xml:"Image"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	TileSize int      `xml:"TileSize,attr"`
+	Overlap  int      `xml:"Overlap,attr"`
+	Format   string   `xml:"Format,attr"`
+	Size     dziSize  `xml:"Size"`
+}
+
+type dziSize struct {
+	Width  int `xml:"Width,attr"`
+	Height int `xml:"Height,attr"`
+}
+
+// GET: get Deep Zoom descriptor of image. Tiles are served under tiles_files.
+// POST: generate tile pyramid of image
+func (a *API) deepZoomHandler(res http.ResponseWriter, req *http.Request) {
+	gid, aid, iid, err := imageVars(req)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		z, err := a.db.GetDeepZoom(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		res.Header().Set("Access-Control-Allow-Origin", "*")
+		if strings.Contains(req.Header.Get("Accept"), "application/json") {
+			writeJSON(res, http.StatusOK, z)
+			return
+		}
+
+		var b bytes.Buffer
+		b.WriteString(xml.Header)
+		err = xml.NewEncoder(&b).Encode(dziImage{
+			Xmlns:    deepZoomNamespace,
+			TileSize: z.TileSize,
+			Overlap:  z.Overlap,
+			Format:   z.Format,
+			Size:     dziSize{z.Width, z.Height},
+		})
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		http.ServeContent(res, req, "", time.Time{}, bytes.NewReader(b.Bytes()))
+	case "POST":
+		if !a.canModifyImage(res, req, gid, aid, iid) {
+			return
+		}
+
+		z, err := a.db.GenerateTiles(gid, aid, iid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, z)
+	default:
+		methodNotAllowed(res, "GET", "POST")
+	}
+}
+
+// GET: get deep zoom tile of image
+func (a *API) tileHandler(res http.ResponseWriter, req *http.Request) {
+	gid, aid, iid, err := imageVars(req)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	vars := mux.Vars(req)
+	level, err := strconv.Atoi(vars["level"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	col, err := strconv.Atoi(vars["col"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+	row, err := strconv.Atoi(vars["row"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	tile, timestamp, err := a.db.GetTile(gid, aid, iid, level, col, row)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "image/jpeg")
+	res.Header().Set("Access-Control-Allow-Origin", "*")
+	http.ServeContent(res, req, "", timestamp, bytes.NewReader(tile))
+}
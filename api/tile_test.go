@@ -0,0 +1,71 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"image/jpeg"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+func TestAPI_DeepZoom(t *testing.T) {
+	m := createFixtureAPI()
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/1/image/1/tiles.dzi", nil))
+	if res.Code != 404 {
+		t.Error("code not matches:", res.Code, "!=", 404)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("POST", "/1/album/1/image/1/tiles.dzi", nil))
+	if res.Code != 403 {
+		t.Error("code not matches:", res.Code, "!=", 403)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("POST", "/1/album/1/image/1/tiles.dzi", nil))
+	var z database.DeepZoom
+	if err := json.Unmarshal(res.Body.Bytes(), &z); err != nil || res.Code != 200 || z.Width != 1280 {
+		t.Fatalf("Assertion Failed: %d %+v %v", res.Code, z, err)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/1/image/1/tiles.dzi", nil))
+	var dzi dziImage
+	if err := xml.Unmarshal(res.Body.Bytes(), &dzi); err != nil {
+		t.Fatal(err)
+	}
+	if dzi.TileSize != z.TileSize || dzi.Overlap != 1 || dzi.Format != "jpg" || dzi.Size.Width != 1280 || dzi.Size.Height != 1280 {
+		t.Errorf("Assertion Failed: %+v", dzi)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/1/image/1/tiles_files/11/0_0.jpg", nil))
+	if res.Code != 200 || res.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatal("tile not served:", res.Code, res.Header())
+	}
+	if c, err := jpeg.DecodeConfig(res.Body); err != nil || c.Width != z.TileSize+1 {
+		t.Errorf("Assertion Failed: %+v %v", c, err)
+	}
+
+	for _, target := range []string{"/1/album/1/image/1/tiles_files/12/0_0.jpg", "/1/album/1/image/1/tiles_files/0/-1_0.jpg"} {
+		res = httptest.NewRecorder()
+		m.ServeHTTP(res, httptest.NewRequest("GET", target, nil))
+		if res.Code != 404 {
+			t.Error("code not matches:", target, res.Code, "!=", 404)
+		}
+	}
+
+	// image JSON reports tiles
+	req := httptest.NewRequest("GET", "/1/album/1/image/1", nil)
+	req.Header.Set("Accept", "application/json")
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, req)
+	var i database.Image
+	if err := json.Unmarshal(res.Body.Bytes(), &i); err != nil || !i.Tiled {
+		t.Errorf("Assertion Failed: %+v %v", i, err)
+	}
+}
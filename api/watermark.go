@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/gorilla/mux"
+)
+
+// GET: get watermark of gallery
+// PUT: set watermark of gallery, and watermark its images again in background
+// DELETE: remove watermark of gallery
+func (a *API) watermarkHandler(res http.ResponseWriter, req *http.Request) {
+	gid, err := atou(mux.Vars(req)["gid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		if !authorize(res, req, database.RoleViewer) {
+			return
+		}
+
+		w, err := a.db.GetWatermark(gid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, w)
+	case "PUT":
+		if !authorize(res, req, database.RoleOwner) {
+			return
+		}
+
+		var w database.Watermark
+		err := json.NewDecoder(req.Body).Decode(&w)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+
+		var before string
+		if current, err := a.db.GetWatermark(gid); err == nil {
+			before = auditJSON(current)
+		}
+
+		err = a.db.SetWatermark(gid, &w)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		a.audit(req, database.AuditEntry{Action: actionWatermarkSet, GalleryId: gid, Before: before, After: auditJSON(w)})
+		writeJSON(res, http.StatusOK, w)
+	case "DELETE":
+		if !authorize(res, req, database.RoleOwner) {
+			return
+		}
+
+		before, err := a.db.GetWatermark(gid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		err = a.db.SetWatermark(gid, nil)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		a.audit(req, database.AuditEntry{Action: actionWatermarkDelete, GalleryId: gid, Before: auditJSON(before)})
+		writeJSON(res, http.StatusOK, before)
+	default:
+		methodNotAllowed(res, "GET", "PUT", "DELETE")
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Watermark(t *testing.T) {
+	a := createTestAPI()
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	for idx, r := range []struct {
+		req  *http.Request
+		code int
+	}{
+		{newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))), 201},
+		{newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"hello"}`))), 201},
+		{newAuthenticatedRequest("GET", "/1/watermark", nil), 404},
+		{newAuthenticatedRequest("PUT", "/1/watermark", bytes.NewReader([]byte(`{"text":"hello","position":"middle","opacity":0.5,"scale":0.2}`))), 400},
+		{httptest.NewRequest("PUT", "/1/watermark", bytes.NewReader([]byte(`{"text":"hello","position":"bottom-right","opacity":0.5,"scale":0.2}`))), 403},
+		{newAuthenticatedRequest("PUT", "/1/watermark", bytes.NewReader([]byte(`{"text":"hello","position":"bottom-right","opacity":0.5,"scale":0.2}`))), 200},
+		{newAuthenticatedRequest("GET", "/1/watermark", nil), 200},
+		{newAuthenticatedRequest("POST", "/1/album/1/images", createTestImage()), 201},
+		{httptest.NewRequest("GET", "/1/album/1/image/1?unwatermarked=1", nil), 403},
+		{newAuthenticatedRequest("GET", "/1/album/1/image/1?unwatermarked=1", nil), 403},
+		{httptest.NewRequest("GET", "/1/album/1/image/1?thumb=1&unwatermarked=1", nil), 403},
+		{httptest.NewRequest("GET", "/1/album/1/image/1/tiles.dzi", nil), 404},
+		{newAuthenticatedRequest("POST", "/1/album/1/image/1/tiles.dzi", nil), 200},
+		{httptest.NewRequest("GET", "/1/album/1/image/1/tiles.dzi", nil), 200},
+		{httptest.NewRequest("GET", "/1/album/1/image/1/tiles_files/0/0_0.jpg", nil), 200},
+	} {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, r.req)
+		if res.Code != r.code {
+			t.Error(idx, "code not matches:", res.Code, "!=", r.code, res.Body.String())
+		}
+	}
+
+	get := func(req *http.Request) []byte {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		if res.Code != 200 || res.Header().Get("Content-Type") != "image/jpeg" {
+			t.Fatal("image not served:", res.Code)
+		}
+		return res.Body.Bytes()
+	}
+
+	watermarked := get(httptest.NewRequest("GET", "/1/album/1/image/1", nil))
+	clean := get(newAdminRequest("GET", "/1/album/1/image/1?unwatermarked=1", nil))
+	if bytes.Equal(watermarked, clean) {
+		t.Error("display image is not watermarked")
+	}
+
+	thumb := get(httptest.NewRequest("GET", "/1/album/1/image/1?thumb=1", nil))
+	cleanThumb := get(newAdminRequest("GET", "/1/album/1/image/1?thumb=1&unwatermarked=1", nil))
+	if bytes.Equal(thumb, cleanThumb) {
+		t.Error("thumbnail is not watermarked")
+	}
+
+	res := httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("DELETE", "/1/watermark", nil))
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, newAuthenticatedRequest("DELETE", "/1/watermark", nil))
+	if res.Code != 404 {
+		t.Error("code not matches:", res.Code, "!=", 404)
+	}
+
+	res = httptest.NewRecorder()
+	m.ServeHTTP(res, httptest.NewRequest("GET", "/1/album/1/image/1/tiles.dzi", nil))
+	if res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/dfkdream/gallery-plugin/database"
+
+	"github.com/dfkdream/hugocms/plugin"
+	"github.com/gorilla/mux"
+)
+
+// requireAdmin rejects request with 403 unless user is admin.
+func requireAdmin(res http.ResponseWriter, req *http.Request) bool {
+	u := plugin.GetUser(req)
+	if u == nil {
+		writeError(res, ErrLoginRequired)
+		return false
+	}
+	if !u.HasPermission(adminPermission) {
+		writeError(res, ErrForbidden)
+		return false
+	}
+	return true
+}
+
+// withoutSecret hides secret of webhook, which is only revealed on creation.
+func withoutSecret(w database.Webhook) database.Webhook {
+	w.Secret = ""
+	return w
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GET: get webhooks
+// POST: create webhook. Secret is generated unless given.
+func (a *API) webhooksHandler(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		if !requireAdmin(res, req) {
+			return
+		}
+
+		w, err := a.db.GetWebhooks()
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		for idx := range w {
+			w[idx] = withoutSecret(w[idx])
+		}
+
+		writeJSON(res, http.StatusOK, w)
+	case "POST":
+		if !requireAdmin(res, req) {
+			return
+		}
+
+		var values database.Webhook
+
+		err := json.NewDecoder(req.Body).Decode(&values)
+		if err != nil {
+			writeError(res, ErrInvalidJSON)
+			return
+		}
+
+		if values.Secret == "" {
+			values.Secret, err = newSecret()
+			if err != nil {
+				writeError(res, err)
+				return
+			}
+		}
+
+		wid, err := a.db.CreateWebhook(values)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+
+		w, err := a.db.GetWebhook(wid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+		// secret is only ever returned here, so webhook is written even in legacy mode
+		res.Header().Set("Location", path.Join(path.Dir(req.URL.Path), "webhook", strconv.FormatUint(wid, 10)))
+		writeJSON(res, http.StatusCreated, w)
+	default:
+		methodNotAllowed(res, "GET", "POST")
+	}
+}
+
+// GET: get webhook
+// DELETE: delete webhook and its queued deliveries
+func (a *API) webhookHandler(res http.ResponseWriter, req *http.Request) {
+	wid, err := atou(mux.Vars(req)["wid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" && req.Method != "DELETE" {
+		methodNotAllowed(res, "GET", "DELETE")
+		return
+	}
+
+	if !requireAdmin(res, req) {
+		return
+	}
+
+	w, err := a.db.GetWebhook(wid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	if req.Method == "DELETE" {
+		err = a.db.DeleteWebhook(wid)
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+	}
+
+	writeJSON(res, http.StatusOK, withoutSecret(w))
+}
+
+// GET: get queued and failed deliveries of webhook
+func (a *API) deliveriesHandler(res http.ResponseWriter, req *http.Request) {
+	wid, err := atou(mux.Vars(req)["wid"])
+	if err != nil {
+		writeError(res, ErrInvalidId)
+		return
+	}
+
+	if req.Method != "GET" {
+		methodNotAllowed(res, "GET")
+		return
+	}
+
+	if !requireAdmin(res, req) {
+		return
+	}
+
+	d, err := a.db.GetDeliveries(wid)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, d)
+}
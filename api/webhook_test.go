@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/gorilla/mux"
+)
+
+func TestAPI_Webhooks(t *testing.T) {
+	a := createTestAPI()
+
+	m := mux.NewRouter()
+	a.SetupHandlers(m)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		m.ServeHTTP(res, req)
+		return res
+	}
+
+	body := `{"url":"http://example.com/hook","events":["gallery.*"]}`
+	if res := serve(newAuthenticatedRequest("POST", "/webhooks", bytes.NewReader([]byte(body)))); res.Code != 403 {
+		t.Error("code not matches:", res.Code, "!=", 403)
+	}
+	if res := serve(newAdminRequest("POST", "/webhooks", bytes.NewReader([]byte(`{"url":"hook","events":["*"]}`)))); res.Code != 400 {
+		t.Error("code not matches:", res.Code, "!=", 400)
+	}
+
+	res := serve(newAdminRequest("POST", "/webhooks", bytes.NewReader([]byte(body))))
+	var w database.Webhook
+	_ = json.NewDecoder(res.Body).Decode(&w)
+	if res.Code != 201 || res.Header().Get("Location") != "/webhook/1" || w.Id != 1 || len(w.Secret) != 64 {
+		t.Errorf("Assertion Failed: %d %+v", res.Code, w)
+	}
+
+	res = serve(newAdminRequest("GET", "/webhooks", nil))
+	if res.Code != 200 || bytes.Contains(res.Body.Bytes(), []byte(w.Secret)) {
+		t.Error("secret revealed:", res.Body.String())
+	}
+
+	serve(newAuthenticatedRequest("POST", "/", bytes.NewReader([]byte(`{"title":"hello"}`))))
+	serve(newAuthenticatedRequest("POST", "/1/albums", bytes.NewReader([]byte(`{"title":"album"}`))))
+
+	res = serve(newAdminRequest("GET", "/webhook/1/deliveries", nil))
+	var d []database.Delivery
+	_ = json.NewDecoder(res.Body).Decode(&d)
+	if res.Code != 200 || len(d) != 1 || d[0].Event != "gallery.create" {
+		t.Errorf("Assertion Failed: %d %+v", res.Code, d)
+	}
+
+	if res := serve(newAdminRequest("DELETE", "/webhook/1", nil)); res.Code != 200 {
+		t.Error("code not matches:", res.Code, "!=", 200)
+	}
+	if res := serve(newAdminRequest("GET", "/webhook/1", nil)); res.Code != 404 {
+		t.Error("code not matches:", res.Code, "!=", 404)
+	}
+}
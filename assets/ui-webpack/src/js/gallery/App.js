@@ -3,18 +3,25 @@ import ReactDOM from "react-dom"
 
 import AlbumsPage from "./components/albumsPage";
 import ImagesPage from "./components/imagesPage";
+import {api} from "./api";
 
 const container = document.getElementById("app");
 
+// album opened by embed page, listed on its own
+const albumId = parseInt(container.dataset.aid) || 0;
+
 class App extends Component {
     constructor(props) {
         super(props);
 
         this.loadAlbums = this.loadAlbums.bind(this);
         this.loadImages = this.loadImages.bind(this);
+        this.loadTag = this.loadTag.bind(this);
 
         this.state = {
-            page: 0, // 0->Albums, 1...->Images (albumId)
+            page: 0, // -1->Tagged images, 0->Albums, 1...->Images (albumId)
+            tag: container.dataset.tag || null,
+            tagImages: null,
             isLoading: true,
             gallery: null,
             albums: null,
@@ -28,17 +35,17 @@ class App extends Component {
             this.loadFromHref();
         });
 
-        fetch("/api/gallery/" + container.dataset.gid)
+        fetch(api + "/" + container.dataset.gid)
             .then(resp => resp.json())
             .then(
                 (json) => {
                     this.setState({gallery: json});
 
-                    fetch("/api/gallery/" + this.state.gallery.id + "/albums")
+                    fetch(api + "/" + this.state.gallery.id + (albumId ? "/album/" + albumId : "/albums"))
                         .then(resp => resp.json())
                         .then(
                             (json) => {
-                                this.setState({albums: json});
+                                this.setState({albums: albumId ? [json] : json});
                                 this.loadFromHref();
                             },
                             (error) => {
@@ -55,7 +62,9 @@ class App extends Component {
     loadFromHref() {
         let href = location.hash.split("/").filter(v => !v.includes("#")).join("/");
         if (href === "") {
-            this.loadAlbums();
+            if (this.state.tag) this.loadTag();
+            else if (albumId) this.loadImages(albumId);
+            else this.loadAlbums();
         } else {
             const aid = parseInt(href);
             if (aid) this.loadImages(aid);
@@ -80,7 +89,7 @@ class App extends Component {
         }
 
         this.setState({isLoading: true, error: null});
-        fetch("/api/gallery/" + this.state.gallery.id + "/album/" + aid + "/images")
+        fetch(api + "/" + this.state.gallery.id + "/album/" + aid + "/images")
             .then(resp => resp.json())
             .then(
                 (json) => {
@@ -92,6 +101,27 @@ class App extends Component {
             )
     }
 
+    loadTag() {
+        if (!this.state.gallery) return;
+
+        if (this.state.tagImages) {
+            this.setState({page: -1});
+            return;
+        }
+
+        this.setState({isLoading: true, error: null});
+        fetch(api + "/" + this.state.gallery.id + "/tag/" + encodeURIComponent(this.state.tag))
+            .then(resp => resp.json())
+            .then(
+                (json) => {
+                    this.setState({isLoading: false, tagImages: json, page: -1});
+                },
+                (error) => {
+                    this.setState({isLoading: false, error: error.message});
+                }
+            )
+    }
+
     render() {
         if (this.state.isLoading) {
             return <h1>Loading...</h1>;
@@ -109,6 +139,12 @@ class App extends Component {
                                albums={this.state.albums}
                                loadImages={this.loadImages}/>;
 
+        } else if (this.state.page === -1) {
+            return <ImagesPage gallery={this.state.gallery}
+                               album={{id: 0, title: "#" + this.state.tag}}
+                               images={this.state.tagImages}
+                               loadAlbums={this.loadTag}/>;
+
         } else if (this.state.page > 0) {
             return <ImagesPage gallery={this.state.gallery}
                                album={this.state.albums.filter(a => a.id === this.state.page)[0]}
@@ -0,0 +1,4 @@
+const container = document.getElementById("app");
+
+// path gallery API is served at. Embed page sets it, as it may be served elsewhere.
+export const api = container.dataset.api !== undefined ? container.dataset.api : "/api/gallery";
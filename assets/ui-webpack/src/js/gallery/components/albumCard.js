@@ -2,12 +2,14 @@ import React from "react";
 
 import "../../../sass/gallery.scss";
 
+import {api} from "../api";
+
 function AlbumCard(props){
     return(
         <a className="album-card" href={"#!/"+props.album.id} onClick={props.onClick}>
             {props.album.cover!==0?
                 <figure className="image is-1by1 img"
-                        style={{backgroundImage: `url(/api/gallery/${props.gallery.id}/album/${props.album.id}/image/${props.album.cover}?thumb=1)`}}
+                        style={{backgroundImage: `url(${api}/${props.gallery.id}/album/${props.album.id}/image/${props.album.cover}?thumb=1)`}}
                 />:
                 <figure className="image placeholder"/>
             }
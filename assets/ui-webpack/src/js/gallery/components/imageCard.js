@@ -2,6 +2,8 @@ import React, {Component} from "react";
 import Lightbox from "react-image-lightbox";
 import "react-image-lightbox/style.css";
 
+import {api} from "../api";
+
 class ImageCard extends Component {
     constructor(props){
         super(props);
@@ -14,8 +16,11 @@ class ImageCard extends Component {
         this.image=this.props.images[this.props.index]
     }
 
-    toImgSrc(id) {
-        return `/api/gallery/${this.props.gallery.id}/album/${this.props.album.id}/image/${id}`
+    toImgSrc(image) {
+        // tagged images and smart album images carry their own location
+        const gid = image.galleryId || this.props.gallery.id;
+        const aid = image.albumId || this.props.album.id;
+        return `${api}/${gid}/album/${aid}/image/${image.id}`
     }
 
     getPrevIndex() {
@@ -32,22 +37,25 @@ class ImageCard extends Component {
                 <a className="image-card">
                     <figure className="image is-1by1 img"
                             data-description={this.image.description === "" ? null : this.image.description}
-                            style={{backgroundImage: `url(${this.toImgSrc(this.image.id)}?thumb=1)`}}
+                            style={{
+                                backgroundImage: `url(${this.toImgSrc(this.image)}?thumb=1)` + (this.image.lqip ? `, url(${this.image.lqip})` : ""),
+                                backgroundColor: this.image.color
+                            }}
                             onClick={()=>{this.setState({isLightboxOpen: true, currentIndex: this.props.index})}}
                     />
                 </a>
                 {this.state.isLightboxOpen &&
                     <Lightbox
-                        mainSrc={this.toImgSrc(this.props.images[this.state.currentIndex].id)}
-                        mainSrcThumbnail={this.toImgSrc(this.props.images[this.state.currentIndex].id) + "?thumb=1"}
+                        mainSrc={this.toImgSrc(this.props.images[this.state.currentIndex])}
+                        mainSrcThumbnail={this.toImgSrc(this.props.images[this.state.currentIndex]) + "?thumb=1"}
                         imageTitle={this.props.images[this.state.currentIndex].description}
-                        prevSrc={this.toImgSrc(this.props.images[this.getPrevIndex()].id)}
-                        prevSrcThumbnail={this.toImgSrc(this.props.images[this.getPrevIndex()].id) + "?thumb=1"}
+                        prevSrc={this.toImgSrc(this.props.images[this.getPrevIndex()])}
+                        prevSrcThumbnail={this.toImgSrc(this.props.images[this.getPrevIndex()]) + "?thumb=1"}
                         onMovePrevRequest={() => {
                             this.setState({currentIndex: this.getPrevIndex()})
                         }}
-                        nextSrc={this.toImgSrc(this.props.images[this.getNextIndex()].id)}
-                        nextSrcThumbnail={this.toImgSrc(this.props.images[this.getNextIndex()].id) + "?thumb=1"}
+                        nextSrc={this.toImgSrc(this.props.images[this.getNextIndex()])}
+                        nextSrcThumbnail={this.toImgSrc(this.props.images[this.getNextIndex()]) + "?thumb=1"}
                         onMoveNextRequest={() => {
                             this.setState({currentIndex: this.getNextIndex()})
                         }}
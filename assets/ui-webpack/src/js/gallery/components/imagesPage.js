@@ -21,7 +21,7 @@ class ImagesPage extends Component {
                 <div className="columns is-multiline">
                     {this.props.images.map((i, idx) => {
                         return (
-                            <div className="column is-one-third" key={"i-"+i.id}>
+                            <div className="column is-one-third" key={"i-"+(i.galleryId || this.props.gallery.id)+"-"+(i.albumId || this.props.album.id)+"-"+i.id}>
                                 <ImageCard gallery={this.props.gallery} album={this.props.album} images={this.props.images} index={idx}/>
                             </div>
                         );
@@ -0,0 +1,693 @@
+// Package client is typed Go client of gallery plugin API.
+// Every method maps to one operation of the OpenAPI document served at /api/gallery/openapi.json.
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+// Error is error response of API
+type Error struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gallery: %d %s: %s", e.Status, e.Code, e.Message)
+}
+
+type Client struct {
+	// BaseURL is url API router is mounted, e.g. https://example.com/api/gallery
+	BaseURL string
+	// Header is added to every request, e.g. for authentication
+	Header     http.Header
+	HTTPClient *http.Client
+}
+
+// New returns client of API at baseURL. http.DefaultClient is used if httpClient is nil.
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Header:     make(http.Header),
+		HTTPClient: httpClient,
+	}
+}
+
+func id(i uint64) string {
+	return strconv.FormatUint(i, 10)
+}
+
+// pathOf joins escaped path segments
+func pathOf(segments ...string) string {
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 400 {
+		defer res.Body.Close()
+
+		e := &Error{Status: res.StatusCode}
+		if err := json.NewDecoder(res.Body).Decode(e); err != nil || e.Code == "" {
+			e.Message = res.Status
+		}
+		return nil, e
+	}
+
+	return res, nil
+}
+
+// do sends request with JSON body if in is not nil, and decodes JSON response into out if not nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	_, err := c.doHeader(ctx, method, path, query, nil, in, out)
+	return err
+}
+
+// doHeader is do with additional request header, returning response header.
+func (c *Client) doHeader(ctx context.Context, method, path string, query url.Values, header http.Header, in, out interface{}) (http.Header, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(string(b))
+
+		if header == nil {
+			header = make(http.Header)
+		}
+		header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.request(ctx, method, path, query, body, header)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(ioutil.Discard, res.Body)
+		return res.Header, err
+	}
+	return res.Header, json.NewDecoder(res.Body).Decode(out)
+}
+
+// update sends PATCH, or PUT if replace is set, with If-Match header if etag is not empty.
+// ETag of updated entity is returned.
+func (c *Client) update(ctx context.Context, path string, replace bool, etag string, in, out interface{}) (string, error) {
+	method := "PATCH"
+	if replace {
+		method = "PUT"
+	}
+
+	header := make(http.Header)
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+
+	h, err := c.doHeader(ctx, method, path, nil, header, in, out)
+	if err != nil {
+		return "", err
+	}
+	return h.Get("ETag"), nil
+}
+
+// get decodes entity at path into out, and returns its ETag
+func (c *Client) get(ctx context.Context, path string, out interface{}) (string, error) {
+	header := http.Header{"Accept": {"application/json"}}
+	h, err := c.doHeader(ctx, "GET", path, nil, header, nil, out)
+	if err != nil {
+		return "", err
+	}
+	return h.Get("ETag"), nil
+}
+
+// pageQuery returns query of page, and whether request is paged.
+func pageQuery(page database.Page) url.Values {
+	q := make(url.Values)
+	if page.Limit > 0 {
+		q.Set("limit", strconv.Itoa(page.Limit))
+	}
+	if page.After != (database.Cursor{}) {
+		q.Set("after", page.After.String())
+	}
+	if len(q) == 0 {
+		// force page envelope
+		q.Set("after", "0")
+	}
+	return q
+}
+
+// doPage requests paged listing and decodes items into out.
+// Returned cursor is zero on last page.
+func (c *Client) doPage(ctx context.Context, path string, page database.Page, out interface{}) (database.Cursor, error) {
+	var result struct {
+		Items interface{} `json:"items"`
+		Next  string      `json:"next"`
+	}
+	result.Items = out
+
+	err := c.do(ctx, "GET", path, pageQuery(page), nil, &result)
+	if err != nil || result.Next == "" {
+		return database.Cursor{}, err
+	}
+	return database.ParseCursor(result.Next)
+}
+
+type title struct {
+	Title string `json:"title"`
+}
+
+// ListGalleries returns every gallery
+func (c *Client) ListGalleries(ctx context.Context) ([]database.Gallery, error) {
+	var result []database.Gallery
+	err := c.do(ctx, "GET", "/", nil, nil, &result)
+	return result, err
+}
+
+// ListGalleriesPage returns page of galleries and cursor of next page
+func (c *Client) ListGalleriesPage(ctx context.Context, page database.Page) ([]database.Gallery, database.Cursor, error) {
+	var result []database.Gallery
+	next, err := c.doPage(ctx, "/", page, &result)
+	return result, next, err
+}
+
+// CreateGallery creates gallery
+func (c *Client) CreateGallery(ctx context.Context, galleryTitle string) (database.Gallery, error) {
+	var result database.Gallery
+	err := c.do(ctx, "POST", "/", nil, title{galleryTitle}, &result)
+	return result, err
+}
+
+// GetGallery returns gallery
+func (c *Client) GetGallery(ctx context.Context, galleryId uint64) (database.Gallery, error) {
+	var result database.Gallery
+	err := c.do(ctx, "GET", pathOf(id(galleryId)), nil, nil, &result)
+	return result, err
+}
+
+// SetGalleryTitle sets gallery title
+func (c *Client) SetGalleryTitle(ctx context.Context, galleryId uint64, galleryTitle string) (database.Gallery, error) {
+	var result database.Gallery
+	err := c.do(ctx, "POST", pathOf(id(galleryId)), nil, title{galleryTitle}, &result)
+	return result, err
+}
+
+// GetGalleryETag returns gallery and its ETag
+func (c *Client) GetGalleryETag(ctx context.Context, galleryId uint64) (database.Gallery, string, error) {
+	var result database.Gallery
+	etag, err := c.get(ctx, pathOf(id(galleryId)), &result)
+	return result, etag, err
+}
+
+// UpdateGallery applies patch to gallery, or replaces it if patch.Replace is set.
+// If etag is not empty, update fails with 412 error when gallery changed since.
+// Updated gallery and its ETag are returned.
+func (c *Client) UpdateGallery(ctx context.Context, galleryId uint64, patch database.GalleryPatch, etag string) (database.Gallery, string, error) {
+	var result database.Gallery
+	etag, err := c.update(ctx, pathOf(id(galleryId)), patch.Replace, etag, patch, &result)
+	return result, etag, err
+}
+
+// DeleteGallery deletes gallery
+func (c *Client) DeleteGallery(ctx context.Context, galleryId uint64) error {
+	return c.do(ctx, "DELETE", pathOf(id(galleryId)), nil, nil, nil)
+}
+
+// BatchResult is result of single batch operation
+type BatchResult struct {
+	Index  int             `json:"index"`
+	Status string          `json:"status"`
+	Image  *database.Image `json:"image,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// Batch applies operations on images of gallery in one transaction.
+// If any operation fails, none is applied and error of failed operation is returned.
+func (c *Client) Batch(ctx context.Context, galleryId uint64, ops []database.BatchOperation) ([]BatchResult, error) {
+	var result struct {
+		Results []BatchResult `json:"results"`
+	}
+	in := struct {
+		Operations []database.BatchOperation `json:"operations"`
+	}{ops}
+	err := c.do(ctx, "POST", pathOf(id(galleryId), "batch"), nil, in, &result)
+	return result.Results, err
+}
+
+// ListGrants returns grants of gallery
+func (c *Client) ListGrants(ctx context.Context, galleryId uint64) ([]database.Grant, error) {
+	var result []database.Grant
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "grants"), nil, nil, &result)
+	return result, err
+}
+
+// SetGrant creates or replaces grant
+func (c *Client) SetGrant(ctx context.Context, galleryId uint64, grant database.Grant) (database.Grant, error) {
+	var result database.Grant
+	err := c.do(ctx, "POST", pathOf(id(galleryId), "grants"), nil, grant, &result)
+	return result, err
+}
+
+// GetGrant returns grant of user
+func (c *Client) GetGrant(ctx context.Context, galleryId uint64, userId string) (database.Grant, error) {
+	var result database.Grant
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "grant", userId), nil, nil, &result)
+	return result, err
+}
+
+// DeleteGrant deletes grant of user
+func (c *Client) DeleteGrant(ctx context.Context, galleryId uint64, userId string) error {
+	return c.do(ctx, "DELETE", pathOf(id(galleryId), "grant", userId), nil, nil, nil)
+}
+
+// GetWatermark returns watermark of gallery
+func (c *Client) GetWatermark(ctx context.Context, galleryId uint64) (database.Watermark, error) {
+	var result database.Watermark
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "watermark"), nil, nil, &result)
+	return result, err
+}
+
+// SetWatermark sets watermark of gallery
+func (c *Client) SetWatermark(ctx context.Context, galleryId uint64, watermark database.Watermark) (database.Watermark, error) {
+	var result database.Watermark
+	err := c.do(ctx, "PUT", pathOf(id(galleryId), "watermark"), nil, watermark, &result)
+	return result, err
+}
+
+// DeleteWatermark removes watermark of gallery
+func (c *Client) DeleteWatermark(ctx context.Context, galleryId uint64) error {
+	return c.do(ctx, "DELETE", pathOf(id(galleryId), "watermark"), nil, nil, nil)
+}
+
+// ListTags returns tags used in gallery
+func (c *Client) ListTags(ctx context.Context, galleryId uint64) ([]database.TagCount, error) {
+	var result []database.TagCount
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "tags"), nil, nil, &result)
+	return result, err
+}
+
+// ListImagesByTag returns images carrying tag, across albums
+func (c *Client) ListImagesByTag(ctx context.Context, galleryId uint64, tag string) ([]database.Image, error) {
+	var result []database.Image
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "tag", tag), nil, nil, &result)
+	return result, err
+}
+
+// ListAlbums returns every album of gallery
+func (c *Client) ListAlbums(ctx context.Context, galleryId uint64) ([]database.Album, error) {
+	var result []database.Album
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "albums"), nil, nil, &result)
+	return result, err
+}
+
+// ListAlbumsPage returns page of albums and cursor of next page
+func (c *Client) ListAlbumsPage(ctx context.Context, galleryId uint64, page database.Page) ([]database.Album, database.Cursor, error) {
+	var result []database.Album
+	next, err := c.doPage(ctx, pathOf(id(galleryId), "albums"), page, &result)
+	return result, next, err
+}
+
+type albumInput struct {
+	Title string               `json:"title"`
+	Query *database.SmartQuery `json:"query,omitempty"`
+}
+
+// CreateAlbum creates album
+func (c *Client) CreateAlbum(ctx context.Context, galleryId uint64, albumTitle string) (database.Album, error) {
+	var result database.Album
+	err := c.do(ctx, "POST", pathOf(id(galleryId), "albums"), nil, albumInput{Title: albumTitle}, &result)
+	return result, err
+}
+
+// CreateSmartAlbum creates album listing images matching query
+func (c *Client) CreateSmartAlbum(ctx context.Context, galleryId uint64, albumTitle string, query database.SmartQuery) (database.Album, error) {
+	var result database.Album
+	err := c.do(ctx, "POST", pathOf(id(galleryId), "albums"), nil, albumInput{Title: albumTitle, Query: &query}, &result)
+	return result, err
+}
+
+// GetAlbum returns album
+func (c *Client) GetAlbum(ctx context.Context, galleryId, albumId uint64) (database.Album, error) {
+	var result database.Album
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "album", id(albumId)), nil, nil, &result)
+	return result, err
+}
+
+// UpdateAlbum sets album title, and query of smart album if query is not nil
+func (c *Client) UpdateAlbum(ctx context.Context, galleryId, albumId uint64, albumTitle string, query *database.SmartQuery) (database.Album, error) {
+	var result database.Album
+	err := c.do(ctx, "POST", pathOf(id(galleryId), "album", id(albumId)), nil, albumInput{Title: albumTitle, Query: query}, &result)
+	return result, err
+}
+
+// GetAlbumETag returns album and its ETag
+func (c *Client) GetAlbumETag(ctx context.Context, galleryId, albumId uint64) (database.Album, string, error) {
+	var result database.Album
+	etag, err := c.get(ctx, pathOf(id(galleryId), "album", id(albumId)), &result)
+	return result, etag, err
+}
+
+// UpdateAlbumFields applies patch to album. See UpdateGallery.
+func (c *Client) UpdateAlbumFields(ctx context.Context, galleryId, albumId uint64, patch database.AlbumPatch, etag string) (database.Album, string, error) {
+	var result database.Album
+	etag, err := c.update(ctx, pathOf(id(galleryId), "album", id(albumId)), patch.Replace, etag, patch, &result)
+	return result, etag, err
+}
+
+// DeleteAlbum deletes album
+func (c *Client) DeleteAlbum(ctx context.Context, galleryId, albumId uint64) error {
+	return c.do(ctx, "DELETE", pathOf(id(galleryId), "album", id(albumId)), nil, nil, nil)
+}
+
+// ListImages returns every image of album
+func (c *Client) ListImages(ctx context.Context, galleryId, albumId uint64) ([]database.Image, error) {
+	var result []database.Image
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "album", id(albumId), "images"), nil, nil, &result)
+	return result, err
+}
+
+// ListImagesPage returns page of images and cursor of next page
+func (c *Client) ListImagesPage(ctx context.Context, galleryId, albumId uint64, page database.Page) ([]database.Image, database.Cursor, error) {
+	var result []database.Image
+	next, err := c.doPage(ctx, pathOf(id(galleryId), "album", id(albumId), "images"), page, &result)
+	return result, next, err
+}
+
+// AddImage uploads image to album
+func (c *Client) AddImage(ctx context.Context, galleryId, albumId uint64, image io.Reader) (database.Image, error) {
+	var result database.Image
+
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	res, err := c.request(ctx, "POST", pathOf(id(galleryId), "album", id(albumId), "images"), nil, image, header)
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+
+	err = json.NewDecoder(res.Body).Decode(&result)
+	return result, err
+}
+
+// GetImage returns JPEG image, or its thumbnail, and modification time.
+func (c *Client) GetImage(ctx context.Context, galleryId, albumId, imageId uint64, thumbnail bool) ([]byte, time.Time, error) {
+	var query url.Values
+	if thumbnail {
+		query = url.Values{"thumb": {"1"}}
+	}
+
+	res, err := c.request(ctx, "GET", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId)), query, nil, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer res.Body.Close()
+
+	modified, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+
+	b, err := ioutil.ReadAll(res.Body)
+	return b, modified, err
+}
+
+// SetImageDescription sets image description
+func (c *Client) SetImageDescription(ctx context.Context, galleryId, albumId, imageId uint64, description string) (database.Image, error) {
+	var result database.Image
+	in := struct {
+		Description string `json:"description"`
+	}{description}
+	err := c.do(ctx, "POST", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId)), nil, in, &result)
+	return result, err
+}
+
+// GetImageInfo returns metadata of image and its ETag
+func (c *Client) GetImageInfo(ctx context.Context, galleryId, albumId, imageId uint64) (database.Image, string, error) {
+	var result database.Image
+	etag, err := c.get(ctx, pathOf(id(galleryId), "album", id(albumId), "image", id(imageId)), &result)
+	return result, etag, err
+}
+
+// UpdateImage applies patch to image. See UpdateGallery.
+func (c *Client) UpdateImage(ctx context.Context, galleryId, albumId, imageId uint64, patch database.ImagePatch, etag string) (database.Image, string, error) {
+	var result database.Image
+	etag, err := c.update(ctx, pathOf(id(galleryId), "album", id(albumId), "image", id(imageId)), patch.Replace, etag, patch, &result)
+	return result, etag, err
+}
+
+// DeleteImage deletes image
+func (c *Client) DeleteImage(ctx context.Context, galleryId, albumId, imageId uint64) error {
+	return c.do(ctx, "DELETE", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId)), nil, nil, nil)
+}
+
+// GetImageTags returns tags of image
+func (c *Client) GetImageTags(ctx context.Context, galleryId, albumId, imageId uint64) ([]string, error) {
+	var result []string
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId), "tags"), nil, nil, &result)
+	return result, err
+}
+
+// AddImageTags adds tags to image and returns resulting tags
+func (c *Client) AddImageTags(ctx context.Context, galleryId, albumId, imageId uint64, tags []string) ([]string, error) {
+	var result []string
+	in := struct {
+		Tags []string `json:"tags"`
+	}{tags}
+	err := c.do(ctx, "POST", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId), "tags"), nil, in, &result)
+	return result, err
+}
+
+// RemoveImageTag removes tag from image and returns remaining tags
+func (c *Client) RemoveImageTag(ctx context.Context, galleryId, albumId, imageId uint64, tag string) ([]string, error) {
+	var result []string
+	err := c.do(ctx, "DELETE", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId), "tag", tag), nil, nil, &result)
+	return result, err
+}
+
+// GetImageEdits returns edit list of image
+func (c *Client) GetImageEdits(ctx context.Context, galleryId, albumId, imageId uint64) ([]database.Edit, error) {
+	var result []database.Edit
+	err := c.do(ctx, "GET", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId), "edits"), nil, nil, &result)
+	return result, err
+}
+
+// SetImageEdits replaces edit list of image, which is rendered again from its original
+func (c *Client) SetImageEdits(ctx context.Context, galleryId, albumId, imageId uint64, edits []database.Edit) (database.Image, error) {
+	var result database.Image
+	err := c.do(ctx, "PUT", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId), "edits"), nil, edits, &result)
+	return result, err
+}
+
+// RevertImage reverts image to its original
+func (c *Client) RevertImage(ctx context.Context, galleryId, albumId, imageId uint64) (database.Image, error) {
+	var result database.Image
+	err := c.do(ctx, "DELETE", pathOf(id(galleryId), "album", id(albumId), "image", id(imageId), "edits"), nil, nil, &result)
+	return result, err
+}
+
+// Search returns galleries, albums and images matching query, highest score first.
+// Server default is used if limit is zero.
+func (c *Client) Search(ctx context.Context, query string, limit int) ([]database.SearchResult, error) {
+	q := url.Values{"q": {query}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+
+	var result []database.SearchResult
+	err := c.do(ctx, "GET", "/search", q, nil, &result)
+	return result, err
+}
+
+// GetAuditLog returns audit entries matching filter, newest first, and cursor of next page.
+func (c *Client) GetAuditLog(ctx context.Context, filter database.AuditFilter) ([]database.AuditEntry, uint64, error) {
+	q := make(url.Values)
+	if filter.Actor != "" {
+		q.Set("actor", filter.Actor)
+	}
+	if filter.Action != "" {
+		q.Set("action", filter.Action)
+	}
+	for k, v := range map[string]uint64{
+		"gallery": filter.GalleryId,
+		"album":   filter.AlbumId,
+		"image":   filter.ImageId,
+		"before":  filter.Before,
+	} {
+		if v != 0 {
+			q.Set(k, id(v))
+		}
+	}
+	if !filter.Since.IsZero() {
+		q.Set("since", filter.Since.Format(time.RFC3339))
+	}
+	if !filter.Until.IsZero() {
+		q.Set("until", filter.Until.Format(time.RFC3339))
+	}
+	if filter.Limit > 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+
+	var result struct {
+		Entries []database.AuditEntry `json:"entries"`
+		Next    uint64                `json:"next"`
+	}
+	err := c.do(ctx, "GET", "/audit", q, nil, &result)
+	return result.Entries, result.Next, err
+}
+
+// Events streams change events of gallery, or of every gallery if galleryId is zero.
+// Channel is closed when stream ends or ctx is done. Caller should reload state then.
+func (c *Client) Events(ctx context.Context, galleryId uint64) (<-chan database.Event, error) {
+	q := make(url.Values)
+	if galleryId != 0 {
+		q.Set("gallery", id(galleryId))
+	}
+
+	res, err := c.request(ctx, "GET", "/events", q, nil, http.Header{"Accept": {"text/event-stream"}})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan database.Event)
+	go func() {
+		defer close(events)
+		defer res.Body.Close()
+
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			line := sc.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+
+			var e database.Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+				return
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// ListWebhooks returns every webhook, without secrets
+func (c *Client) ListWebhooks(ctx context.Context) ([]database.Webhook, error) {
+	var result []database.Webhook
+	err := c.do(ctx, "GET", "/webhooks", nil, nil, &result)
+	return result, err
+}
+
+// CreateWebhook creates webhook. Secret is generated unless given, and returned only here.
+func (c *Client) CreateWebhook(ctx context.Context, w database.Webhook) (database.Webhook, error) {
+	var result database.Webhook
+	err := c.do(ctx, "POST", "/webhooks", nil, w, &result)
+	return result, err
+}
+
+// GetWebhook returns webhook, without secret
+func (c *Client) GetWebhook(ctx context.Context, webhookId uint64) (database.Webhook, error) {
+	var result database.Webhook
+	err := c.do(ctx, "GET", pathOf("webhook", id(webhookId)), nil, nil, &result)
+	return result, err
+}
+
+// DeleteWebhook deletes webhook and its queued deliveries
+func (c *Client) DeleteWebhook(ctx context.Context, webhookId uint64) error {
+	return c.do(ctx, "DELETE", pathOf("webhook", id(webhookId)), nil, nil, nil)
+}
+
+// ListDeliveries returns queued and failed deliveries of webhook
+func (c *Client) ListDeliveries(ctx context.Context, webhookId uint64) ([]database.Delivery, error) {
+	var result []database.Delivery
+	err := c.do(ctx, "GET", pathOf("webhook", id(webhookId), "deliveries"), nil, nil, &result)
+	return result, err
+}
+
+// ListJobs returns background jobs in queue order. Every status is returned if status is empty.
+func (c *Client) ListJobs(ctx context.Context, status string) ([]database.Job, error) {
+	var query url.Values
+	if status != "" {
+		query = url.Values{"status": {status}}
+	}
+
+	var result []database.Job
+	err := c.do(ctx, "GET", "/jobs", query, nil, &result)
+	return result, err
+}
+
+// ListDuplicates returns clusters of near-duplicate images across every gallery
+func (c *Client) ListDuplicates(ctx context.Context) ([][]database.Duplicate, error) {
+	var result [][]database.Duplicate
+	err := c.do(ctx, "GET", "/duplicates", nil, nil, &result)
+	return result, err
+}
+
+// RegenerateImages queues job regenerating thumbnails and renditions of album from stored originals.
+// Zero albumId regenerates every album of gallery, and zero galleryId every gallery.
+func (c *Client) RegenerateImages(ctx context.Context, galleryId, albumId uint64) (database.Job, error) {
+	in := struct {
+		Kind      string `json:"kind"`
+		GalleryId uint64 `json:"galleryId,omitempty"`
+		AlbumId   uint64 `json:"albumId,omitempty"`
+	}{database.JobRegenerateImages, galleryId, albumId}
+
+	var result database.Job
+	err := c.do(ctx, "POST", "/jobs", nil, in, &result)
+	return result, err
+}
+
+// GetJob returns background job
+func (c *Client) GetJob(ctx context.Context, jobId uint64) (database.Job, error) {
+	var result database.Job
+	err := c.do(ctx, "GET", pathOf("job", id(jobId)), nil, nil, &result)
+	return result, err
+}
+
+// RetryJob queues failed job again
+func (c *Client) RetryJob(ctx context.Context, jobId uint64) (database.Job, error) {
+	var result database.Job
+	err := c.do(ctx, "POST", pathOf("job", id(jobId), "retry"), nil, nil, &result)
+	return result, err
+}
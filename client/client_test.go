@@ -0,0 +1,238 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/dfkdream/gallery-plugin/api"
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/dfkdream/hugocms/plugin"
+	"github.com/dfkdream/hugocms/user"
+	"github.com/gorilla/mux"
+	"github.com/nfnt/resize"
+)
+
+func createTestImage() *bytes.Buffer {
+	i := image.NewRGBA(image.Rect(0, 0, 1280, 1280))
+	var b bytes.Buffer
+	err := jpeg.Encode(&b, i, &jpeg.Options{Quality: 80})
+	if err != nil {
+		panic(err)
+	}
+	return &b
+}
+
+// createTestServer serves API at /api/gallery, authenticating requests as user of X-User header.
+func createTestServer() *httptest.Server {
+	dpath, err := ioutil.TempDir("", "gallery-plugin-test-")
+	if err != nil {
+		panic(err)
+	}
+	b, err := bolt.Open(path.Join(dpath, "gallery.db"), os.FileMode(0644), nil)
+	if err != nil {
+		panic(err)
+	}
+	cfg := &config.Config{Interpolation: resize.Lanczos3, Quality: 80}
+	db, err := database.New(b, cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	m := mux.NewRouter()
+	api.New(db, cfg).SetupHandlers(m.PathPrefix("/api/gallery").Subrouter())
+
+	return httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		u := &user.User{Id: req.Header.Get("X-User"), Username: "world"}
+		m.ServeHTTP(res, req.WithContext(context.WithValue(req.Context(), plugin.ContextKeyUser, u)))
+	}))
+}
+
+func TestClient(t *testing.T) {
+	s := createTestServer()
+	defer s.Close()
+
+	c := New(s.URL+"/api/gallery/", nil)
+	c.Header.Set("X-User", "hello")
+	ctx := context.Background()
+
+	g, err := c.CreateGallery(ctx, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(g, database.Gallery{Id: 1, Title: "hello", Owner: "hello"}) {
+		t.Error("gallery not matches:", g)
+	}
+
+	stream, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := c.Events(stream, g.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := c.CreateAlbum(ctx, g.Id, "album")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := <-events; e.Entity != database.EntityAlbum || e.Action != database.ActionCreate || e.AlbumId != a.Id {
+		t.Error("event not matches:", e)
+	}
+
+	i, err := c.AddImage(ctx, g.Id, a.Id, createTestImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err = c.SetImageDescription(ctx, g.Id, a.Id, i.Id, "desc")
+	if err != nil || i.Description != "desc" {
+		t.Error("description not set:", i, err)
+	}
+
+	tags, err := c.AddImageTags(ctx, g.Id, a.Id, i.Id, []string{"Sky", "sea"})
+	if err != nil || !reflect.DeepEqual(tags, []string{"sea", "sky"}) {
+		t.Error("tags not matches:", tags, err)
+	}
+
+	tagged, err := c.ListImagesByTag(ctx, g.Id, "sky")
+	if err != nil || len(tagged) != 1 || tagged[0].Id != i.Id {
+		t.Error("tagged images not matches:", tagged, err)
+	}
+
+	tags, err = c.RemoveImageTag(ctx, g.Id, a.Id, i.Id, "sea")
+	if err != nil || !reflect.DeepEqual(tags, []string{"sky"}) {
+		t.Error("tags not matches:", tags, err)
+	}
+
+	thumb, _, err := c.GetImage(ctx, g.Id, a.Id, i.Id, true)
+	if err != nil {
+		t.Error(err)
+	} else if img, _, err := image.Decode(bytes.NewReader(thumb)); err != nil || img.Bounds() != image.Rect(0, 0, 360, 360) {
+		t.Error("thumbnail not matches:", err)
+	}
+
+	for idx := 0; idx < 2; idx++ {
+		if _, err := c.CreateAlbum(ctx, g.Id, "more"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	albums, next, err := c.ListAlbumsPage(ctx, g.Id, database.Page{Limit: 2})
+	if err != nil || len(albums) != 2 || next != (database.Cursor{Id: albums[1].Id}) {
+		t.Error("album page not matches:", albums, next, err)
+	}
+	albums, next, err = c.ListAlbumsPage(ctx, g.Id, database.Page{After: next, Limit: 2})
+	if err != nil || len(albums) != 1 || next != (database.Cursor{}) {
+		t.Error("album page not matches:", albums, next, err)
+	}
+
+	r, err := c.Search(ctx, "desc", 0)
+	if err != nil || len(r) != 1 || r[0].ImageId != i.Id {
+		t.Error("search result not matches:", r, err)
+	}
+
+	_, err = c.SetGrant(ctx, g.Id, database.Grant{UserId: "viewer", Role: database.RoleViewer})
+	if err != nil {
+		t.Error(err)
+	}
+
+	entries, _, err := c.GetAuditLog(ctx, database.AuditFilter{GalleryId: g.Id, Action: "grant.set"})
+	if err != nil || len(entries) != 1 || entries[0].UserId != "viewer" {
+		t.Error("audit log not matches:", entries, err)
+	}
+
+	viewer := New(s.URL+"/api/gallery", nil)
+	viewer.Header.Set("X-User", "viewer")
+	_, err = viewer.SetGalleryTitle(ctx, g.Id, "world")
+	if e, ok := err.(*Error); !ok || e.Status != 403 || e.Code != "forbidden" {
+		t.Error("error not matches:", err)
+	}
+
+	err = c.DeleteGallery(ctx, g.Id)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = c.GetGallery(ctx, g.Id)
+	if e, ok := err.(*Error); !ok || e.Status != 404 || e.Code != "gallery_not_found" {
+		t.Error("error not matches:", err)
+	}
+}
+
+func TestClient_Update(t *testing.T) {
+	s := createTestServer()
+	defer s.Close()
+
+	c := New(s.URL+"/api/gallery", nil)
+	c.Header.Set("X-User", "hello")
+	ctx := context.Background()
+
+	g, err := c.CreateGallery(ctx, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, tag, err := c.GetGalleryETag(ctx, g.Id)
+	if err != nil || tag == "" {
+		t.Fatal(tag, err)
+	}
+
+	title := "world"
+	g, next, err := c.UpdateGallery(ctx, g.Id, database.GalleryPatch{Title: &title}, tag)
+	if err != nil || g.Title != "world" || next == "" || next == tag {
+		t.Error("gallery not updated:", g, next, err)
+	}
+
+	_, _, err = c.UpdateGallery(ctx, g.Id, database.GalleryPatch{Title: &title}, tag)
+	if e, ok := err.(*Error); !ok || e.Status != 412 {
+		t.Error("stale etag accepted:", err)
+	}
+
+	g, _, err = c.UpdateGallery(ctx, g.Id, database.GalleryPatch{Replace: true}, "")
+	if err != nil || !reflect.DeepEqual(g, database.Gallery{Id: 1, Owner: "hello"}) {
+		t.Error("gallery not replaced:", g, err)
+	}
+
+	a, _ := c.CreateAlbum(ctx, g.Id, "album")
+	i, err := c.AddImage(ctx, g.Id, a.Id, createTestImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, tag, err = c.GetImageInfo(ctx, g.Id, a.Id, i.Id)
+	if err != nil || tag == "" {
+		t.Fatal(tag, err)
+	}
+	desc := "desc"
+	i, _, err = c.UpdateImage(ctx, g.Id, a.Id, i.Id, database.ImagePatch{Description: &desc}, tag)
+	if err != nil || i.Description != "desc" {
+		t.Error("image not updated:", i, err)
+	}
+
+	order := 1
+	r, err := c.Batch(ctx, g.Id, []database.BatchOperation{{Op: database.BatchReorder, AlbumId: a.Id, ImageId: i.Id, Order: &order}})
+	if err != nil || len(r) != 1 || r[0].Image == nil || r[0].Image.Order != 1 {
+		t.Error("batch not applied:", r, err)
+	}
+	_, err = c.Batch(ctx, g.Id, []database.BatchOperation{{Op: database.BatchDelete, AlbumId: a.Id, ImageId: i.Id + 1}})
+	if e, ok := err.(*Error); !ok || e.Code != "image_not_found" {
+		t.Error("batch error not matches:", err)
+	}
+
+	unlisted := database.VisibilityUnlisted
+	a, _, err = c.UpdateAlbumFields(ctx, g.Id, a.Id, database.AlbumPatch{Visibility: &unlisted}, "")
+	if err != nil || a.Visibility != unlisted {
+		t.Error("album not updated:", a, err)
+	}
+}
@@ -12,18 +12,36 @@ type Config struct {
 	BoltPath      string `json:"boltPath"`
 	Interpolation resize.InterpolationFunction
 	Quality       int `json:"quality"`
+	// LegacyResponses makes gallery, album and image mutation endpoints reply with plain text id
+	// and 200 OK, for admin UI builds predating JSON responses.
+	LegacyResponses bool `json:"legacyResponses"`
+	// WebhookMaxAttempts is number of delivery attempts before webhook delivery is given up.
+	WebhookMaxAttempts int `json:"webhookMaxAttempts"`
+	// DeepZoomThreshold is width or height above which tile pyramid is generated at upload.
+	// Zero disables generation at upload.
+	DeepZoomThreshold int `json:"deepZoomThreshold"`
+	// TileSize is edge length of deep zoom tiles, without overlap
+	TileSize int `json:"tileSize"`
+	// ProcessingWorkers is number of workers rendering uploaded images in background.
+	// Zero renders uploads synchronously, and runs other jobs on single worker.
+	ProcessingWorkers int `json:"processingWorkers"`
 }
 
 func Get() *Config {
 	return &Config{
-		BoltPath:      getEnvStringOr("BOLT", "./gallery.db"),
-		Interpolation: getEnvInterpolationOr("INTERPOLATION", resize.Lanczos3),
-		Quality:       getEnvIntOr("QUALITY", 80),
+		BoltPath:           getEnvStringOr("BOLT", "./gallery.db"),
+		Interpolation:      getEnvInterpolationOr("INTERPOLATION", resize.Lanczos3),
+		Quality:            getEnvIntOr("QUALITY", 80),
+		LegacyResponses:    getEnvBoolOr("LEGACY_RESPONSES", false),
+		WebhookMaxAttempts: getEnvIntOr("WEBHOOK_MAX_ATTEMPTS", 8),
+		DeepZoomThreshold:  getEnvIntOr("DEEPZOOM_THRESHOLD", 4096),
+		TileSize:           getEnvIntOr("TILE_SIZE", 254),
+		ProcessingWorkers:  getEnvIntOr("PROCESSING_WORKERS", 2),
 	}
 }
 
 func (c Config) String() string {
-	return fmt.Sprintf("BoltPath: %s\nInterpolation: %d\nQuality: %d", c.BoltPath, c.Interpolation, c.Quality)
+	return fmt.Sprintf("BoltPath: %s\nInterpolation: %d\nQuality: %d\nLegacyResponses: %t\nWebhookMaxAttempts: %d\nDeepZoomThreshold: %d\nTileSize: %d\nProcessingWorkers: %d", c.BoltPath, c.Interpolation, c.Quality, c.LegacyResponses, c.WebhookMaxAttempts, c.DeepZoomThreshold, c.TileSize, c.ProcessingWorkers)
 }
 
 func getEnvStringOr(key string, defaultValue string) string {
@@ -34,7 +52,7 @@ func getEnvStringOr(key string, defaultValue string) string {
 }
 
 func getEnvInterpolationOr(key string, defaultValue resize.InterpolationFunction) resize.InterpolationFunction {
-	switch key {
+	switch os.Getenv(key) {
 	case "NearestNeighbor":
 		return resize.NearestNeighbor
 	case "Bilinear":
@@ -58,3 +76,10 @@ func getEnvIntOr(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvBoolOr(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
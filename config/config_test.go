@@ -0,0 +1,29 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+func TestGetEnvInterpolationOr(t *testing.T) {
+	for idx, v := range []struct {
+		value  string
+		result resize.InterpolationFunction
+	}{
+		{"", resize.Lanczos3},
+		{"NearestNeighbor", resize.NearestNeighbor},
+		{"Bilinear", resize.Bilinear},
+		{"Lanczos2", resize.Lanczos2},
+		{"bogus", resize.Lanczos3},
+	} {
+		if err := os.Setenv("INTERPOLATION", v.value); err != nil {
+			t.Fatal(err)
+		}
+		if result := Get().Interpolation; result != v.result {
+			t.Errorf("Test %d failed. expected: %d, result:%d", idx, v.result, result)
+		}
+	}
+	_ = os.Unsetenv("INTERPOLATION")
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var auditBucket = []byte("audit")
+
+// AuditEntry records single mutation performed through the API.
+// Before and After hold JSON of entity before and after mutation, and are empty if it did not exist.
+// Tag actions record tags of image instead.
+type AuditEntry struct {
+	Id        uint64    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	GalleryId uint64    `json:"galleryId,omitempty"`
+	AlbumId   uint64    `json:"albumId,omitempty"`
+	ImageId   uint64    `json:"imageId,omitempty"`
+	UserId    string    `json:"userId,omitempty"`
+	Before    string    `json:"before,omitempty"`
+	After     string    `json:"after,omitempty"`
+}
+
+// AuditFilter selects audit entries.
+// Zero values match every entry.
+type AuditFilter struct {
+	Actor     string
+	Action    string
+	GalleryId uint64
+	AlbumId   uint64
+	ImageId   uint64
+	Since     time.Time
+	Until     time.Time
+	// Before returns entries older than entry with given id
+	Before uint64
+	Limit  int
+}
+
+func (f AuditFilter) match(e AuditEntry) bool {
+	if f.Actor != "" && f.Actor != e.Actor {
+		return false
+	}
+	if f.Action != "" && f.Action != e.Action {
+		return false
+	}
+	if f.GalleryId != 0 && f.GalleryId != e.GalleryId {
+		return false
+	}
+	if f.AlbumId != 0 && f.AlbumId != e.AlbumId {
+		return false
+	}
+	if f.ImageId != 0 && f.ImageId != e.ImageId {
+		return false
+	}
+	if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// AppendAudit appends entry to audit log and returns its id.
+// Entries can not be modified or deleted once appended.
+// Entry is queued for delivery to webhooks subscribing to its action in the same transaction.
+func (d *Database) AppendAudit(entry AuditEntry) (uint64, error) {
+	queued := 0
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(auditBucket)
+
+		var err error
+		entry.Id, err = b.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.Timestamp = time.Now()
+
+		v, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		err = b.Put(itob(entry.Id), v)
+		if err != nil {
+			return err
+		}
+
+		queued, err = enqueueDeliveries(tx, entry)
+		return err
+	})
+
+	if err == nil && queued > 0 {
+		d.notifyQueued()
+	}
+	return entry.Id, err
+}
+
+// GetAuditLog returns entries matching filter, newest first.
+func (d *Database) GetAuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	result := make([]AuditEntry, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(auditBucket).Cursor()
+		var k, v []byte
+		if filter.Before != 0 {
+			k, v = c.Seek(itob(filter.Before))
+			if k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		} else {
+			k, v = c.Last()
+		}
+
+		for ; k != nil; k, v = c.Prev() {
+			if filter.Limit > 0 && len(result) >= filter.Limit {
+				break
+			}
+
+			var e AuditEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+
+			if !filter.Since.IsZero() && e.Timestamp.Before(filter.Since) {
+				break
+			}
+
+			if filter.match(e) {
+				result = append(result, e)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
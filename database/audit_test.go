@@ -0,0 +1,68 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDatabase_AppendAudit(t *testing.T) {
+	db := createTestDB()
+	for i := 0; i < 3; i++ {
+		id, err := db.AppendAudit(AuditEntry{Actor: "test-user", Action: "gallery.create", GalleryId: uint64(i + 1)})
+		if err != nil {
+			t.Error(err)
+		}
+		if id != uint64(i+1) {
+			t.Errorf("%d != %d", id, i+1)
+		}
+	}
+}
+
+func TestDatabase_GetAuditLog(t *testing.T) {
+	db := createTestDB()
+	start := time.Now().Add(-100 * time.Millisecond)
+	for _, e := range []AuditEntry{
+		{Actor: "alice", Action: "gallery.create", GalleryId: 1, After: `{"title":"a"}`},
+		{Actor: "bob", Action: "album.create", GalleryId: 1, AlbumId: 1, After: `{"title":"b"}`},
+		{Actor: "alice", Action: "album.delete", GalleryId: 1, AlbumId: 1, Before: `{"title":"b"}`},
+		{Actor: "alice", Action: "gallery.create", GalleryId: 2, After: `{"title":"c"}`},
+	} {
+		_, err := db.AppendAudit(e)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	for idx, v := range []struct {
+		filter AuditFilter
+		ids    []uint64
+	}{
+		{AuditFilter{}, []uint64{4, 3, 2, 1}},
+		{AuditFilter{Actor: "alice"}, []uint64{4, 3, 1}},
+		{AuditFilter{GalleryId: 1, AlbumId: 1}, []uint64{3, 2}},
+		{AuditFilter{Action: "gallery.create", Limit: 1}, []uint64{4}},
+		{AuditFilter{Before: 3, Limit: 1}, []uint64{2}},
+		{AuditFilter{Since: start}, []uint64{4, 3, 2, 1}},
+		{AuditFilter{Until: start}, []uint64{}},
+	} {
+		e, err := db.GetAuditLog(v.filter)
+		if err != nil {
+			t.Error(idx, err)
+			continue
+		}
+		ids := make([]uint64, 0)
+		for _, i := range e {
+			ids = append(ids, i.Id)
+		}
+		if len(ids) != len(v.ids) {
+			t.Errorf("Test %d failed. expected: %v, result:%v", idx, v.ids, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != v.ids[i] {
+				t.Errorf("Test %d failed. expected: %v, result:%v", idx, v.ids, ids)
+				break
+			}
+		}
+	}
+}
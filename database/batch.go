@@ -0,0 +1,268 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+var ErrInvalidBatch = errors.New("invalid batch operation")
+
+const MaxBatchOperations = 500
+
+// Operations of batch
+const (
+	BatchDelete   = "delete"
+	BatchDescribe = "describe"
+	BatchTag      = "tag"
+	BatchUntag    = "untag"
+	BatchMove     = "move"
+	BatchReorder  = "reorder"
+)
+
+// BatchOperation is single operation of batch on image of gallery.
+// Description is used by describe, Tags by tag and untag,
+// ToAlbumId by move and Order by reorder.
+type BatchOperation struct {
+	Op          string   `json:"op"`
+	AlbumId     uint64   `json:"albumId"`
+	ImageId     uint64   `json:"imageId"`
+	Description *string  `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	ToAlbumId   uint64   `json:"toAlbumId,omitempty"`
+	Order       *int     `json:"order,omitempty"`
+}
+
+// BatchResult is result of batch operation.
+// Image is nil for deleted image. Moved image gets new id in its new album.
+type BatchResult struct {
+	Before Image  `json:"-"`
+	Image  *Image `json:"image,omitempty"`
+}
+
+// BatchError is returned when operation at Index fails.
+// No operation of batch is applied then.
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch operation %d: %v", e.Index, e.Err)
+}
+
+// normalize validates operation and normalizes its tags
+func (op BatchOperation) normalize() (BatchOperation, error) {
+	var err error
+	switch op.Op {
+	case BatchDelete:
+	case BatchDescribe:
+		if op.Description == nil {
+			return op, ErrInvalidBatch
+		}
+	case BatchTag, BatchUntag:
+		if len(op.Tags) == 0 {
+			return op, ErrInvalidBatch
+		}
+		op.Tags, err = normalizeTags(op.Tags)
+	case BatchMove:
+		if op.ToAlbumId == 0 {
+			return op, ErrInvalidBatch
+		}
+	case BatchReorder:
+		if op.Order == nil {
+			return op, ErrInvalidBatch
+		}
+	default:
+		return op, ErrInvalidBatch
+	}
+	return op, err
+}
+
+// Batch applies operations to images of gallery in a single transaction,
+// so either every operation is applied or none is.
+// If check is not nil, it is called with each operation and current image before applying it,
+// and batch is aborted when it returns error.
+// Errors of operations are returned as *BatchError.
+func (d *Database) Batch(galleryId uint64, ops []BatchOperation, check func(BatchOperation, Image) error) ([]BatchResult, error) {
+	if len(ops) == 0 || len(ops) > MaxBatchOperations {
+		return nil, ErrInvalidBatch
+	}
+
+	normalized := make([]BatchOperation, len(ops))
+	for idx, op := range ops {
+		var err error
+		normalized[idx], err = op.normalize()
+		if err != nil {
+			return nil, &BatchError{Index: idx, Err: err}
+		}
+	}
+
+	var result []BatchResult
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		result = make([]BatchResult, len(normalized))
+		for idx, op := range normalized {
+			r, err := applyBatchOperation(tx, galleryId, op, check)
+			if err != nil {
+				return &BatchError{Index: idx, Err: err}
+			}
+			result[idx] = r
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for idx, op := range normalized {
+		e := Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: galleryId, AlbumId: op.AlbumId, ImageId: op.ImageId}
+		switch op.Op {
+		case BatchDelete:
+			e.Action = ActionDelete
+		case BatchMove:
+			if i := result[idx].Image; i.AlbumId != op.AlbumId {
+				e.Action = ActionDelete
+				events = append(events, Event{Entity: EntityImage, Action: ActionCreate, GalleryId: galleryId, AlbumId: i.AlbumId, ImageId: i.Id})
+			}
+		}
+		events = append(events, e)
+	}
+	d.publish(nil, events...)
+	d.notifyJobs()
+
+	return result, nil
+}
+
+func applyBatchOperation(tx *bolt.Tx, galleryId uint64, op BatchOperation, check func(BatchOperation, Image) error) (BatchResult, error) {
+	var result BatchResult
+
+	g, i, err := imageBuckets(tx, galleryId, op.AlbumId, op.ImageId)
+	if err != nil {
+		return result, err
+	}
+	result.Before = readImage(op.ImageId, g, i)
+	result.Before.AlbumId = op.AlbumId
+
+	if check != nil {
+		if err := check(op, result.Before); err != nil {
+			return result, err
+		}
+	}
+
+	albumId, imageId := op.AlbumId, op.ImageId
+	switch op.Op {
+	case BatchDelete:
+		return result, deleteImage(tx, galleryId, albumId, imageId)
+	case BatchDescribe:
+		_, err = updateImage(tx, galleryId, albumId, imageId, ImagePatch{Description: op.Description}, nil)
+	case BatchReorder:
+		_, err = updateImage(tx, galleryId, albumId, imageId, ImagePatch{Order: op.Order}, nil)
+	case BatchTag:
+		_, err = addImageTags(tx, galleryId, albumId, imageId, op.Tags)
+	case BatchUntag:
+		for _, tag := range op.Tags {
+			if err = removeImageTag(tx, galleryId, albumId, imageId, tag); err != nil {
+				break
+			}
+		}
+	case BatchMove:
+		albumId = op.ToAlbumId
+		imageId, err = moveImage(tx, galleryId, op.AlbumId, op.ImageId, op.ToAlbumId)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	g, i, err = imageBuckets(tx, galleryId, albumId, imageId)
+	if err != nil {
+		return result, err
+	}
+	img := readImage(imageId, g, i)
+	img.AlbumId = albumId
+	result.Image = &img
+	return result, nil
+}
+
+// copyBucket copies every key and nested bucket of src into dst
+func copyBucket(dst, src *bolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			b, err := dst.CreateBucketIfNotExists(k)
+			if err != nil {
+				return err
+			}
+			return copyBucket(b, src.Bucket(k))
+		}
+		return dst.Put(append([]byte{}, k...), append([]byte{}, v...))
+	})
+}
+
+// moveImage moves image to another album of the same gallery and returns its new id.
+// Image keeps its tags, and is reindexed under new album.
+func moveImage(tx *bolt.Tx, galleryId, albumId, imageId, toAlbumId uint64) (uint64, error) {
+	if albumId == toAlbumId {
+		return imageId, nil
+	}
+
+	g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+	if err != nil {
+		return 0, err
+	}
+
+	to := g.Bucket(albumsBucket).Bucket(itob(toAlbumId))
+	if to == nil {
+		return 0, ErrAlbumNotFound
+	}
+	if to.Get(queryKey) != nil {
+		return 0, ErrSmartAlbum
+	}
+
+	imgs := to.Bucket(imagesBucket)
+	id, err := imgs.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+
+	moved, err := imgs.CreateBucket(itob(id))
+	if err != nil {
+		return 0, err
+	}
+	if err := copyBucket(moved, i); err != nil {
+		return 0, err
+	}
+	if err := retainBlob(moved); err != nil {
+		return 0, err
+	}
+
+	tags := readTags(i)
+	if err := deleteImage(tx, galleryId, albumId, imageId); err != nil {
+		return 0, err
+	}
+
+	if len(tags) > 0 {
+		// restores reverse index under new album
+		if _, err := addImageTags(tx, galleryId, toAlbumId, id, tags); err != nil {
+			return 0, err
+		}
+	}
+
+	if moved.Get(statusKey) != nil {
+		// job of old id is dropped when it runs
+		if err := moved.Put(statusKey, []byte(ImagePending)); err != nil {
+			return 0, err
+		}
+		if err := enqueueJob(tx, JobProcessImage, galleryId, toAlbumId, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if h := moved.Get(hashKey); h != nil {
+		if err := indexHash(tx, documentRef(galleryId, toAlbumId, id), btoi(h)); err != nil {
+			return 0, err
+		}
+	}
+
+	return id, indexDocument(tx, documentRef(galleryId, toAlbumId, id), imageText(moved))
+}
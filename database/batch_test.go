@@ -0,0 +1,77 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDatabase_Batch(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	if _, err := db.AddImageTags(gid, 1, 1, []string{"sunset"}); err != nil {
+		t.Fatal(err)
+	}
+
+	order := 2
+	r, err := db.Batch(gid, []BatchOperation{
+		{Op: BatchDescribe, AlbumId: 1, ImageId: 1, Description: stringPtr("moved")},
+		{Op: BatchReorder, AlbumId: 1, ImageId: 1, Order: &order},
+		{Op: BatchTag, AlbumId: 2, ImageId: 1, Tags: []string{" Sea "}},
+		{Op: BatchMove, AlbumId: 1, ImageId: 1, ToAlbumId: 2},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r) != 4 || r[0].Before.Description != "" || !reflect.DeepEqual(r[2].Image.Tags, []string{"sea"}) {
+		t.Errorf("Assertion Failed: %+v", r)
+	}
+
+	moved := r[3].Image
+	if moved.Id != 2 || moved.AlbumId != 2 || moved.Description != "moved" || moved.Order != 2 || !reflect.DeepEqual(moved.Tags, []string{"sunset"}) {
+		t.Errorf("Assertion Failed: %+v", moved)
+	}
+	if _, err := db.GetImageInfo(gid, 1, 1); err != ErrImageNotFound {
+		t.Errorf("%v != %v", err, ErrImageNotFound)
+	}
+	if i, err := db.GetImagesByTag(gid, "sunset", false); err != nil || len(i) != 1 || i[0].AlbumId != 2 || i[0].Id != 2 {
+		t.Errorf("Assertion Failed: %+v %v", i, err)
+	}
+	if s, err := db.Search("moved", 0, SearchScope{}); err != nil || len(s) != 1 || s[0].AlbumId != 2 || s[0].ImageId != 2 {
+		t.Errorf("Assertion Failed: %+v %v", s, err)
+	}
+	if b, _, err := db.GetImage(gid, 2, 2); err != nil || len(b) == 0 {
+		t.Error("image data not moved:", err)
+	}
+
+	// reordered image is listed first
+	order = -1
+	if _, err := db.Batch(gid, []BatchOperation{{Op: BatchReorder, AlbumId: 2, ImageId: 2, Order: &order}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := db.GetImages(gid, 2); err != nil || len(i) != 2 || i[0].Id != 2 || i[1].Id != 1 {
+		t.Errorf("Assertion Failed: %+v %v", i, err)
+	}
+
+	// failing operation rolls back whole batch
+	_, err = db.Batch(gid, []BatchOperation{
+		{Op: BatchDelete, AlbumId: 2, ImageId: 1},
+		{Op: BatchUntag, AlbumId: 2, ImageId: 2, Tags: []string{"missing"}},
+	}, nil)
+	if e, ok := err.(*BatchError); !ok || e.Index != 1 || e.Err != ErrTagNotFound {
+		t.Errorf("Assertion Failed: %v", err)
+	}
+	if _, err := db.GetImageInfo(gid, 2, 1); err != nil {
+		t.Error("batch not rolled back:", err)
+	}
+
+	for idx, ops := range [][]BatchOperation{
+		nil,
+		{{Op: "rename", AlbumId: 2, ImageId: 1}},
+		{{Op: BatchDescribe, AlbumId: 2, ImageId: 1}},
+		{{Op: BatchTag, AlbumId: 2, ImageId: 1, Tags: []string{"a/b"}}},
+	} {
+		_, err := db.Batch(gid, ops, nil)
+		if err == nil {
+			t.Errorf("%d: invalid batch accepted", idx)
+		}
+	}
+}
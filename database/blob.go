@@ -0,0 +1,151 @@
+package database
+
+import (
+	"crypto/sha256"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	blobsBucket = []byte("blobs")
+	blobKey     = []byte("blob")
+	refsKey     = []byte("refs")
+	sourceKey   = []byte("source")
+)
+
+// blobSum returns content address of uploaded bytes
+func blobSum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// imageData returns bucket holding original and renditions of image bucket i.
+// Identical uploads share blob holding them, referred by blobKey of image.
+// Images stored before blobs hold their data themselves.
+func imageData(i *bolt.Bucket) *bolt.Bucket {
+	if k := i.Get(blobKey); k != nil {
+		if b := i.Tx().Bucket(blobsBucket).Bucket(k); b != nil {
+			return b
+		}
+	}
+	return i
+}
+
+// readOriginal returns original of image data bucket.
+// Blob of edited image holds no original, but refers blob of its unedited original as source.
+func readOriginal(data *bolt.Bucket) []byte {
+	if s := data.Get(sourceKey); s != nil {
+		if b := data.Tx().Bucket(blobsBucket).Bucket(s); b != nil {
+			return b.Get(originalKey)
+		}
+	}
+	return data.Get(originalKey)
+}
+
+// createBlob returns blob of sum, creating it holding original unless identical upload is stored.
+func createBlob(tx *bolt.Tx, sum, original []byte) (*bolt.Bucket, error) {
+	blobs := tx.Bucket(blobsBucket)
+	if b := blobs.Bucket(sum); b != nil {
+		return b, nil
+	}
+
+	b, err := blobs.CreateBucket(sum)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Put(originalKey, original); err != nil {
+		return nil, err
+	}
+	return b, putFiles(b)
+}
+
+// putBlob makes image bucket i refer blob of sum, and returns the blob.
+// Blob holding original is created unless identical upload is stored.
+func putBlob(tx *bolt.Tx, i *bolt.Bucket, sum, original []byte) (*bolt.Bucket, error) {
+	b, err := createBlob(tx, sum, original)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.Put(blobKey, sum); err != nil {
+		return nil, err
+	}
+	return b, retainBlob(i)
+}
+
+func readRefs(b *bolt.Bucket) uint64 {
+	if v := b.Get(refsKey); v != nil {
+		return btoi(v)
+	}
+	return 0
+}
+
+// putShared marks image bucket i referred by ref processed, sharing rendition already stored in its blob.
+// Watermarked renditions w are kept by image, as watermark differs by gallery.
+func putShared(tx *bolt.Tx, ref []byte, i *bolt.Bucket, w watermarked) error {
+	if h := imageData(i).Get(hashKey); h != nil {
+		if err := putHash(tx, i, ref, btoi(h)); err != nil {
+			return err
+		}
+	}
+	if err := putWatermarked(tx, ref, i, w); err != nil {
+		return err
+	}
+	return clearStatus(i)
+}
+
+// retainBlob counts reference of image bucket i to its blob
+func retainBlob(i *bolt.Bucket) error {
+	k := i.Get(blobKey)
+	if k == nil {
+		return nil
+	}
+	b := i.Tx().Bucket(blobsBucket).Bucket(k)
+	if b == nil {
+		return nil
+	}
+	return b.Put(refsKey, itob(readRefs(b)+1))
+}
+
+// releaseBlob removes reference of image bucket i to its blob, and deletes blob no image refers
+func releaseBlob(i *bolt.Bucket) error {
+	k := i.Get(blobKey)
+	if k == nil {
+		return nil
+	}
+	return unrefBlob(i.Tx().Bucket(blobsBucket), k)
+}
+
+// unrefBlob removes reference to blob of sum, and deletes blob nothing refers.
+// Deleted blob of edited image releases its source in turn.
+func unrefBlob(blobs *bolt.Bucket, sum []byte) error {
+	b := blobs.Bucket(sum)
+	if b == nil {
+		return nil
+	}
+
+	refs := readRefs(b)
+	if refs > 1 {
+		return b.Put(refsKey, itob(refs-1))
+	}
+
+	source := append([]byte(nil), b.Get(sourceKey)...)
+	if err := blobs.DeleteBucket(sum); err != nil {
+		return err
+	}
+	if len(source) == 0 {
+		return nil
+	}
+	return unrefBlob(blobs, source)
+}
+
+// releaseAlbumBlobs releases blobs of every image of album bucket a
+func releaseAlbumBlobs(a *bolt.Bucket) error {
+	imgs := a.Bucket(imagesBucket)
+	return imgs.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return nil
+		}
+		return releaseBlob(imgs.Bucket(k))
+	})
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// blobRefs returns reference count of every stored blob
+func blobRefs(db *Database) []uint64 {
+	var result []uint64
+	_ = db.db.View(func(tx *bolt.Tx) error {
+		blobs := tx.Bucket(blobsBucket)
+		return blobs.ForEach(func(k, v []byte) error {
+			result = append(result, readRefs(blobs.Bucket(k)))
+			return nil
+		})
+	})
+	return result
+}
+
+func TestDatabase_SharedBlob(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	other, _ := db.CreateAlbum(gid, "other", "test-user")
+	ogid, _ := db.CreateGallery("other", "test-user")
+	oaid, _ := db.CreateAlbum(ogid, "test-album", "test-user")
+
+	add := func(gid, aid uint64) uint64 {
+		img := createTestImage()
+		iid, err := db.AddImage(gid, aid, "test-user", &img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return iid
+	}
+
+	first := add(gid, aid)
+	second := add(gid, other)
+	_ = add(ogid, oaid)
+	distinct := createGradientImage(640, 480, false)
+	_, _ = db.AddImage(gid, aid, "test-user", &distinct)
+
+	if r := blobRefs(db); len(r) != 2 {
+		t.Fatalf("Assertion Failed: %v", r)
+	}
+
+	i1, _, _ := db.GetImage(gid, aid, first)
+	i2, _, _ := db.GetImage(gid, other, second)
+	if len(i1) == 0 || !bytes.Equal(i1, i2) {
+		t.Error("image of shared blob not matches")
+	}
+	if dup, _ := db.GetDuplicates(gid, other, second); len(dup) != 1 || dup[0].ImageId != first {
+		t.Errorf("Assertion Failed: %+v", dup)
+	}
+
+	// moved image keeps reference
+	r, err := db.Batch(gid, []BatchOperation{{Op: BatchMove, AlbumId: aid, ImageId: first, ToAlbumId: other}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := r[0].Image.Id
+
+	if err := db.DeleteImage(gid, other, second); err != nil {
+		t.Fatal(err)
+	}
+	if i, _, err := db.GetImage(gid, other, moved); err != nil || !bytes.Equal(i, i1) {
+		t.Error("blob freed while referenced:", err)
+	}
+
+	if err := db.DeleteAlbum(gid, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.GetThumbnail(ogid, oaid, 1); err != nil {
+		t.Error("blob freed while referenced:", err)
+	}
+
+	if err := db.DeleteGallery(ogid); err != nil {
+		t.Fatal(err)
+	}
+	if r := blobRefs(db); len(r) != 1 || r[0] != 1 {
+		t.Errorf("Assertion Failed: %v", r)
+	}
+}
+
+func TestDatabase_SharedBlobJob(t *testing.T) {
+	db, gid, aid := createAsyncTestDB()
+
+	img := createTestImage()
+	first, _ := db.AddImage(gid, aid, "test-user", &img)
+	img = createTestImage()
+	second, _ := db.AddImage(gid, aid, "test-user", &img)
+
+	runJobs(db)
+
+	for _, iid := range []uint64{first, second} {
+		if i, _ := db.GetImageInfo(gid, aid, iid); i.Status != "" {
+			t.Errorf("%q != %q", i.Status, "")
+		}
+	}
+
+	// identical upload of rendered image is not queued
+	img = createTestImage()
+	third, _ := db.AddImage(gid, aid, "test-user", &img)
+	if i, _ := db.GetImageInfo(gid, aid, third); i.Status != "" {
+		t.Errorf("%q != %q", i.Status, "")
+	}
+	if jobs, _ := db.GetJobs(""); len(jobs) != 0 {
+		t.Errorf("Assertion Failed: %+v", jobs)
+	}
+	if r := blobRefs(db); len(r) != 1 || r[0] != 3 {
+		t.Errorf("Assertion Failed: %v", r)
+	}
+}
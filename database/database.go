@@ -1,25 +1,24 @@
 package database
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
-	"image"
 	_ "image/gif"
-	"image/jpeg"
 	_ "image/png"
 	"io"
+	"io/ioutil"
 	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/dfkdream/gallery-plugin/config"
-	"github.com/nfnt/resize"
 )
 
 var (
-	ErrGalleryNotFound = errors.New("gallery not found")
-	ErrAlbumNotFound   = errors.New("album not found")
-	ErrImageNotFound   = errors.New("image not found")
+	ErrGalleryNotFound   = errors.New("gallery not found")
+	ErrAlbumNotFound     = errors.New("album not found")
+	ErrImageNotFound     = errors.New("image not found")
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrInvalidImage      = errors.New("invalid image data")
 )
 
 var (
@@ -31,24 +30,46 @@ var (
 	thumbnailKey   = []byte("thumbnail")
 	timestampKey   = []byte("timestamp")
 	descriptionKey = []byte("description")
+	ownerKey       = []byte("owner")
 )
 
 type Database struct {
-	db  *bolt.DB
-	cfg *config.Config
+	db     *bolt.DB
+	cfg    *config.Config
+	queued chan struct{}
+	jobs   chan struct{}
+	hub    hub
 }
 
 func New(db *bolt.DB, cfg *config.Config) (*Database, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(galleryBucket)
-		return err
+		for _, b := range [][]byte{galleryBucket, auditBucket, webhooksBucket, deliveriesBucket, jobsBucket, blobsBucket} {
+			_, err := tx.CreateBucketIfNotExists(b)
+			if err != nil {
+				return err
+			}
+		}
+
+		if tx.Bucket(searchBucket) == nil {
+			if err := rebuildSearchIndex(tx); err != nil {
+				return err
+			}
+		}
+
+		if tx.Bucket(hashesBucket) == nil {
+			if err := rebuildHashIndex(tx); err != nil {
+				return err
+			}
+		}
+
+		return migrate(tx)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &Database{db: db, cfg: cfg}, nil
+	return &Database{db: db, cfg: cfg, queued: make(chan struct{}, 1), jobs: make(chan struct{}, 1)}, nil
 }
 
 func itob(id uint64) []byte {
@@ -61,28 +82,54 @@ func btoi(v []byte) uint64 {
 	return binary.BigEndian.Uint64(v)
 }
 
+// Gallery is metadata of gallery.
+// Empty Visibility means VisibilityPublic.
 type Gallery struct {
-	Id    uint64 `json:"id"`
-	Title string `json:"title"`
+	Id         uint64            `json:"id"`
+	Title      string            `json:"title"`
+	Owner      string            `json:"owner"`
+	Visibility Visibility        `json:"visibility,omitempty"`
+	Order      int               `json:"order,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
+// readGallery reads metadata from gallery bucket
+func readGallery(id uint64, g *bolt.Bucket) Gallery {
+	return Gallery{
+		Id:         id,
+		Title:      string(g.Get(titleKey)),
+		Owner:      string(g.Get(ownerKey)),
+		Visibility: readVisibility(g),
+		Order:      readOrder(g),
+		Metadata:   readMetadata(g),
+	}
+}
+
+// GetGalleries returns every gallery, including unlisted ones
 func (d *Database) GetGalleries() ([]Gallery, error) {
+	result, _, err := d.GetGalleriesPage(Page{Unlisted: true})
+	return result, err
+}
+
+// GetGalleriesPage returns galleries in page, and cursor of next page
+func (d *Database) GetGalleriesPage(page Page) ([]Gallery, Cursor, error) {
 	result := make([]Gallery, 0)
+	var next Cursor
 	err := d.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(galleryBucket)
-		c := b.Cursor()
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			result = append(result, Gallery{
-				Id:    btoi(k),
-				Title: string(b.Bucket(k).Get(titleKey)),
-			})
+		var keys [][]byte
+		keys, next = page.keys(b, func(g *bolt.Bucket) bool {
+			return listed(g, page) || granted(g, page.UserId)
+		})
+		for _, k := range keys {
+			result = append(result, readGallery(btoi(k), b.Bucket(k)))
 		}
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, Cursor{}, err
 	}
-	return result, err
+	return result, next, err
 }
 
 func (d *Database) GetGallery(galleryId uint64) (Gallery, error) {
@@ -93,16 +140,16 @@ func (d *Database) GetGallery(galleryId uint64) (Gallery, error) {
 		if b == nil {
 			return ErrGalleryNotFound
 		}
-		result.Title = string(b.Get(titleKey))
-		result.Id = galleryId
+		result = readGallery(galleryId, b)
 		return nil
 	})
 
 	return result, err
 }
 
-// CreateGallery creates gallery and return uint64 auto-incremental key
-func (d *Database) CreateGallery(title string) (uint64, error) {
+// CreateGallery creates gallery and return uint64 auto-incremental key.
+// owner is recorded as the creator and granted RoleOwner on the gallery.
+func (d *Database) CreateGallery(title, owner string) (uint64, error) {
 	var id uint64
 
 	err := d.db.Update(func(tx *bolt.Tx) error {
@@ -124,39 +171,131 @@ func (d *Database) CreateGallery(title string) (uint64, error) {
 			return err
 		}
 
-		return bkt.Put(titleKey, []byte(title))
+		grants, err := bkt.CreateBucket(grantsBucket)
+		if err != nil {
+			return err
+		}
+
+		if owner != "" {
+			err = putGrant(grants, Grant{UserId: owner, Role: RoleOwner})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = bkt.Put(ownerKey, []byte(owner))
+		if err != nil {
+			return err
+		}
+
+		err = bkt.Put(titleKey, []byte(title))
+		if err != nil {
+			return err
+		}
+
+		return indexDocument(tx, documentRef(id, 0, 0), title)
 	})
 
+	d.publish(err, Event{Entity: EntityGallery, Action: ActionCreate, GalleryId: id})
 	return id, err
 }
 
 // DeleteGallery deletes gallery
 func (d *Database) DeleteGallery(id uint64) error {
-	return d.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(galleryBucket).DeleteBucket(itob(id))
-	})
-}
-
-func (d *Database) SetGalleryTitle(id uint64, title string) error {
-	return d.db.Update(func(tx *bolt.Tx) error {
-		g := tx.Bucket(galleryBucket)
-		b := g.Bucket(itob(id))
-		if b == nil {
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(id))
+		if g == nil {
 			return ErrGalleryNotFound
 		}
 
-		return b.Put(titleKey, []byte(title))
+		albums := g.Bucket(albumsBucket)
+		err := albums.ForEach(func(k, v []byte) error {
+			if v != nil {
+				return nil
+			}
+			return releaseAlbumBlobs(albums.Bucket(k))
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(galleryBucket).DeleteBucket(itob(id))
+		if err != nil {
+			return err
+		}
+
+		err = unindexHashes(tx, itob(id))
+		if err != nil {
+			return err
+		}
+
+		return unindexDocuments(tx, itob(id))
 	})
+
+	d.publish(err, Event{Entity: EntityGallery, Action: ActionDelete, GalleryId: id})
+	return err
 }
 
+func (d *Database) SetGalleryTitle(id uint64, title string) error {
+	_, err := d.UpdateGallery(id, GalleryPatch{Title: &title}, nil)
+	return err
+}
+
+// Album is metadata of album.
+// Smart albums have no stored images, and list images matching Query instead.
+// Cover is first image of album unless set explicitly.
 type Album struct {
-	Id    uint64 `json:"id"`
-	Title string `json:"title"`
-	Cover uint64 `json:"cover"`
+	Id         uint64            `json:"id"`
+	Title      string            `json:"title"`
+	Cover      uint64            `json:"cover"`
+	Owner      string            `json:"owner"`
+	Smart      bool              `json:"smart,omitempty"`
+	Query      *SmartQuery       `json:"query,omitempty"`
+	Visibility Visibility        `json:"visibility,omitempty"`
+	Order      int               `json:"order,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
+// readAlbum reads metadata from album bucket
+func readAlbum(id uint64, a *bolt.Bucket) (Album, error) {
+	result := Album{
+		Id:         id,
+		Title:      string(a.Get(titleKey)),
+		Owner:      string(a.Get(ownerKey)),
+		Visibility: readVisibility(a),
+		Order:      readOrder(a),
+		Metadata:   readMetadata(a),
+	}
+
+	images := a.Bucket(imagesBucket)
+	if c := a.Get(coverKey); c != nil && images.Bucket(c) != nil {
+		result.Cover = btoi(c)
+	} else if k, _ := images.Cursor().First(); k != nil {
+		result.Cover = btoi(k)
+	}
+
+	q, err := readSmartQuery(a)
+	if err != nil {
+		return result, err
+	}
+	if q != nil {
+		result.Smart = true
+		result.Query = q
+	}
+
+	return result, nil
+}
+
+// GetAlbums returns every album of gallery, including unlisted ones
 func (d *Database) GetAlbums(galleryId uint64) ([]Album, error) {
+	result, _, err := d.GetAlbumsPage(galleryId, Page{Unlisted: true})
+	return result, err
+}
+
+// GetAlbumsPage returns albums in page, and cursor of next page
+func (d *Database) GetAlbumsPage(galleryId uint64, page Page) ([]Album, Cursor, error) {
 	result := make([]Album, 0)
+	var next Cursor
 
 	err := d.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(galleryBucket)
@@ -165,25 +304,21 @@ func (d *Database) GetAlbums(galleryId uint64) ([]Album, error) {
 			return ErrGalleryNotFound
 		}
 		b = b.Bucket(albumsBucket)
-		c := b.Cursor()
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			title := string(b.Bucket(k).Get(titleKey))
-
-			var cover uint64 = 0
-			key, _ := b.Bucket(k).Bucket(imagesBucket).Cursor().First()
-			if key != nil {
-				cover = btoi(key)
+		var keys [][]byte
+		keys, next = page.keys(b, func(a *bolt.Bucket) bool {
+			return listed(a, page)
+		})
+		for _, k := range keys {
+			a, err := readAlbum(btoi(k), b.Bucket(k))
+			if err != nil {
+				return err
 			}
-			result = append(result, Album{
-				Id:    btoi(k),
-				Title: title,
-				Cover: cover,
-			})
+			result = append(result, a)
 		}
 		return nil
 	})
 
-	return result, err
+	return result, next, err
 }
 
 func (d *Database) GetAlbum(galleryId, albumId uint64) (Album, error) {
@@ -199,20 +334,20 @@ func (d *Database) GetAlbum(galleryId, albumId uint64) (Album, error) {
 		if b == nil {
 			return ErrAlbumNotFound
 		}
-		result.Id = albumId
-		result.Title = string(b.Get(titleKey))
-		var cover uint64 = 0
-		if k, _ := b.Bucket(imagesBucket).Cursor().First(); k != nil {
-			cover = btoi(k)
-		}
-		result.Cover = cover
-		return nil
+		var err error
+		result, err = readAlbum(albumId, b)
+		return err
 	})
 
 	return result, err
 }
 
-func (d *Database) CreateAlbum(galleryId uint64, title string) (uint64, error) {
+func (d *Database) CreateAlbum(galleryId uint64, title, owner string) (uint64, error) {
+	return d.createAlbum(galleryId, title, owner, nil)
+}
+
+// createAlbum creates album, or smart album if query is not nil
+func (d *Database) createAlbum(galleryId uint64, title, owner string, query *SmartQuery) (uint64, error) {
 	var albumId uint64
 
 	err := d.db.Update(func(tx *bolt.Tx) error {
@@ -236,98 +371,287 @@ func (d *Database) CreateAlbum(galleryId uint64, title string) (uint64, error) {
 			return err
 		}
 
-		return a.Put(titleKey, []byte(title))
+		err = a.Put(ownerKey, []byte(owner))
+		if err != nil {
+			return err
+		}
+
+		if query != nil {
+			err = putSmartQuery(a, *query)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = a.Put(titleKey, []byte(title))
+		if err != nil {
+			return err
+		}
+
+		err = a.Put(timestampKey, itob(uint64(time.Now().UnixNano())))
+		if err != nil {
+			return err
+		}
+
+		return indexDocument(tx, documentRef(galleryId, albumId, 0), title)
 	})
 
+	d.publish(err, Event{Entity: EntityAlbum, Action: ActionCreate, GalleryId: galleryId, AlbumId: albumId})
 	return albumId, err
 }
 
 func (d *Database) SetAlbumTitle(galleryId, albumId uint64, title string) error {
-	return d.db.Update(func(tx *bolt.Tx) error {
-		g := tx.Bucket(galleryBucket)
-		b := g.Bucket(itob(galleryId))
-		if b == nil {
-			return ErrGalleryNotFound
-		}
-		b = b.Bucket(albumsBucket)
-		b = b.Bucket(itob(albumId))
-		if b == nil {
-			return ErrAlbumNotFound
-		}
-		return b.Put(titleKey, []byte(title))
-	})
+	_, err := d.UpdateAlbum(galleryId, albumId, AlbumPatch{Title: &title}, nil)
+	return err
 }
 
 func (d *Database) DeleteAlbum(galleryId, albumId uint64) error {
-	return d.db.Update(func(tx *bolt.Tx) error {
+	err := d.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(galleryBucket)
 		b = b.Bucket(itob(galleryId))
 		if b == nil {
 			return ErrGalleryNotFound
 		}
-		b = b.Bucket(albumsBucket)
-		return b.DeleteBucket(itob(albumId))
+		a := b.Bucket(albumsBucket).Bucket(itob(albumId))
+		if a == nil {
+			return ErrAlbumNotFound
+		}
+
+		imgs := a.Bucket(imagesBucket)
+		err := imgs.ForEach(func(k, v []byte) error {
+			return untagImage(b, albumId, btoi(k), imgs.Bucket(k))
+		})
+		if err != nil {
+			return err
+		}
+
+		err = releaseAlbumBlobs(a)
+		if err != nil {
+			return err
+		}
+
+		err = b.Bucket(albumsBucket).DeleteBucket(itob(albumId))
+		if err != nil {
+			return err
+		}
+
+		err = unindexHashes(tx, documentRef(galleryId, albumId, 0)[:16])
+		if err != nil {
+			return err
+		}
+
+		return unindexDocuments(tx, documentRef(galleryId, albumId, 0)[:16])
 	})
+
+	d.publish(err, Event{Entity: EntityAlbum, Action: ActionDelete, GalleryId: galleryId, AlbumId: albumId})
+	return err
 }
 
+// Image is metadata of image.
+// GalleryId and AlbumId are set when image is listed outside of its album,
+// such as tag lookups and smart albums.
 type Image struct {
-	Id          uint64 `json:"id"`
-	Description string `json:"description"`
+	Id          uint64            `json:"id"`
+	GalleryId   uint64            `json:"galleryId,omitempty"`
+	AlbumId     uint64            `json:"albumId,omitempty"`
+	Description string            `json:"description"`
+	Owner       string            `json:"owner"`
+	Tags        []string          `json:"tags,omitempty"`
+	CapturedAt  *time.Time        `json:"capturedAt,omitempty"`
+	Camera      string            `json:"camera,omitempty"`
+	Order       int               `json:"order,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	// Tiled is set when deep zoom tile pyramid of image is available
+	Tiled bool `json:"tiled,omitempty"`
+	// Status is ImagePending or ImageFailed until image is processed
+	Status string `json:"status,omitempty"`
+	// Width and Height are pixel size of uploaded image, as edited
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// Files are stored files of image and its renditions
+	Files *Files `json:"files,omitempty"`
+	// BlurHash, LQIP and Color are shown while image loads.
+	// LQIP is data URI of tiny JPEG, and Color is dominant color in #rrggbb.
+	BlurHash string `json:"blurHash,omitempty"`
+	LQIP     string `json:"lqip,omitempty"`
+	Color    string `json:"color,omitempty"`
+	// Edits are applied to original to render image
+	Edits []Edit `json:"edits,omitempty"`
+}
+
+// readImage reads metadata from image bucket
+// readImage reads image bucket i of gallery bucket g
+func readImage(id uint64, g, i *bolt.Bucket) Image {
+	result := Image{
+		Id:          id,
+		Description: string(i.Get(descriptionKey)),
+		Owner:       string(i.Get(ownerKey)),
+		Tags:        readTags(i),
+		Camera:      string(i.Get(cameraKey)),
+		Order:       readOrder(i),
+		Metadata:    readMetadata(i),
+		Tiled:       imageData(i).Get(deepZoomKey) != nil,
+		Status:      string(i.Get(statusKey)),
+	}
+	if c := i.Get(capturedKey); c != nil {
+		t := time.Unix(0, int64(btoi(c))).UTC()
+		result.CapturedAt = &t
+	}
+	if p, ok := readPlaceholder(imageData(i)); ok {
+		result.Width, result.Height = p.Width, p.Height
+		result.BlurHash, result.LQIP, result.Color = p.BlurHash, p.LQIP, p.Color
+	}
+	if edits, err := readEdits(imageData(i)); err == nil && len(edits) > 0 {
+		result.Edits = edits
+	}
+	if f, ok := readFiles(imageData(i)); ok {
+		result.Files = &f
+		if f.Original != nil && (result.Edits == nil || f.Display == nil) {
+			result.Width, result.Height = f.Original.Width, f.Original.Height
+		} else if f.Display != nil {
+			result.Width, result.Height = f.Display.Width, f.Display.Height
+		}
+		f.Display, f.Thumbnail = nil, nil
+		if served, err := servedData(g, i); err == nil {
+			if s, ok := readFiles(served); ok {
+				f.Display, f.Thumbnail = s.Display, s.Thumbnail
+			}
+		}
+	}
+	return result
 }
 
 func (d *Database) GetImages(galleryId, albumId uint64) ([]Image, error) {
+	result, _, err := d.GetImagesPage(galleryId, albumId, Page{})
+	return result, err
+}
+
+// GetImagesPage returns images in page, and cursor of next page.
+// Images of smart album are ordered by capture time, and paged by offset.
+// Images of unlisted albums are matched by smart album only if page includes unlisted albums.
+func (d *Database) GetImagesPage(galleryId, albumId uint64, page Page) ([]Image, Cursor, error) {
 	result := make([]Image, 0)
+	var next Cursor
 
 	err := d.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(galleryBucket)
-		b = b.Bucket(itob(galleryId))
-		if b == nil {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
 			return ErrGalleryNotFound
 		}
-		b = b.Bucket(albumsBucket)
+		b := g.Bucket(albumsBucket)
 		b = b.Bucket(itob(albumId))
 		if b == nil {
 			return ErrAlbumNotFound
 		}
-		b = b.Bucket(imagesBucket)
 
-		c := b.Cursor()
-		for k, _ := c.First(); k != nil; k, _ = c.Next() {
-			id := btoi(k)
-			description := string(b.Bucket(k).Get(descriptionKey))
+		q, err := readSmartQuery(b)
+		if err != nil {
+			return err
+		}
+		if q != nil {
+			i, err := resolveSmartQuery(tx, galleryId, *q, page.Unlisted)
+			if err != nil {
+				return err
+			}
+			result, next = page.slice(i)
+			return nil
+		}
+
+		b = b.Bucket(imagesBucket)
 
-			result = append(result, Image{
-				Id:          id,
-				Description: description,
-			})
+		var keys [][]byte
+		keys, next = page.keys(b, nil)
+		for _, k := range keys {
+			result = append(result, readImage(btoi(k), g, b.Bucket(k)))
 		}
 
 		return nil
 	})
+	return result, next, err
+}
+
+// GetImageInfo returns metadata of single image
+func (d *Database) GetImageInfo(galleryId, albumId, imageId uint64) (Image, error) {
+	var result Image
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+		result = readImage(imageId, g, i)
+		return nil
+	})
+
 	return result, err
 }
 
-func (d *Database) AddImage(galleryId, albumId uint64, imageReader io.Reader) (uint64, error) {
+// AddImage stores uploaded image and returns its id.
+// With processing workers configured, image is rendered by queued job and stays ImagePending until then.
+// Otherwise it is rendered before it is stored.
+// Identical uploads share original and renditions, and are rendered once.
+func (d *Database) AddImage(galleryId, albumId uint64, owner string, imageReader io.Reader) (uint64, error) {
 	var imgId uint64
 
-	img, _, err := image.Decode(imageReader)
+	data, err := ioutil.ReadAll(imageReader)
 	if err != nil {
 		return 0, err
 	}
 
-	thumb := resize.Thumbnail(640, 360, img, d.cfg.Interpolation)
-
-	var tBuff, iBuff bytes.Buffer
-	err = jpeg.Encode(&tBuff, thumb, &jpeg.Options{Quality: d.cfg.Quality})
+	err = checkImage(data)
 	if err != nil {
 		return 0, err
 	}
 
-	err = jpeg.Encode(&iBuff, img, &jpeg.Options{Quality: d.cfg.Quality})
+	sum := blobSum(data)
+	rendered, tiled := false, false
+	var display []byte
+	var s watermarkState
+	err = d.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(blobsBucket).Bucket(sum)
+		rendered = b != nil && b.Get(imageKey) != nil
+		if rendered {
+			display = append([]byte{}, b.Get(imageKey)...)
+			tiled = b.Get(deepZoomKey) != nil
+		}
+
+		if g := tx.Bucket(galleryBucket).Bucket(itob(galleryId)); g != nil {
+			var err error
+			s, err = readWatermarkState(g)
+			return err
+		}
+		return nil
+	})
 	if err != nil {
 		return 0, err
 	}
 
+	async := d.cfg.ProcessingWorkers > 0
+
+	var r rendition
+	analyzed, queued := false, false
+	switch {
+	case rendered:
+		r.watermarked, err = d.watermarkShared(display, tiled, s)
+		if err != nil {
+			return 0, err
+		}
+	case async:
+		// near-duplicates and placeholder are available before image is processed.
+		// Image failing to decode is marked failed by its job.
+		if img, err := decodeImage(data); err == nil {
+			r.hash = perceptualHash(img)
+			r.placeholder, err = newPlaceholder(img, d.cfg.Interpolation)
+			analyzed = err == nil
+		}
+	default:
+		r, err = d.render(data, nil, false, s)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	captured, camera := readExif(data)
+
 	err = d.db.Update(func(tx *bolt.Tx) error {
 		g := tx.Bucket(galleryBucket)
 		b := g.Bucket(itob(galleryId))
@@ -340,6 +664,9 @@ func (d *Database) AddImage(galleryId, albumId uint64, imageReader io.Reader) (u
 		if b == nil {
 			return ErrAlbumNotFound
 		}
+		if b.Get(queryKey) != nil {
+			return ErrSmartAlbum
+		}
 
 		imgs := b.Bucket(imagesBucket)
 
@@ -353,17 +680,58 @@ func (d *Database) AddImage(galleryId, albumId uint64, imageReader io.Reader) (u
 			return err
 		}
 
-		err = imgBucket.Put(thumbnailKey, tBuff.Bytes())
+		blob, err := putBlob(tx, imgBucket, sum, data)
 		if err != nil {
 			return err
 		}
 
-		err = imgBucket.Put(imageKey, iBuff.Bytes())
+		err = imgBucket.Put(descriptionKey, []byte(""))
 		if err != nil {
 			return err
 		}
 
-		err = imgBucket.Put(descriptionKey, []byte(""))
+		err = imgBucket.Put(ownerKey, []byte(owner))
+		if err != nil {
+			return err
+		}
+
+		if !captured.IsZero() {
+			err = imgBucket.Put(capturedKey, itob(uint64(captured.UnixNano())))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = imgBucket.Put(cameraKey, []byte(camera))
+		if err != nil {
+			return err
+		}
+
+		ref := documentRef(galleryId, albumId, imgId)
+		switch {
+		case blob.Get(imageKey) != nil:
+			err = putShared(tx, ref, imgBucket, r.watermarked)
+		case async || rendered:
+			// blob found rendered may be deleted meanwhile, and is rendered by job again
+			queued = true
+			err = imgBucket.Put(statusKey, []byte(ImagePending))
+			if err != nil {
+				return err
+			}
+			if analyzed {
+				err = putHash(tx, imgBucket, ref, r.hash)
+				if err != nil {
+					return err
+				}
+				err = putPlaceholder(blob, r.placeholder)
+				if err != nil {
+					return err
+				}
+			}
+			err = enqueueJob(tx, JobProcessImage, galleryId, albumId, imgId)
+		default:
+			err = r.put(tx, ref, imgBucket)
+		}
 		if err != nil {
 			return err
 		}
@@ -371,111 +739,104 @@ func (d *Database) AddImage(galleryId, albumId uint64, imageReader io.Reader) (u
 		return imgBucket.Put(timestampKey, itob(uint64(time.Now().UnixNano())))
 	})
 
+	if err == nil && queued {
+		d.notifyJobs()
+	}
+	d.publish(err, Event{Entity: EntityImage, Action: ActionCreate, GalleryId: galleryId, AlbumId: albumId, ImageId: imgId})
 	return imgId, err
 }
 
 func (d *Database) SetImageDescription(galleryId, albumId, imageId uint64, description string) error {
-	return d.db.Update(func(tx *bolt.Tx) error {
-		g := tx.Bucket(galleryBucket)
-		b := g.Bucket(itob(galleryId))
-		if b == nil {
-			return ErrGalleryNotFound
-		}
-		b = b.Bucket(albumsBucket)
-		b = b.Bucket(itob(albumId))
-		if b == nil {
-			return ErrAlbumNotFound
-		}
-		b = b.Bucket(imagesBucket)
-		i := b.Bucket(itob(imageId))
-		if i == nil {
-			return ErrImageNotFound
-		}
-		return i.Put(descriptionKey, []byte(description))
-	})
+	_, err := d.UpdateImage(galleryId, albumId, imageId, ImagePatch{Description: &description}, nil)
+	return err
 }
 
 func (d *Database) DeleteImage(galleryId, albumId, imageId uint64) error {
-	return d.db.Update(func(tx *bolt.Tx) error {
-		g := tx.Bucket(galleryBucket)
-		b := g.Bucket(itob(galleryId))
-		if b == nil {
-			return ErrGalleryNotFound
-		}
-		b = b.Bucket(albumsBucket)
-		b = b.Bucket(itob(albumId))
-		if b == nil {
-			return ErrAlbumNotFound
-		}
-		b = b.Bucket(imagesBucket)
-		return b.DeleteBucket(itob(imageId))
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		return deleteImage(tx, galleryId, albumId, imageId)
 	})
+
+	d.publish(err, Event{Entity: EntityImage, Action: ActionDelete, GalleryId: galleryId, AlbumId: albumId, ImageId: imageId})
+	return err
 }
 
-func (d *Database) GetImage(galleryId, albumId, imageId uint64) ([]byte, time.Time, error) {
-	var img []byte = nil
-	timestamp := time.Unix(1, 0)
+func deleteImage(tx *bolt.Tx, galleryId, albumId, imageId uint64) error {
+	g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+	if err != nil {
+		return err
+	}
 
-	err := d.db.View(func(tx *bolt.Tx) error {
-		g := tx.Bucket(galleryBucket)
-		b := g.Bucket(itob(galleryId))
-		if b == nil {
-			return ErrGalleryNotFound
-		}
-		b = b.Bucket(albumsBucket)
-		b = b.Bucket(itob(albumId))
-		if b == nil {
-			return ErrAlbumNotFound
-		}
-		b = b.Bucket(imagesBucket)
-		i := b.Bucket(itob(imageId))
-		if i == nil {
-			return ErrImageNotFound
-		}
+	err = untagImage(g, albumId, imageId, i)
+	if err != nil {
+		return err
+	}
 
-		ib := i.Get(imageKey)
-		img = make([]byte, len(ib))
-		copy(img, ib)
+	err = releaseBlob(i)
+	if err != nil {
+		return err
+	}
 
-		if mt := i.Get(timestampKey); mt != nil {
-			timestamp = time.Unix(0, int64(btoi(mt)))
-		}
+	err = g.Bucket(albumsBucket).Bucket(itob(albumId)).Bucket(imagesBucket).DeleteBucket(itob(imageId))
+	if err != nil {
+		return err
+	}
 
-		return nil
-	})
+	err = unindexHashes(tx, documentRef(galleryId, albumId, imageId))
 	if err != nil {
-		return nil, timestamp, err
+		return err
 	}
-	return img, timestamp, nil
+
+	return unindexDocuments(tx, documentRef(galleryId, albumId, imageId))
 }
 
+// GetImage returns display image, watermarked if gallery has watermark
+func (d *Database) GetImage(galleryId, albumId, imageId uint64) ([]byte, time.Time, error) {
+	return d.getRendition(galleryId, albumId, imageId, imageKey, true)
+}
+
+// GetUnwatermarkedImage returns display image without watermark
+func (d *Database) GetUnwatermarkedImage(galleryId, albumId, imageId uint64) ([]byte, time.Time, error) {
+	return d.getRendition(galleryId, albumId, imageId, imageKey, false)
+}
+
+// GetThumbnail returns thumbnail, watermarked if gallery has watermark
 func (d *Database) GetThumbnail(galleryId, albumId, imageId uint64) ([]byte, time.Time, error) {
+	return d.getRendition(galleryId, albumId, imageId, thumbnailKey, true)
+}
+
+// GetUnwatermarkedThumbnail returns thumbnail without watermark
+func (d *Database) GetUnwatermarkedThumbnail(galleryId, albumId, imageId uint64) ([]byte, time.Time, error) {
+	return d.getRendition(galleryId, albumId, imageId, thumbnailKey, false)
+}
+
+// getRendition returns rendition of image stored under key, and time it last changed.
+// Watermarked rendition is returned if watermarked is set and gallery has watermark.
+func (d *Database) getRendition(galleryId, albumId, imageId uint64, key []byte, watermarked bool) ([]byte, time.Time, error) {
 	var img []byte = nil
 	timestamp := time.Unix(1, 0)
+
 	err := d.db.View(func(tx *bolt.Tx) error {
-		g := tx.Bucket(galleryBucket)
-		b := g.Bucket(itob(galleryId))
-		if b == nil {
-			return ErrGalleryNotFound
+		g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
 		}
-		b = b.Bucket(albumsBucket)
-		b = b.Bucket(itob(albumId))
-		if b == nil {
-			return ErrAlbumNotFound
+
+		data := imageData(i)
+		if watermarked {
+			data, err = servedData(g, i)
+			if err != nil {
+				return err
+			}
 		}
-		b = b.Bucket(imagesBucket)
-		i := b.Bucket(itob(imageId))
-		if i == nil {
-			return ErrImageNotFound
+
+		ib := data.Get(key)
+		if ib == nil {
+			return ErrImageNotReady
 		}
-		ib := i.Get(thumbnailKey)
 		img = make([]byte, len(ib))
 		copy(img, ib)
 
-		if mt := i.Get(timestampKey); mt != nil {
-			timestamp = time.Unix(0, int64(btoi(mt)))
-		}
-
+		timestamp = renderedTime(i)
 		return nil
 	})
 	if err != nil {
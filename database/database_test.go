@@ -48,6 +48,22 @@ func createTestImage() bytes.Buffer {
 	return b
 }
 
+// processedTestImage returns i with size, placeholder and files of image created by createTestImage, once processed
+func processedTestImage(i Image) Image {
+	b := createTestImage()
+	d := &Database{cfg: &config.Config{Interpolation: resize.Lanczos3, Quality: 80}}
+	r, err := d.render(b.Bytes(), nil, false, watermarkState{})
+	if err != nil {
+		panic(err)
+	}
+
+	p := r.placeholder
+	i.Width, i.Height = p.Width, p.Height
+	i.BlurHash, i.LQIP, i.Color = p.BlurHash, p.LQIP, p.Color
+	i.Files = &Files{Original: readFile(b.Bytes()), Display: readFile(r.image), Thumbnail: readFile(r.thumbnail)}
+	return i
+}
+
 func TestNew(t *testing.T) {
 	b := createTestBolt()
 	_, err := New(b, &config.Config{Interpolation: resize.Lanczos3, Quality: 80})
@@ -58,7 +74,7 @@ func TestNew(t *testing.T) {
 
 func TestDatabase_CreateGallery(t *testing.T) {
 	db := createTestDB()
-	_, err := db.CreateGallery("test-gallery")
+	_, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -66,7 +82,7 @@ func TestDatabase_CreateGallery(t *testing.T) {
 
 func TestDatabase_GetGalleries(t *testing.T) {
 	db := createTestDB()
-	_, err := db.CreateGallery("test-gallery")
+	_, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -74,14 +90,14 @@ func TestDatabase_GetGalleries(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if !reflect.DeepEqual(g, []Gallery{{Id: 1, Title: "test-gallery"}}) {
+	if !reflect.DeepEqual(g, []Gallery{{Id: 1, Title: "test-gallery", Owner: "test-user"}}) {
 		t.Errorf("Assertion Failed: %+v", g)
 	}
 }
 
 func TestDatabase_GetGallery(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -89,14 +105,14 @@ func TestDatabase_GetGallery(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if !reflect.DeepEqual(g, Gallery{Id: 1, Title: "test-gallery"}) {
+	if !reflect.DeepEqual(g, Gallery{Id: 1, Title: "test-gallery", Owner: "test-user"}) {
 		t.Errorf("Assertion Failed: %+v", g)
 	}
 }
 
 func TestDatabase_SetGalleryTitle(t *testing.T) {
 	db := createTestDB()
-	id, err := db.CreateGallery("test-gallery")
+	id, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -108,14 +124,14 @@ func TestDatabase_SetGalleryTitle(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if !reflect.DeepEqual(g, []Gallery{{Id: 1, Title: "test-gallery-01"}}) {
+	if !reflect.DeepEqual(g, []Gallery{{Id: 1, Title: "test-gallery-01", Owner: "test-user"}}) {
 		t.Errorf("Assertion Failed: %+v", g)
 	}
 }
 
 func TestDatabase_DeleteGallery(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -134,11 +150,11 @@ func TestDatabase_DeleteGallery(t *testing.T) {
 
 func TestDatabase_CreateAlbum(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	_, err = db.CreateAlbum(gid, "test-album")
+	_, err = db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -146,11 +162,11 @@ func TestDatabase_CreateAlbum(t *testing.T) {
 
 func TestDatabase_GetAlbums(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	_, err = db.CreateAlbum(gid, "test-album")
+	_, err = db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -158,18 +174,18 @@ func TestDatabase_GetAlbums(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if !reflect.DeepEqual(a, []Album{{Id: 1, Title: "test-album", Cover: 0}}) {
+	if !reflect.DeepEqual(a, []Album{{Id: 1, Title: "test-album", Cover: 0, Owner: "test-user"}}) {
 		t.Errorf("Assertion Failed: %+v", a)
 	}
 }
 
 func TestDatabase_GetAlbum(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -177,18 +193,18 @@ func TestDatabase_GetAlbum(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if !reflect.DeepEqual(a, Album{Id: 1, Title: "test-album", Cover: 0}) {
+	if !reflect.DeepEqual(a, Album{Id: 1, Title: "test-album", Cover: 0, Owner: "test-user"}) {
 		t.Errorf("Assertion Failed: %+v", a)
 	}
 }
 
 func TestDatabase_SetAlbumTitle(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -200,18 +216,18 @@ func TestDatabase_SetAlbumTitle(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if !reflect.DeepEqual(a, []Album{{Id: 1, Title: "test-album-1", Cover: 0}}) {
+	if !reflect.DeepEqual(a, []Album{{Id: 1, Title: "test-album-1", Cover: 0, Owner: "test-user"}}) {
 		t.Errorf("Assertion Failed: %+v", a)
 	}
 }
 
 func TestDatabase_DeleteAlbum(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
@@ -230,16 +246,16 @@ func TestDatabase_DeleteAlbum(t *testing.T) {
 
 func TestDatabase_AddImage(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
 	img := createTestImage()
-	_, err = db.AddImage(gid, aid, &img)
+	_, err = db.AddImage(gid, aid, "test-user", &img)
 	if err != nil {
 		t.Error(err)
 	}
@@ -247,16 +263,16 @@ func TestDatabase_AddImage(t *testing.T) {
 
 func TestDatabase_GetImages(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
 	img := createTestImage()
-	_, err = db.AddImage(gid, aid, &img)
+	_, err = db.AddImage(gid, aid, "test-user", &img)
 	if err != nil {
 		t.Error(err)
 	}
@@ -265,7 +281,7 @@ func TestDatabase_GetImages(t *testing.T) {
 		t.Error(err)
 	}
 
-	if !reflect.DeepEqual(i, []Image{{Id: 1, Description: ""}}) {
+	if !reflect.DeepEqual(i, []Image{processedTestImage(Image{Id: 1, Description: "", Owner: "test-user"})}) {
 		t.Errorf("Assertion Failed: %+v", i)
 	}
 }
@@ -273,16 +289,16 @@ func TestDatabase_GetImages(t *testing.T) {
 func TestDatabase_SetImageDescription(t *testing.T) {
 
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
 	img := createTestImage()
-	iid, err := db.AddImage(gid, aid, &img)
+	iid, err := db.AddImage(gid, aid, "test-user", &img)
 	if err != nil {
 		t.Error(err)
 	}
@@ -292,23 +308,23 @@ func TestDatabase_SetImageDescription(t *testing.T) {
 		t.Error(err)
 	}
 
-	if !reflect.DeepEqual(i, []Image{{Id: 1, Description: "test-image"}}) {
+	if !reflect.DeepEqual(i, []Image{processedTestImage(Image{Id: 1, Description: "test-image", Owner: "test-user"})}) {
 		t.Errorf("Assertion Failed: %+v", i)
 	}
 }
 
 func TestDatabase_GetImage(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
 	img := createTestImage()
-	iid, err := db.AddImage(gid, aid, &img)
+	iid, err := db.AddImage(gid, aid, "test-user", &img)
 	if err != nil {
 		t.Error(err)
 	}
@@ -337,17 +353,17 @@ func TestDatabase_GetImage(t *testing.T) {
 
 func TestDatabase_GetThumbnail(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
 	img := createTestImage()
 	ut := time.Now().Add(-100 * time.Millisecond)
-	iid, err := db.AddImage(gid, aid, &img)
+	iid, err := db.AddImage(gid, aid, "test-user", &img)
 	if err != nil {
 		t.Error(err)
 	}
@@ -375,16 +391,16 @@ func TestDatabase_GetThumbnail(t *testing.T) {
 
 func TestDatabase_DeleteImage(t *testing.T) {
 	db := createTestDB()
-	gid, err := db.CreateGallery("test-gallery")
+	gid, err := db.CreateGallery("test-gallery", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
-	aid, err := db.CreateAlbum(gid, "test-album")
+	aid, err := db.CreateAlbum(gid, "test-album", "test-user")
 	if err != nil {
 		t.Error(err)
 	}
 	img := createTestImage()
-	iid, err := db.AddImage(gid, aid, &img)
+	iid, err := db.AddImage(gid, aid, "test-user", &img)
 	if err != nil {
 		t.Error(err)
 	}
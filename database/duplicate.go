@@ -0,0 +1,287 @@
+package database
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/bits"
+	"sort"
+
+	"github.com/boltdb/bolt"
+	"github.com/nfnt/resize"
+)
+
+var (
+	hashKey          = []byte("phash")
+	hashesBucket     = []byte("hashes")
+	hashImagesBucket = []byte("images")
+	hashChunksBucket = []byte("chunks")
+)
+
+// DuplicateDistance is the largest number of differing bits between
+// perceptual hashes of near-duplicate images.
+// Hashes differing in less than 8 bits share at least one byte, by which hashes are indexed.
+const DuplicateDistance = 6
+
+// Duplicate is near-duplicate image
+type Duplicate struct {
+	GalleryId uint64 `json:"galleryId"`
+	AlbumId   uint64 `json:"albumId"`
+	ImageId   uint64 `json:"imageId"`
+	// Distance is number of differing bits of perceptual hashes
+	Distance int `json:"distance"`
+}
+
+// perceptualHash returns difference hash (dHash) of img.
+// Each bit is set if brightness increases between horizontally adjacent pixels of 9x8 grayscale image.
+func perceptualHash(img image.Image) uint64 {
+	small := resize.Resize(9, 8, img, resize.Bilinear)
+	b := small.Bounds()
+
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := color.GrayModel.Convert(small.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+			right := color.GrayModel.Convert(small.At(b.Min.X+x+1, b.Min.Y+y)).(color.Gray).Y
+			h <<= 1
+			if right > left {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+func hashChunkKey(pos int, chunk byte, ref []byte) []byte {
+	return append([]byte{byte(pos), chunk}, ref...)
+}
+
+// putHash stores perceptual hash of image bucket i, and indexes it by ref.
+func putHash(tx *bolt.Tx, i *bolt.Bucket, ref []byte, hash uint64) error {
+	if err := i.Put(hashKey, itob(hash)); err != nil {
+		return err
+	}
+	return indexHash(tx, ref, hash)
+}
+
+// indexHash replaces indexed hash of image ref.
+func indexHash(tx *bolt.Tx, ref []byte, hash uint64) error {
+	if err := unindexHashes(tx, ref); err != nil {
+		return err
+	}
+
+	h := tx.Bucket(hashesBucket)
+	v := itob(hash)
+	if err := h.Bucket(hashImagesBucket).Put(ref, v); err != nil {
+		return err
+	}
+
+	chunks := h.Bucket(hashChunksBucket)
+	for pos, chunk := range v {
+		if err := chunks.Put(hashChunkKey(pos, chunk, ref), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unindexHashes removes hash of every image whose ref starts with prefix.
+func unindexHashes(tx *bolt.Tx, prefix []byte) error {
+	h := tx.Bucket(hashesBucket)
+	images := h.Bucket(hashImagesBucket)
+	chunks := h.Bucket(hashChunksBucket)
+
+	var refs [][]byte
+	c := images.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		for pos, chunk := range v {
+			if err := chunks.Delete(hashChunkKey(pos, chunk, k)); err != nil {
+				return err
+			}
+		}
+		refs = append(refs, append([]byte{}, k...))
+	}
+
+	for _, r := range refs {
+		if err := images.Delete(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildHashIndex indexes stored hash of every image from scratch.
+func rebuildHashIndex(tx *bolt.Tx) error {
+	if tx.Bucket(hashesBucket) != nil {
+		if err := tx.DeleteBucket(hashesBucket); err != nil {
+			return err
+		}
+	}
+
+	h, err := tx.CreateBucket(hashesBucket)
+	if err != nil {
+		return err
+	}
+	for _, b := range [][]byte{hashImagesBucket, hashChunksBucket} {
+		if _, err := h.CreateBucket(b); err != nil {
+			return err
+		}
+	}
+
+	paths, err := scopeImages(tx, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		_, i, err := imageBuckets(tx, p[0], p[1], p[2])
+		if err != nil {
+			return err
+		}
+		if v := i.Get(hashKey); v != nil {
+			if err := indexHash(tx, documentRef(p[0], p[1], p[2]), btoi(v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findDuplicates returns near-duplicates of hash, excluding image ref, nearest first.
+// Zero galleryId finds near-duplicates in every gallery.
+func findDuplicates(tx *bolt.Tx, ref []byte, hash uint64, galleryId uint64) []Duplicate {
+	result := make([]Duplicate, 0)
+	seen := make(map[string]bool)
+
+	chunks := tx.Bucket(hashesBucket).Bucket(hashChunksBucket)
+	c := chunks.Cursor()
+	for pos, chunk := range itob(hash) {
+		prefix := hashChunkKey(pos, chunk, nil)
+		if galleryId != 0 {
+			prefix = append(prefix, itob(galleryId)...)
+		}
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			other := k[2:]
+			if bytes.Equal(other, ref) || seen[string(other)] {
+				continue
+			}
+			seen[string(other)] = true
+
+			distance := bits.OnesCount64(hash ^ btoi(v))
+			if distance > DuplicateDistance {
+				continue
+			}
+			result = append(result, Duplicate{
+				GalleryId: btoi(other[:8]),
+				AlbumId:   btoi(other[8:16]),
+				ImageId:   btoi(other[16:]),
+				Distance:  distance,
+			})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Distance != result[j].Distance {
+			return result[i].Distance < result[j].Distance
+		}
+		return bytes.Compare(documentRef(result[i].GalleryId, result[i].AlbumId, result[i].ImageId), documentRef(result[j].GalleryId, result[j].AlbumId, result[j].ImageId)) < 0
+	})
+	return result
+}
+
+// GetDuplicates returns near-duplicates of image in the same gallery, nearest first.
+// Images without perceptual hash, such as uploaded before hashes were stored, have no duplicates
+// until they are regenerated.
+func (d *Database) GetDuplicates(galleryId, albumId, imageId uint64) ([]Duplicate, error) {
+	result := make([]Duplicate, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+
+		if v := i.Get(hashKey); v != nil {
+			result = findDuplicates(tx, documentRef(galleryId, albumId, imageId), btoi(v), galleryId)
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// GetDuplicateClusters returns groups of near-duplicate images across every gallery.
+// Image is in the same cluster as its near-duplicates, so that distant images may be clustered through others.
+// Clusters are ordered by their first image, and Distance is to the first image of cluster.
+func (d *Database) GetDuplicateClusters() ([][]Duplicate, error) {
+	result := make([][]Duplicate, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		hashes := make(map[string]uint64)
+		parent := make(map[string]string)
+
+		var find func(string) string
+		find = func(ref string) string {
+			p, ok := parent[ref]
+			if !ok || p == ref {
+				return ref
+			}
+			root := find(p)
+			parent[ref] = root
+			return root
+		}
+
+		var refs []string
+		err := tx.Bucket(hashesBucket).Bucket(hashImagesBucket).ForEach(func(k, v []byte) error {
+			ref := string(k)
+			refs = append(refs, ref)
+			hashes[ref] = btoi(v)
+
+			for _, dup := range findDuplicates(tx, k, btoi(v), 0) {
+				other := string(documentRef(dup.GalleryId, dup.AlbumId, dup.ImageId))
+				a, b := find(ref), find(other)
+				if a == b {
+					continue
+				}
+				// the earliest image is root of cluster
+				if a > b {
+					a, b = b, a
+				}
+				parent[b] = a
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		members := make(map[string][]string)
+		for _, ref := range refs {
+			root := find(ref)
+			members[root] = append(members[root], ref)
+		}
+
+		// refs are sorted, and root of cluster is its earliest image
+		for _, root := range refs {
+			m := members[root]
+			if len(m) < 2 {
+				continue
+			}
+
+			cluster := make([]Duplicate, 0, len(m))
+			for _, ref := range m {
+				r := []byte(ref)
+				cluster = append(cluster, Duplicate{
+					GalleryId: btoi(r[:8]),
+					AlbumId:   btoi(r[8:16]),
+					ImageId:   btoi(r[16:]),
+					Distance:  bits.OnesCount64(hashes[root] ^ hashes[ref]),
+				})
+			}
+			result = append(result, cluster)
+		}
+		return nil
+	})
+
+	return result, err
+}
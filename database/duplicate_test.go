@@ -0,0 +1,179 @@
+package database
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+// createGradientImage returns JPEG of horizontal gradient, brightening to the right unless reversed
+func createGradientImage(w, h int, reversed bool) bytes.Buffer {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		v := uint8(x * 255 / w)
+		if reversed {
+			v = 255 - v
+		}
+		for y := 0; y < h; y++ {
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: 80}); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// createNoiseImage returns JPEG of random noise
+func createNoiseImage(w, h int) bytes.Buffer {
+	r := rand.New(rand.NewSource(1))
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for idx := range img.Pix {
+		img.Pix[idx] = uint8(r.Intn(256))
+	}
+
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: 80}); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestPerceptualHash(t *testing.T) {
+	hash := func(b bytes.Buffer) uint64 {
+		img, err := decodeImage(b.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return perceptualHash(img)
+	}
+
+	small, large := hash(createGradientImage(320, 240, false)), hash(createGradientImage(1280, 960, false))
+	if d := bits.OnesCount64(small ^ large); d > DuplicateDistance {
+		t.Errorf("resized image is not near-duplicate: distance %d", d)
+	}
+
+	reversed := hash(createGradientImage(1280, 960, true))
+	if d := bits.OnesCount64(large ^ reversed); d <= DuplicateDistance {
+		t.Errorf("different image is near-duplicate: distance %d", d)
+	}
+
+	if _, err := decodeImage([]byte("hello")); err != ErrUnsupportedFormat {
+		t.Errorf("%v != %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestDatabase_GetDuplicates(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	other, _ := db.CreateGallery("other", "test-user")
+	oaid, _ := db.CreateAlbum(other, "test-album", "test-user")
+
+	add := func(gid, aid uint64, b bytes.Buffer) uint64 {
+		iid, err := db.AddImage(gid, aid, "test-user", &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return iid
+	}
+
+	first := add(gid, aid, createGradientImage(1280, 960, false))
+	second := add(gid, aid, createGradientImage(640, 480, false))
+	_ = add(gid, aid, createGradientImage(1280, 960, true))
+	_ = add(other, oaid, createGradientImage(1280, 960, false))
+
+	dup, err := db.GetDuplicates(gid, aid, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dup) != 1 || dup[0].GalleryId != gid || dup[0].AlbumId != aid || dup[0].ImageId != first {
+		t.Errorf("Assertion Failed: %+v", dup)
+	}
+
+	if _, err := db.GetDuplicates(gid, aid, 10); err != ErrImageNotFound {
+		t.Errorf("%v != %v", err, ErrImageNotFound)
+	}
+
+	// moved image is found under new album
+	to, _ := db.CreateAlbum(gid, "to", "test-user")
+	r, err := db.Batch(gid, []BatchOperation{{Op: BatchMove, AlbumId: aid, ImageId: first, ToAlbumId: to}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup, _ = db.GetDuplicates(gid, aid, second)
+	if len(dup) != 1 || dup[0].AlbumId != to || dup[0].ImageId != r[0].Image.Id {
+		t.Errorf("Assertion Failed: %+v", dup)
+	}
+
+	_ = db.DeleteAlbum(gid, to)
+	if dup, _ = db.GetDuplicates(gid, aid, second); len(dup) != 0 {
+		t.Errorf("Assertion Failed: %+v", dup)
+	}
+}
+
+func TestDatabase_GetDuplicateClusters(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	other, _ := db.CreateGallery("other", "test-user")
+	oaid, _ := db.CreateAlbum(other, "test-album", "test-user")
+
+	for _, i := range []struct {
+		gid, aid uint64
+		b        bytes.Buffer
+	}{
+		{gid, aid, createGradientImage(1280, 960, false)},
+		{gid, aid, createGradientImage(1280, 960, true)},
+		{gid, aid, createGradientImage(320, 240, true)},
+		{other, oaid, createGradientImage(640, 480, false)},
+		{other, oaid, createNoiseImage(640, 480)},
+	} {
+		if _, err := db.AddImage(i.gid, i.aid, "test-user", &i.b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := db.GetDuplicateClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]Duplicate{
+		{{GalleryId: gid, AlbumId: aid, ImageId: 1}, {GalleryId: other, AlbumId: oaid, ImageId: 1}},
+		{{GalleryId: gid, AlbumId: aid, ImageId: 2}, {GalleryId: gid, AlbumId: aid, ImageId: 3}},
+	}
+	if len(c) != len(expected) {
+		t.Fatalf("Assertion Failed: %+v", c)
+	}
+	for idx := range expected {
+		if len(c[idx]) != len(expected[idx]) {
+			t.Fatalf("Assertion Failed: %+v", c)
+		}
+		for n, e := range expected[idx] {
+			d := c[idx][n]
+			if d.GalleryId != e.GalleryId || d.AlbumId != e.AlbumId || d.ImageId != e.ImageId || n == 0 && d.Distance != 0 {
+				t.Errorf("Assertion Failed: %+v != %+v", d, e)
+			}
+		}
+	}
+
+	// index is rebuilt from stored hashes
+	err = db.db.Update(rebuildHashIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt, _ := db.GetDuplicateClusters(); len(rebuilt) != len(c) {
+		t.Errorf("Assertion Failed: %+v", rebuilt)
+	}
+
+	_ = db.DeleteGallery(other)
+	if c, _ = db.GetDuplicateClusters(); len(c) != 1 {
+		t.Errorf("Assertion Failed: %+v", c)
+	}
+}
@@ -0,0 +1,299 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/draw"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	ErrInvalidEdit      = errors.New("invalid image edit")
+	ErrOriginalNotFound = errors.New("original of image is not stored")
+)
+
+var editsKey = []byte("edits")
+
+// maxEdits limits length of edit list of image
+const maxEdits = 16
+
+// Operation of edit
+const (
+	EditRotate = "rotate"
+	EditFlip   = "flip"
+	EditCrop   = "crop"
+)
+
+// Direction of flip
+const (
+	FlipHorizontal = "horizontal"
+	FlipVertical   = "vertical"
+)
+
+// Edit is operation on original of image. Edit list of image is applied in order,
+// and renditions are rendered from the edited original.
+// Angle of rotate is clockwise in degrees, one of 90, 180 and 270.
+// Rectangle of crop is in pixels of image edited by preceding edits.
+type Edit struct {
+	Op        string `json:"op"`
+	Angle     int    `json:"angle,omitempty"`
+	Direction string `json:"direction,omitempty"`
+	X         int    `json:"x,omitempty"`
+	Y         int    `json:"y,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+}
+
+// size returns pixel size of image of size w, h after e, or ErrInvalidEdit
+func (e Edit) size(w, h int) (int, int, error) {
+	switch e.Op {
+	case EditRotate:
+		switch e.Angle {
+		case 90, 270:
+			return h, w, nil
+		case 180:
+			return w, h, nil
+		}
+	case EditFlip:
+		if e.Direction == FlipHorizontal || e.Direction == FlipVertical {
+			return w, h, nil
+		}
+	case EditCrop:
+		if e.X >= 0 && e.Y >= 0 && e.Width > 0 && e.Height > 0 && e.X+e.Width <= w && e.Y+e.Height <= h {
+			return e.Width, e.Height, nil
+		}
+	}
+	return 0, 0, ErrInvalidEdit
+}
+
+// validateEdits returns ErrInvalidEdit unless edits apply to original of size w, h
+func validateEdits(edits []Edit, w, h int) error {
+	if len(edits) > maxEdits {
+		return ErrInvalidEdit
+	}
+	var err error
+	for _, e := range edits {
+		w, h, err = e.size(w, h)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyEdits returns img edited by edits in order. img is not modified.
+func applyEdits(img image.Image, edits []Edit) image.Image {
+	if len(edits) == 0 {
+		return img
+	}
+
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	for _, e := range edits {
+		w, h := src.Bounds().Dx(), src.Bounds().Dy()
+		switch e.Op {
+		case EditRotate:
+			dw, dh, _ := e.size(w, h)
+			dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					var dx, dy int
+					switch e.Angle {
+					case 90:
+						dx, dy = h-1-y, x
+					case 180:
+						dx, dy = w-1-x, h-1-y
+					case 270:
+						dx, dy = y, w-1-x
+					}
+					copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+				}
+			}
+			src = dst
+		case EditFlip:
+			dst := image.NewRGBA(src.Bounds())
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					dx, dy := w-1-x, y
+					if e.Direction == FlipVertical {
+						dx, dy = x, h-1-y
+					}
+					copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+				}
+			}
+			src = dst
+		case EditCrop:
+			dst := image.NewRGBA(image.Rect(0, 0, e.Width, e.Height))
+			draw.Draw(dst, dst.Bounds(), src, image.Pt(e.X, e.Y), draw.Src)
+			src = dst
+		}
+	}
+	return src
+}
+
+// readEdits reads edit list of data bucket. Unedited images have empty edit list.
+func readEdits(data *bolt.Bucket) ([]Edit, error) {
+	edits := make([]Edit, 0)
+	if v := data.Get(editsKey); v != nil {
+		if err := json.Unmarshal(v, &edits); err != nil {
+			return nil, err
+		}
+	}
+	return edits, nil
+}
+
+// editedSum returns address of blob holding original of sum edited by edits.
+// Unedited original is held by blob of sum itself, so that it is shared with identical uploads.
+func editedSum(sum []byte, edits []Edit) ([]byte, error) {
+	if len(edits) == 0 {
+		return sum, nil
+	}
+	v, err := json.Marshal(edits)
+	if err != nil {
+		return nil, err
+	}
+	return blobSum(append(append([]byte{}, sum...), v...)), nil
+}
+
+// createEditedBlob returns blob of sum, holding edits of original held by blob of source.
+// Edited blob refers and retains blob of source rather than holding copy of original.
+// Blob of source is created for images stored before blobs.
+func createEditedBlob(tx *bolt.Tx, sum, source, original []byte, edits []Edit) (*bolt.Bucket, error) {
+	blobs := tx.Bucket(blobsBucket)
+	if b := blobs.Bucket(sum); b != nil {
+		return b, nil
+	}
+
+	s, err := createBlob(tx, source, original)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Put(refsKey, itob(readRefs(s)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := blobs.CreateBucket(sum)
+	if err != nil {
+		return nil, err
+	}
+	err = b.Put(sourceKey, source)
+	if err != nil {
+		return nil, err
+	}
+	v, err := json.Marshal(edits)
+	if err != nil {
+		return nil, err
+	}
+	err = b.Put(editsKey, v)
+	if err != nil {
+		return nil, err
+	}
+	return b, putFiles(b)
+}
+
+// GetImageEdits returns edit list of image
+func (d *Database) GetImageEdits(galleryId, albumId, imageId uint64) ([]Edit, error) {
+	var result []Edit
+	err := d.db.View(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+		result, err = readEdits(imageData(i))
+		return err
+	})
+	return result, err
+}
+
+// SetImageEdits replaces edit list of image, and renders image again from its original.
+// Empty edits reverts image to its original.
+// Edited image moves to blob of its edits, shared with identical uploads edited alike.
+// Image is rendered by job if processing is asynchronous.
+func (d *Database) SetImageEdits(galleryId, albumId, imageId uint64, edits []Edit) error {
+	changed := false
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+
+		data := imageData(i)
+		original := readOriginal(data)
+		if original == nil {
+			return ErrOriginalNotFound
+		}
+		original = append([]byte{}, original...)
+
+		c, _, err := image.DecodeConfig(bytes.NewReader(original))
+		if err != nil {
+			return ErrInvalidImage
+		}
+		err = validateEdits(edits, c.Width, c.Height)
+		if err != nil {
+			return err
+		}
+
+		source := blobSum(original)
+		sum, err := editedSum(source, edits)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(i.Get(blobKey), sum) {
+			return nil
+		}
+		changed = true
+
+		var b *bolt.Bucket
+		if len(edits) == 0 {
+			b, err = createBlob(tx, source, original)
+		} else {
+			b, err = createEditedBlob(tx, sum, source, original, edits)
+		}
+		if err != nil {
+			return err
+		}
+
+		// new blob is retained before current one is released, as it may be the source of current one
+		err = b.Put(refsKey, itob(readRefs(b)+1))
+		if err != nil {
+			return err
+		}
+		err = releaseBlob(i)
+		if err != nil {
+			return err
+		}
+		err = i.Put(blobKey, sum)
+		if err != nil {
+			return err
+		}
+
+		err = i.Put(statusKey, []byte(ImagePending))
+		if err != nil {
+			return err
+		}
+		if d.cfg.ProcessingWorkers > 0 {
+			return enqueueJob(tx, JobProcessImage, galleryId, albumId, imageId)
+		}
+		return nil
+	})
+	if !changed {
+		return err
+	}
+	d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: galleryId, AlbumId: albumId, ImageId: imageId})
+	if err != nil {
+		return err
+	}
+
+	if d.cfg.ProcessingWorkers > 0 {
+		d.notifyJobs()
+		return nil
+	}
+	return d.ProcessImage(galleryId, albumId, imageId)
+}
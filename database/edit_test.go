@@ -0,0 +1,186 @@
+package database
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestValidateEdits(t *testing.T) {
+	for idx, c := range []struct {
+		edits []Edit
+		valid bool
+	}{
+		{nil, true},
+		{[]Edit{{Op: EditRotate, Angle: 90}, {Op: EditFlip, Direction: FlipVertical}}, true},
+		{[]Edit{{Op: EditCrop, X: 10, Y: 20, Width: 90, Height: 180}}, true},
+		{[]Edit{{Op: EditRotate, Angle: 90}, {Op: EditCrop, Width: 200, Height: 100}}, true},
+		{[]Edit{{Op: EditCrop, Width: 200, Height: 100}}, false},
+		{[]Edit{{Op: EditCrop, X: -1, Width: 10, Height: 10}}, false},
+		{[]Edit{{Op: EditCrop, Width: 0, Height: 10}}, false},
+		{[]Edit{{Op: EditRotate, Angle: 45}}, false},
+		{[]Edit{{Op: EditFlip, Direction: "diagonal"}}, false},
+		{[]Edit{{Op: "resize"}}, false},
+		{make([]Edit, maxEdits+1), false},
+	} {
+		if err := validateEdits(c.edits, 100, 200); (err == nil) != c.valid {
+			t.Errorf("%d: %v", idx, err)
+		}
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	// 4x2 image with red top-left pixel
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.RGBA{R: 0xff, A: 0xff})
+	red := color.RGBA{R: 0xff, A: 0xff}
+
+	for idx, c := range []struct {
+		edits  []Edit
+		size   image.Point
+		marked image.Point
+	}{
+		{nil, image.Pt(4, 2), image.Pt(0, 0)},
+		{[]Edit{{Op: EditRotate, Angle: 90}}, image.Pt(2, 4), image.Pt(1, 0)},
+		{[]Edit{{Op: EditRotate, Angle: 180}}, image.Pt(4, 2), image.Pt(3, 1)},
+		{[]Edit{{Op: EditRotate, Angle: 270}}, image.Pt(2, 4), image.Pt(0, 3)},
+		{[]Edit{{Op: EditFlip, Direction: FlipHorizontal}}, image.Pt(4, 2), image.Pt(3, 0)},
+		{[]Edit{{Op: EditFlip, Direction: FlipVertical}}, image.Pt(4, 2), image.Pt(0, 1)},
+		{[]Edit{{Op: EditRotate, Angle: 90}, {Op: EditCrop, X: 1, Y: 0, Width: 1, Height: 2}}, image.Pt(1, 2), image.Pt(0, 0)},
+	} {
+		m := applyEdits(img, c.edits)
+		if m.Bounds().Size() != c.size {
+			t.Errorf("%d: %v != %v", idx, m.Bounds().Size(), c.size)
+			continue
+		}
+		if p := color.RGBAModel.Convert(m.At(m.Bounds().Min.X+c.marked.X, m.Bounds().Min.Y+c.marked.Y)); p != red {
+			t.Errorf("%d: %v != %v", idx, p, red)
+		}
+	}
+
+	if img.At(0, 0) != red {
+		t.Error("source image is modified")
+	}
+}
+
+func TestDatabase_SetImageEdits(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+
+	img := createGradientImage(400, 200, false)
+	original := img.Bytes()
+	iid, _ := db.AddImage(gid, aid, "test-user", bytes.NewReader(original))
+	other, _ := db.AddImage(gid, aid, "test-user", bytes.NewReader(original))
+	clean, uploaded, _ := db.GetImage(gid, aid, iid)
+
+	if err := db.SetImageEdits(gid, aid, iid, []Edit{{Op: EditCrop, Width: 500, Height: 100}}); err != ErrInvalidEdit {
+		t.Errorf("%v != %v", err, ErrInvalidEdit)
+	}
+	if err := db.SetImageEdits(gid, aid, 9, nil); err != ErrImageNotFound {
+		t.Errorf("%v != %v", err, ErrImageNotFound)
+	}
+
+	edits := []Edit{{Op: EditRotate, Angle: 90}, {Op: EditCrop, X: 0, Y: 50, Width: 200, Height: 300}}
+	if err := db.SetImageEdits(gid, aid, iid, edits); err != nil {
+		t.Fatal(err)
+	}
+
+	// edited image is served with later modification time than upload
+	if _, modified, _ := db.GetImage(gid, aid, iid); !modified.After(uploaded) {
+		t.Errorf("%v is not after %v", modified, uploaded)
+	}
+
+	i, _ := db.GetImageInfo(gid, aid, iid)
+	if i.Width != 200 || i.Height != 300 || i.Status != "" || len(i.Edits) != 2 {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+	if i.Files.Original.Bytes != len(original) || i.Files.Thumbnail.Height != 300 {
+		t.Errorf("Assertion Failed: %+v %+v", i.Files.Original, i.Files.Thumbnail)
+	}
+	if e, _ := db.GetImageEdits(gid, aid, iid); len(e) != 2 || e[1] != edits[1] {
+		t.Errorf("Assertion Failed: %+v", e)
+	}
+
+	edited, _, _ := db.GetImage(gid, aid, iid)
+	if decodeSize(edited) != (Size{200, 300}) {
+		t.Errorf("%v != %v", decodeSize(edited), Size{200, 300})
+	}
+
+	// identical upload keeps its renditions
+	if o, _, _ := db.GetImage(gid, aid, other); !bytes.Equal(o, clean) {
+		t.Error("edit leaks to identical upload")
+	}
+	if e, _ := db.GetImageEdits(gid, aid, other); len(e) != 0 {
+		t.Errorf("Assertion Failed: %+v", e)
+	}
+	if refs := blobRefs(db); len(refs) != 2 {
+		t.Errorf("Assertion Failed: %v", refs)
+	}
+
+	// edited blob refers original of identical upload instead of copying it
+	_ = db.db.View(func(tx *bolt.Tx) error {
+		_, i, _ := imageBuckets(tx, gid, aid, iid)
+		data := imageData(i)
+		if data.Get(originalKey) != nil || !bytes.Equal(data.Get(sourceKey), blobSum(original)) || !bytes.Equal(readOriginal(data), original) {
+			t.Error("original of edited blob is not its source")
+		}
+		return nil
+	})
+
+	// reverting shares renditions of identical upload again
+	if err := db.SetImageEdits(gid, aid, iid, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _ := db.GetImage(gid, aid, iid); !bytes.Equal(r, clean) {
+		t.Error("image is not reverted")
+	}
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Width != 400 || i.Height != 200 || i.Edits != nil {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+	if refs := blobRefs(db); len(refs) != 1 || refs[0] != 2 {
+		t.Errorf("Assertion Failed: %v", refs)
+	}
+
+	// deleting edited image releases its source
+	_ = db.SetImageEdits(gid, aid, iid, edits)
+	_ = db.DeleteImage(gid, aid, iid)
+	if refs := blobRefs(db); len(refs) != 1 || refs[0] != 1 {
+		t.Errorf("Assertion Failed: %v", refs)
+	}
+}
+
+func TestDatabase_SetImageEditsJob(t *testing.T) {
+	db, gid, aid := createAsyncTestDB()
+
+	img := createTestImage()
+	iid, _ := db.AddImage(gid, aid, "test-user", &img)
+	runJobs(db)
+
+	if err := db.SetImageEdits(gid, aid, iid, []Edit{{Op: EditCrop, Width: 640, Height: 320}}); err != nil {
+		t.Fatal(err)
+	}
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Status != ImagePending {
+		t.Errorf("%s != %s", i.Status, ImagePending)
+	}
+	if jobs, _ := db.GetJobs(JobQueued); len(jobs) != 1 || jobs[0].Kind != JobProcessImage || jobs[0].ImageId != iid {
+		t.Errorf("Assertion Failed: %+v", jobs)
+	}
+
+	runJobs(db)
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Status != "" || i.Width != 640 || i.Height != 320 {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	// regenerated image keeps edits
+	if _, err := db.RegenerateImages(gid, 0); err != nil {
+		t.Fatal(err)
+	}
+	runJobs(db)
+	if i, _, _ := db.GetImage(gid, aid, iid); decodeSize(i) != (Size{640, 320}) {
+		t.Errorf("%v != %v", decodeSize(i), Size{640, 320})
+	}
+}
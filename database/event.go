@@ -0,0 +1,100 @@
+package database
+
+import (
+	"sync"
+	"time"
+)
+
+// Entities of event
+const (
+	EntityGallery = "gallery"
+	EntityAlbum   = "album"
+	EntityImage   = "image"
+	EntityGrant   = "grant"
+)
+
+// Actions of event
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+const subscriberBuffer = 64
+
+// Event notifies committed change of entity.
+// Ids of parent entities are set, e.g. GalleryId and AlbumId of image.
+type Event struct {
+	Id        uint64    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Entity    string    `json:"entity"`
+	Action    string    `json:"action"`
+	GalleryId uint64    `json:"galleryId"`
+	AlbumId   uint64    `json:"albumId,omitempty"`
+	ImageId   uint64    `json:"imageId,omitempty"`
+	UserId    string    `json:"userId,omitempty"`
+}
+
+type subscriber struct {
+	galleryId uint64
+	events    chan Event
+}
+
+// hub fans out events to subscribers
+type hub struct {
+	sync.Mutex
+	seq         uint64
+	subscribers map[*subscriber]struct{}
+}
+
+// Subscribe returns channel receiving events of gallery, or of every gallery if galleryId is zero.
+// Channel is closed when subscriber falls behind, or cancel is called.
+// Events are not persisted, so subscriber should reload state when channel is closed.
+func (d *Database) Subscribe(galleryId uint64) (<-chan Event, func()) {
+	s := &subscriber{galleryId: galleryId, events: make(chan Event, subscriberBuffer)}
+
+	d.hub.Lock()
+	if d.hub.subscribers == nil {
+		d.hub.subscribers = make(map[*subscriber]struct{})
+	}
+	d.hub.subscribers[s] = struct{}{}
+	d.hub.Unlock()
+
+	return s.events, func() {
+		d.hub.Lock()
+		defer d.hub.Unlock()
+		if _, ok := d.hub.subscribers[s]; ok {
+			delete(d.hub.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// publish sends events to subscribers unless err is set by failed mutation
+func (d *Database) publish(err error, events ...Event) {
+	if err != nil {
+		return
+	}
+
+	d.hub.Lock()
+	defer d.hub.Unlock()
+
+	for _, e := range events {
+		d.hub.seq++
+		e.Id = d.hub.seq
+		e.Timestamp = time.Now()
+
+		for s := range d.hub.subscribers {
+			if s.galleryId != 0 && s.galleryId != e.GalleryId {
+				continue
+			}
+			select {
+			case s.events <- e:
+			default:
+				// drops slow subscriber rather than blocking mutations
+				delete(d.hub.subscribers, s)
+				close(s.events)
+			}
+		}
+	}
+}
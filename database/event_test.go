@@ -0,0 +1,65 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestDatabase_Subscribe(t *testing.T) {
+	db := createTestDB()
+
+	all, cancelAll := db.Subscribe(0)
+	defer cancelAll()
+	second, cancelSecond := db.Subscribe(2)
+
+	gid, _ := db.CreateGallery("hello", "test-user")
+	aid, _ := db.CreateAlbum(gid, "album", "test-user")
+	if _, err := db.CreateAlbum(gid+1, "album", "test-user"); err != ErrGalleryNotFound {
+		t.Errorf("%v != %v", err, ErrGalleryNotFound)
+	}
+	img := createTestImage()
+	iid, _ := db.AddImage(gid, aid, "test-user", &img)
+	_ = db.SetImageDescription(gid, aid, iid, "hello")
+	_ = db.DeleteGallery(gid)
+
+	for idx, expected := range []Event{
+		{Entity: EntityGallery, Action: ActionCreate, GalleryId: gid},
+		{Entity: EntityAlbum, Action: ActionCreate, GalleryId: gid, AlbumId: aid},
+		{Entity: EntityImage, Action: ActionCreate, GalleryId: gid, AlbumId: aid, ImageId: iid},
+		{Entity: EntityImage, Action: ActionUpdate, GalleryId: gid, AlbumId: aid, ImageId: iid},
+		{Entity: EntityGallery, Action: ActionDelete, GalleryId: gid},
+	} {
+		e := <-all
+		if e.Id != uint64(idx+1) || e.Timestamp.IsZero() {
+			t.Errorf("%d: Assertion Failed: %+v", idx, e)
+		}
+		e.Id, e.Timestamp = 0, expected.Timestamp
+		if e != expected {
+			t.Errorf("%d: %+v != %+v", idx, e, expected)
+		}
+	}
+
+	select {
+	case e := <-second:
+		t.Errorf("event of other gallery received: %+v", e)
+	default:
+	}
+	cancelSecond()
+	if _, ok := <-second; ok {
+		t.Error("channel not closed on cancel")
+	}
+	cancelSecond()
+
+	// slow subscriber is dropped
+	slow, cancelSlow := db.Subscribe(0)
+	defer cancelSlow()
+	for i := 0; i <= subscriberBuffer; i++ {
+		_, _ = db.CreateGallery("hello", "test-user")
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("%d != %d", n, subscriberBuffer)
+	}
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+var (
+	capturedKey = []byte("captured")
+	cameraKey   = []byte("camera")
+)
+
+// readExif returns capture time and camera model recorded in image.
+// Zero values are returned for images without EXIF data.
+func readExif(data []byte) (time.Time, string) {
+	x, err := exif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return time.Time{}, ""
+	}
+
+	captured, err := x.DateTime()
+	if err != nil {
+		captured = time.Time{}
+	}
+
+	var camera []string
+	for _, f := range []exif.FieldName{exif.Make, exif.Model} {
+		t, err := x.Get(f)
+		if err != nil {
+			continue
+		}
+		if v, err := t.StringVal(); err == nil && strings.TrimSpace(v) != "" {
+			camera = append(camera, strings.TrimSpace(v))
+		}
+	}
+
+	return captured, strings.Join(camera, " ")
+}
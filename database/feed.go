@@ -0,0 +1,145 @@
+package database
+
+import (
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// FeedEntry is album or image published in feed.
+// ImageId of album entry is its cover, and zero if album is empty.
+// Images of smart album may be stored in other gallery than the feed's.
+type FeedEntry struct {
+	GalleryId     uint64
+	AlbumId       uint64
+	ImageId       uint64
+	Title         string
+	Content       string
+	Published     time.Time
+	ThumbnailSize int
+}
+
+func readTimestamp(b *bolt.Bucket) time.Time {
+	if t := b.Get(timestampKey); t != nil {
+		return time.Unix(0, int64(btoi(t))).UTC()
+	}
+	return time.Time{}
+}
+
+// albumPublished returns creation time of album.
+// Albums created before it was recorded fall back to latest upload.
+func albumPublished(a *bolt.Bucket) time.Time {
+	if t := readTimestamp(a); !t.IsZero() {
+		return t
+	}
+
+	var result time.Time
+	_ = a.Bucket(imagesBucket).ForEach(func(k, v []byte) error {
+		if t := readTimestamp(a.Bucket(imagesBucket).Bucket(k)); t.After(result) {
+			result = t
+		}
+		return nil
+	})
+	return result
+}
+
+// newest sorts entries newest first, and truncates them to limit
+func newest(entries []FeedEntry, limit int) []FeedEntry {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Published.After(entries[j].Published)
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// GetGalleryFeed returns gallery and its newest listed albums, up to limit.
+func (d *Database) GetGalleryFeed(galleryId uint64, limit int) (Gallery, []FeedEntry, error) {
+	var gallery Gallery
+	result := make([]FeedEntry, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+		gallery = readGallery(galleryId, g)
+
+		albums := g.Bucket(albumsBucket)
+		return albums.ForEach(func(k, v []byte) error {
+			a := albums.Bucket(k)
+			if !listed(a, Page{}) {
+				return nil
+			}
+
+			published := albumPublished(a)
+			if published.IsZero() {
+				return nil
+			}
+
+			album, err := readAlbum(btoi(k), a)
+			if err != nil {
+				return err
+			}
+			e := FeedEntry{GalleryId: galleryId, AlbumId: album.Id, ImageId: album.Cover, Title: album.Title, Published: published}
+			if album.Cover != 0 && album.Query == nil {
+				e.ThumbnailSize = servedSize(g, a.Bucket(imagesBucket).Bucket(itob(album.Cover)), thumbnailKey)
+			}
+			result = append(result, e)
+			return nil
+		})
+	})
+
+	return gallery, newest(result, limit), err
+}
+
+// GetAlbumFeed returns album and its newest images, up to limit.
+// Entries of smart album are images matching its query, with GalleryId and AlbumId of their own album.
+// Images of unlisted albums are left out of smart album feed.
+func (d *Database) GetAlbumFeed(galleryId, albumId uint64, limit int) (Album, []FeedEntry, error) {
+	var album Album
+	result := make([]FeedEntry, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+		a := g.Bucket(albumsBucket).Bucket(itob(albumId))
+		if a == nil {
+			return ErrAlbumNotFound
+		}
+
+		var err error
+		album, err = readAlbum(albumId, a)
+		if err != nil {
+			return err
+		}
+
+		if album.Query != nil {
+			images, err := resolveSmartQuery(tx, galleryId, *album.Query, false)
+			if err != nil {
+				return err
+			}
+			for _, img := range images {
+				ig, i, err := imageBuckets(tx, img.GalleryId, img.AlbumId, img.Id)
+				if err != nil {
+					return err
+				}
+				result = append(result, FeedEntry{GalleryId: img.GalleryId, AlbumId: img.AlbumId, ImageId: img.Id, Content: img.Description, Published: readTimestamp(i), ThumbnailSize: servedSize(ig, i, thumbnailKey)})
+			}
+			return nil
+		}
+
+		images := a.Bucket(imagesBucket)
+		return images.ForEach(func(k, v []byte) error {
+			i := images.Bucket(k)
+			result = append(result, FeedEntry{GalleryId: galleryId, AlbumId: albumId, ImageId: btoi(k), Content: string(i.Get(descriptionKey)), Published: readTimestamp(i), ThumbnailSize: servedSize(g, i, thumbnailKey)})
+			return nil
+		})
+	})
+
+	return album, newest(result, limit), err
+}
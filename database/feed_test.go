@@ -0,0 +1,76 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestDatabase_GetFeed(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	img := createTestImage()
+	if _, err := db.AddImage(gid, 2, "test-user", &img); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.SetImageDescription(gid, 2, 2, "newest")
+
+	aid, _ := db.CreateAlbum(gid, "unlisted", "test-user")
+	unlisted := VisibilityUnlisted
+	if _, err := db.UpdateAlbum(gid, aid, AlbumPatch{Visibility: &unlisted}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	g, entries, err := db.GetGalleryFeed(gid, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Title != "test-gallery" || len(entries) != 2 {
+		t.Fatalf("Assertion Failed: %+v %+v", g, entries)
+	}
+	if e := entries[0]; e.AlbumId != 2 || e.Title != "album-2" || e.ImageId != 1 || e.ThumbnailSize == 0 || e.Published.Before(entries[1].Published) {
+		t.Errorf("Assertion Failed: %+v", entries)
+	}
+
+	a, entries, err := db.GetAlbumFeed(gid, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Title != "album-2" || len(entries) != 1 || entries[0].ImageId != 2 || entries[0].Content != "newest" {
+		t.Errorf("Assertion Failed: %+v %+v", a, entries)
+	}
+
+	if _, _, err := db.GetAlbumFeed(gid, 9, 0); err != ErrAlbumNotFound {
+		t.Errorf("%v != %v", err, ErrAlbumNotFound)
+	}
+	if _, _, err := db.GetGalleryFeed(gid+1, 0); err != ErrGalleryNotFound {
+		t.Errorf("%v != %v", err, ErrGalleryNotFound)
+	}
+}
+
+func TestDatabase_GetFeed_Watermark(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	if err := db.SetWatermark(gid, &Watermark{Text: "hello", Position: PositionCenter, Opacity: 1, Scale: 1}); err != nil {
+		t.Fatal(err)
+	}
+	runJobs(db)
+
+	// enclosures describe watermarked thumbnails served
+	thumb, _, err := db.GetThumbnail(gid, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, entries, err := db.GetGalleryFeed(gid, 0)
+	if err != nil || len(entries) == 0 || entries[0].ImageId != 1 || entries[0].ThumbnailSize != len(thumb) {
+		t.Errorf("Assertion Failed: %d %+v %v", len(thumb), entries, err)
+	}
+	_, entries, err = db.GetAlbumFeed(gid, 2, 0)
+	if err != nil || len(entries) != 1 || entries[0].ThumbnailSize != len(thumb) {
+		t.Errorf("Assertion Failed: %d %+v %v", len(thumb), entries, err)
+	}
+
+	// thumbnails not watermarked yet are not served
+	if err := db.SetWatermark(gid, &Watermark{Text: "other", Position: PositionCenter, Opacity: 1, Scale: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, entries, err = db.GetAlbumFeed(gid, 2, 0); err != nil || len(entries) != 1 || entries[0].ThumbnailSize != 0 {
+		t.Errorf("Assertion Failed: %+v %v", entries, err)
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+
+	"github.com/boltdb/bolt"
+)
+
+var filesKey = []byte("files")
+
+// File is stored file of uploaded image or its rendition
+type File struct {
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Bytes    int    `json:"bytes"`
+	Format   string `json:"format"`
+	MimeType string `json:"mimeType"`
+}
+
+// Files are stored files of image.
+// Original is absent for images stored before originals were kept.
+// Renditions are those served, watermarked if gallery has watermark, and are absent until image is processed or watermarked.
+type Files struct {
+	Original  *File `json:"original,omitempty"`
+	Display   *File `json:"display,omitempty"`
+	Thumbnail *File `json:"thumbnail,omitempty"`
+}
+
+// readFile reads file info from header of encoded image, or returns nil for data failing to decode
+func readFile(b []byte) *File {
+	if b == nil {
+		return nil
+	}
+	c, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil
+	}
+	return &File{Width: c.Width, Height: c.Height, Bytes: len(b), Format: format, MimeType: "image/" + format}
+}
+
+// putFiles stores info of files held by data bucket
+func putFiles(data *bolt.Bucket) error {
+	v, err := json.Marshal(Files{
+		Original:  readFile(readOriginal(data)),
+		Display:   readFile(data.Get(imageKey)),
+		Thumbnail: readFile(data.Get(thumbnailKey)),
+	})
+	if err != nil {
+		return err
+	}
+	return data.Put(filesKey, v)
+}
+
+func readFiles(data *bolt.Bucket) (Files, bool) {
+	var f Files
+	v := data.Get(filesKey)
+	if v == nil || json.Unmarshal(v, &f) != nil {
+		return f, false
+	}
+	return f, true
+}
+
+// backfillFiles stores file info of every image stored before it was kept
+func backfillFiles(tx *bolt.Tx) error {
+	paths, err := scopeImages(tx, 0, 0)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		_, i, err := imageBuckets(tx, p[0], p[1], p[2])
+		if err != nil {
+			return err
+		}
+		if data := imageData(i); data.Get(filesKey) == nil {
+			if err := putFiles(data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
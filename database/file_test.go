@@ -0,0 +1,94 @@
+package database
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/nfnt/resize"
+)
+
+func TestReadFile(t *testing.T) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, 320, 240))); err != nil {
+		t.Fatal(err)
+	}
+
+	if f := readFile(b.Bytes()); !reflect.DeepEqual(f, &File{Width: 320, Height: 240, Bytes: b.Len(), Format: "png", MimeType: "image/png"}) {
+		t.Errorf("Assertion Failed: %+v", f)
+	}
+	if f := readFile([]byte("hello")); f != nil {
+		t.Errorf("Assertion Failed: %+v", f)
+	}
+}
+
+func TestDatabase_GetImageFiles(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+
+	b := createGradientImage(1600, 1200, false)
+	size := b.Len()
+	iid, err := db.AddImage(gid, aid, "test-user", &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := db.GetImageInfo(gid, aid, iid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Width != 1600 || i.Height != 1200 || i.Files == nil {
+		t.Fatalf("Assertion Failed: %+v", i)
+	}
+	if o := i.Files.Original; o == nil || *o != (File{Width: 1600, Height: 1200, Bytes: size, Format: "jpeg", MimeType: "image/jpeg"}) {
+		t.Errorf("Assertion Failed: %+v", o)
+	}
+
+	img, _, _ := db.GetImage(gid, aid, iid)
+	thumb, _, _ := db.GetThumbnail(gid, aid, iid)
+	if !reflect.DeepEqual(i.Files.Display, readFile(img)) || !reflect.DeepEqual(i.Files.Thumbnail, readFile(thumb)) {
+		t.Errorf("Assertion Failed: %+v %+v", i.Files.Display, i.Files.Thumbnail)
+	}
+	if th := i.Files.Thumbnail; th == nil || th.Width != 480 || th.Height != 360 {
+		t.Errorf("Assertion Failed: %+v", th)
+	}
+}
+
+func TestMigrate_BackfillFiles(t *testing.T) {
+	b := createTestBolt()
+	db, _ := New(b, &config.Config{Interpolation: resize.Lanczos3, Quality: 80})
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	img := createTestImage()
+	iid, _ := db.AddImage(gid, aid, "test-user", &img)
+
+	expected, _ := db.GetImageInfo(gid, aid, iid)
+
+	// simulate record stored before files were kept
+	_ = b.Update(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, gid, aid, iid)
+		if err != nil {
+			return err
+		}
+		if err := imageData(i).Delete(filesKey); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(migrationsBucket)
+	})
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Files != nil {
+		t.Fatalf("Assertion Failed: %+v", i.Files)
+	}
+
+	db, err := New(b, &config.Config{Interpolation: resize.Lanczos3, Quality: 80})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i, _ := db.GetImageInfo(gid, aid, iid); !reflect.DeepEqual(i, expected) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+}
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	ErrGrantNotFound = errors.New("grant not found")
+	ErrInvalidRole   = errors.New("invalid role")
+	ErrLastOwner     = errors.New("gallery must have at least one owner")
+)
+
+var grantsBucket = []byte("grants")
+
+// Role is permission level of user on a gallery.
+type Role string
+
+const (
+	RoleNone        Role = ""
+	RoleViewer      Role = "viewer"
+	RoleContributor Role = "contributor"
+	RoleEditor      Role = "editor"
+	RoleOwner       Role = "owner"
+)
+
+var roleLevels = map[Role]int{
+	RoleNone:        0,
+	RoleViewer:      1,
+	RoleContributor: 2,
+	RoleEditor:      3,
+	RoleOwner:       4,
+}
+
+// Valid reports whether r is one of assignable roles.
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok && r != RoleNone
+}
+
+// Includes reports whether r has every permission of o.
+func (r Role) Includes(o Role) bool {
+	return roleLevels[r] >= roleLevels[o]
+}
+
+// Grant assigns role to user on a gallery.
+// Albums restricts contributors to listed albums.
+type Grant struct {
+	UserId string   `json:"userId"`
+	Role   Role     `json:"role"`
+	Albums []uint64 `json:"albums,omitempty"`
+}
+
+// CanContributeTo reports whether grant allows uploading images to album.
+func (g Grant) CanContributeTo(albumId uint64) bool {
+	if g.Role.Includes(RoleEditor) {
+		return true
+	}
+	if !g.Role.Includes(RoleContributor) {
+		return false
+	}
+	for _, a := range g.Albums {
+		if a == albumId {
+			return true
+		}
+	}
+	return false
+}
+
+// granted reports whether user has any role on gallery bucket g
+func granted(g *bolt.Bucket, userId string) bool {
+	if userId == "" {
+		return false
+	}
+	grants := g.Bucket(grantsBucket)
+	return grants != nil && grants.Get([]byte(userId)) != nil
+}
+
+func putGrant(b *bolt.Bucket, grant Grant) error {
+	v, err := json.Marshal(grant)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(grant.UserId), v)
+}
+
+func countOwners(b *bolt.Bucket) (int, error) {
+	count := 0
+	err := b.ForEach(func(k, v []byte) error {
+		var g Grant
+		if err := json.Unmarshal(v, &g); err != nil {
+			return err
+		}
+		if g.Role == RoleOwner {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// keepOwner applies change to grants bucket b, unless it removes the last owner of gallery.
+// Galleries without owner accept any change, so admins can grant roles on them.
+func keepOwner(b *bolt.Bucket, change func() error) error {
+	before, err := countOwners(b)
+	if err != nil {
+		return err
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	after, err := countOwners(b)
+	if err != nil {
+		return err
+	}
+	if before > 0 && after == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// grantOwners grants owner role to owner of every gallery created before permissions were introduced.
+// Galleries without owner, as every gallery created before owners were recorded, are left without grants.
+// Those are managed by admins only, until an admin grants roles on them, typically owner role to one user.
+func grantOwners(tx *bolt.Tx) error {
+	galleries := tx.Bucket(galleryBucket)
+
+	var ids [][]byte
+	err := galleries.ForEach(func(k, v []byte) error {
+		if v == nil {
+			ids = append(ids, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		g := galleries.Bucket(id)
+		b, err := g.CreateBucketIfNotExists(grantsBucket)
+		if err != nil {
+			return err
+		}
+		if k, _ := b.Cursor().First(); k != nil {
+			continue
+		}
+		if owner := g.Get(ownerKey); len(owner) != 0 {
+			if err := putGrant(b, Grant{UserId: string(owner), Role: RoleOwner}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// grants returns grants bucket of gallery, creating it for galleries
+// created before permissions were introduced.
+func grants(tx *bolt.Tx, galleryId uint64) (*bolt.Bucket, error) {
+	b := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+	if b == nil {
+		return nil, ErrGalleryNotFound
+	}
+	if g := b.Bucket(grantsBucket); g != nil || !tx.Writable() {
+		return g, nil
+	}
+	return b.CreateBucket(grantsBucket)
+}
+
+// GetGrants returns every grant of gallery.
+// Empty result means gallery has no owner, and is managed by admins only.
+func (d *Database) GetGrants(galleryId uint64) ([]Grant, error) {
+	result := make([]Grant, 0)
+	err := d.db.View(func(tx *bolt.Tx) error {
+		b, err := grants(tx, galleryId)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var g Grant
+			if err := json.Unmarshal(v, &g); err != nil {
+				return err
+			}
+			result = append(result, g)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (d *Database) GetGrant(galleryId uint64, userId string) (Grant, error) {
+	var result Grant
+	err := d.db.View(func(tx *bolt.Tx) error {
+		b, err := grants(tx, galleryId)
+		if err != nil {
+			return err
+		}
+		if b == nil {
+			return ErrGrantNotFound
+		}
+		v := b.Get([]byte(userId))
+		if v == nil {
+			return ErrGrantNotFound
+		}
+		return json.Unmarshal(v, &result)
+	})
+	return result, err
+}
+
+// SetGrant creates or replaces grant of grant.UserId
+func (d *Database) SetGrant(galleryId uint64, grant Grant) error {
+	if !grant.Role.Valid() || grant.UserId == "" {
+		return ErrInvalidRole
+	}
+	if grant.Role != RoleContributor {
+		grant.Albums = nil
+	}
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b, err := grants(tx, galleryId)
+		if err != nil {
+			return err
+		}
+
+		return keepOwner(b, func() error {
+			return putGrant(b, grant)
+		})
+	})
+
+	d.publish(err, Event{Entity: EntityGrant, Action: ActionUpdate, GalleryId: galleryId, UserId: grant.UserId})
+	return err
+}
+
+func (d *Database) DeleteGrant(galleryId uint64, userId string) error {
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b, err := grants(tx, galleryId)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(userId)) == nil {
+			return ErrGrantNotFound
+		}
+
+		return keepOwner(b, func() error {
+			return b.Delete([]byte(userId))
+		})
+	})
+
+	d.publish(err, Event{Entity: EntityGrant, Action: ActionDelete, GalleryId: galleryId, UserId: userId})
+	return err
+}
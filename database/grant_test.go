@@ -0,0 +1,136 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/dfkdream/gallery-plugin/config"
+)
+
+func TestDatabase_GetGrants(t *testing.T) {
+	db := createTestDB()
+	gid, err := db.CreateGallery("test-gallery", "test-user")
+	if err != nil {
+		t.Error(err)
+	}
+	g, err := db.GetGrants(gid)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(g, []Grant{{UserId: "test-user", Role: RoleOwner}}) {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+}
+
+func TestDatabase_SetGrant(t *testing.T) {
+	db := createTestDB()
+	gid, err := db.CreateGallery("test-gallery", "test-user")
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.SetGrant(gid, Grant{UserId: "contributor", Role: RoleContributor, Albums: []uint64{1}})
+	if err != nil {
+		t.Error(err)
+	}
+	g, err := db.GetGrant(gid, "contributor")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(g, Grant{UserId: "contributor", Role: RoleContributor, Albums: []uint64{1}}) {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+	if !g.CanContributeTo(1) || g.CanContributeTo(2) {
+		t.Errorf("Album restriction failed: %+v", g)
+	}
+
+	if err := db.SetGrant(gid, Grant{UserId: "test-user", Role: RoleEditor}); err != ErrLastOwner {
+		t.Errorf("%v != %v", err, ErrLastOwner)
+	}
+
+	if err := db.SetGrant(gid, Grant{UserId: "test-user", Role: "admin"}); err != ErrInvalidRole {
+		t.Errorf("%v != %v", err, ErrInvalidRole)
+	}
+}
+
+func TestDatabase_DeleteGrant(t *testing.T) {
+	db := createTestDB()
+	gid, err := db.CreateGallery("test-gallery", "test-user")
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.SetGrant(gid, Grant{UserId: "editor", Role: RoleEditor})
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.DeleteGrant(gid, "editor")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := db.GetGrant(gid, "editor"); err != ErrGrantNotFound {
+		t.Errorf("%v != %v", err, ErrGrantNotFound)
+	}
+	if err := db.DeleteGrant(gid, "test-user"); err != ErrLastOwner {
+		t.Errorf("%v != %v", err, ErrLastOwner)
+	}
+}
+
+func TestRole_Includes(t *testing.T) {
+	for idx, v := range []struct {
+		role   Role
+		other  Role
+		result bool
+	}{
+		{RoleOwner, RoleEditor, true},
+		{RoleEditor, RoleEditor, true},
+		{RoleContributor, RoleEditor, false},
+		{RoleViewer, RoleContributor, false},
+		{RoleNone, RoleViewer, false},
+	} {
+		if result := v.role.Includes(v.other); result != v.result {
+			t.Errorf("Test %d failed. expected: %v, result:%v", idx, v.result, result)
+		}
+	}
+}
+
+func TestMigrate_GrantOwners(t *testing.T) {
+	b := createTestBolt()
+	db, _ := New(b, &config.Config{})
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	orphan, _ := db.CreateGallery("orphan-gallery", "")
+
+	// simulate galleries stored before permissions were introduced
+	_ = b.Update(func(tx *bolt.Tx) error {
+		for _, id := range []uint64{gid, orphan} {
+			if err := tx.Bucket(galleryBucket).Bucket(itob(id)).DeleteBucket(grantsBucket); err != nil {
+				return err
+			}
+		}
+		return tx.DeleteBucket(migrationsBucket)
+	})
+
+	db, err := New(b, &config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, _ := db.GetGrants(gid); !reflect.DeepEqual(g, []Grant{{UserId: "test-user", Role: RoleOwner}}) {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+	if g, _ := db.GetGrants(orphan); len(g) != 0 {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+
+	// gallery without owner accepts any grant, and keeps owner once granted
+	if err := db.SetGrant(orphan, Grant{UserId: "viewer", Role: RoleViewer}); err != nil {
+		t.Error(err)
+	}
+	if err := db.DeleteGrant(orphan, "viewer"); err != nil {
+		t.Error(err)
+	}
+	if err := db.SetGrant(orphan, Grant{UserId: "owner", Role: RoleOwner}); err != nil {
+		t.Error(err)
+	}
+	if err := db.SetGrant(orphan, Grant{UserId: "owner", Role: RoleEditor}); err != ErrLastOwner {
+		t.Errorf("%v != %v", err, ErrLastOwner)
+	}
+}
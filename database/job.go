@@ -0,0 +1,281 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobNotFailed = errors.New("job is not failed")
+)
+
+var jobsBucket = []byte("jobs")
+
+// Kind of job
+const (
+	JobProcessImage     = "image.process"
+	JobRegenerateImages = "images.regenerate"
+)
+
+// Status of job. Completed jobs are removed from queue.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobFailed  = "failed"
+)
+
+// Progress of job working on multiple images
+type Progress struct {
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+}
+
+// Job is queued background work on image.
+// Jobs working on multiple images have zero ImageId, and zero AlbumId or GalleryId for every album or gallery.
+type Job struct {
+	Id        uint64    `json:"id"`
+	Kind      string    `json:"kind"`
+	GalleryId uint64    `json:"galleryId"`
+	AlbumId   uint64    `json:"albumId"`
+	ImageId   uint64    `json:"imageId"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Progress  *Progress `json:"progress,omitempty"`
+	// Cursor is gallery, album and image id of the last image job worked on
+	Cursor []uint64 `json:"cursor,omitempty"`
+}
+
+func readJob(v []byte) (Job, error) {
+	var j Job
+	err := json.Unmarshal(v, &j)
+	return j, err
+}
+
+func putJob(b *bolt.Bucket, j Job) error {
+	v, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(j.Id), v)
+}
+
+// addJob queues j, and returns it with id assigned. Caller should call notifyJobs after commit.
+func addJob(tx *bolt.Tx, j Job) (Job, error) {
+	b := tx.Bucket(jobsBucket)
+
+	id, err := b.NextSequence()
+	if err != nil {
+		return j, err
+	}
+
+	now := time.Now().UTC()
+	j.Id = id
+	j.Status = JobQueued
+	j.CreatedAt, j.UpdatedAt = now, now
+	return j, putJob(b, j)
+}
+
+// enqueueJob queues job of kind on image. Caller should call notifyJobs after commit.
+func enqueueJob(tx *bolt.Tx, kind string, galleryId, albumId, imageId uint64) error {
+	_, err := addJob(tx, Job{Kind: kind, GalleryId: galleryId, AlbumId: albumId, ImageId: imageId})
+	return err
+}
+
+// JobsNotify returns channel which receives when job is queued
+func (d *Database) JobsNotify() <-chan struct{} {
+	return d.jobs
+}
+
+func (d *Database) notifyJobs() {
+	select {
+	case d.jobs <- struct{}{}:
+	default:
+	}
+}
+
+// ClaimJob marks the oldest queued job running and returns it.
+// ok is false if no job is queued.
+func (d *Database) ClaimJob() (j Job, ok bool, err error) {
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobsBucket)
+
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			job, err := readJob(v)
+			if err != nil {
+				return err
+			}
+			if job.Status != JobQueued {
+				continue
+			}
+
+			job.Status = JobRunning
+			job.Attempts++
+			job.UpdatedAt = time.Now().UTC()
+			j, ok = job, true
+			return putJob(b, job)
+		}
+		return nil
+	})
+	return j, ok, err
+}
+
+// RunJob runs work of job
+func (d *Database) RunJob(j Job) error {
+	switch j.Kind {
+	case JobProcessImage:
+		return d.ProcessImage(j.GalleryId, j.AlbumId, j.ImageId)
+	case JobRegenerateImages:
+		return d.regenerateImages(j)
+	case JobWatermarkImages:
+		return d.watermarkImages(j)
+	default:
+		return fmt.Errorf("unknown job kind %q", j.Kind)
+	}
+}
+
+// CompleteJob removes finished job from queue, or marks it failed with jobErr.
+// Jobs of deleted images are removed.
+func (d *Database) CompleteJob(id uint64, jobErr error) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobsBucket)
+		v := b.Get(itob(id))
+		if v == nil {
+			return ErrJobNotFound
+		}
+
+		switch jobErr {
+		case nil, ErrGalleryNotFound, ErrAlbumNotFound, ErrImageNotFound:
+			return b.Delete(itob(id))
+		}
+
+		j, err := readJob(v)
+		if err != nil {
+			return err
+		}
+		j.Status = JobFailed
+		j.Error = jobErr.Error()
+		j.UpdatedAt = time.Now().UTC()
+		return putJob(b, j)
+	})
+}
+
+// RequeueRunningJobs queues jobs left running, such as by crash of previous process
+func (d *Database) RequeueRunningJobs() error {
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobsBucket)
+		return b.ForEach(func(k, v []byte) error {
+			j, err := readJob(v)
+			if err != nil {
+				return err
+			}
+			if j.Status != JobRunning {
+				return nil
+			}
+			j.Status = JobQueued
+			return putJob(b, j)
+		})
+	})
+	if err == nil {
+		d.notifyJobs()
+	}
+	return err
+}
+
+// GetJobs returns jobs in queue order. Empty status returns jobs of every status.
+func (d *Database) GetJobs(status string) ([]Job, error) {
+	result := make([]Job, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+			j, err := readJob(v)
+			if err != nil {
+				return err
+			}
+			if status == "" || j.Status == status {
+				result = append(result, j)
+			}
+			return nil
+		})
+	})
+
+	return result, err
+}
+
+// GetJob returns job
+func (d *Database) GetJob(id uint64) (Job, error) {
+	var j Job
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(jobsBucket).Get(itob(id))
+		if v == nil {
+			return ErrJobNotFound
+		}
+		var err error
+		j, err = readJob(v)
+		return err
+	})
+
+	return j, err
+}
+
+// RetryJob queues failed job again, and marks its image pending.
+// Jobs working on multiple images continue from their cursor.
+func (d *Database) RetryJob(id uint64) (Job, error) {
+	var j Job
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobsBucket)
+		v := b.Get(itob(id))
+		if v == nil {
+			return ErrJobNotFound
+		}
+
+		var err error
+		j, err = readJob(v)
+		if err != nil {
+			return err
+		}
+		if j.Status != JobFailed {
+			return ErrJobNotFailed
+		}
+
+		j.Status = JobQueued
+		j.Error = ""
+		j.UpdatedAt = time.Now().UTC()
+		if err := putJob(b, j); err != nil {
+			return err
+		}
+		if j.Kind != JobProcessImage {
+			return nil
+		}
+
+		_, i, err := imageBuckets(tx, j.GalleryId, j.AlbumId, j.ImageId)
+		if err == ErrImageNotFound || err == ErrAlbumNotFound || err == ErrGalleryNotFound {
+			// job of deleted image is removed when it runs
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return i.Put(statusKey, []byte(ImagePending))
+	})
+
+	if err == nil {
+		d.notifyJobs()
+	}
+	if j.Kind == JobProcessImage {
+		d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: j.GalleryId, AlbumId: j.AlbumId, ImageId: j.ImageId})
+	}
+	return j, err
+}
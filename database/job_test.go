@@ -0,0 +1,172 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/nfnt/resize"
+)
+
+// createAsyncTestDB returns database processing uploads by jobs, holding gallery and album
+func createAsyncTestDB() (*Database, uint64, uint64) {
+	db, err := New(createTestBolt(), &config.Config{Interpolation: resize.Lanczos3, Quality: 80, ProcessingWorkers: 1})
+	if err != nil {
+		panic(err)
+	}
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	return db, gid, aid
+}
+
+func TestDatabase_ProcessImageJob(t *testing.T) {
+	db, gid, aid := createAsyncTestDB()
+
+	img := createTestImage()
+	iid, err := db.AddImage(gid, aid, "test-user", &img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, _ := db.GetImageInfo(gid, aid, iid)
+	if i.Status != ImagePending {
+		t.Errorf("%q != %q", i.Status, ImagePending)
+	}
+	if _, _, err := db.GetImage(gid, aid, iid); err != ErrImageNotReady {
+		t.Errorf("%v != %v", err, ErrImageNotReady)
+	}
+	if _, _, err := db.GetThumbnail(gid, aid, iid); err != ErrImageNotReady {
+		t.Errorf("%v != %v", err, ErrImageNotReady)
+	}
+
+	select {
+	case <-db.JobsNotify():
+	default:
+		t.Error("job queue not notified")
+	}
+
+	j, ok, err := db.ClaimJob()
+	if err != nil || !ok {
+		t.Fatal("job not claimed:", ok, err)
+	}
+	if j.Kind != JobProcessImage || j.ImageId != iid || j.Status != JobRunning || j.Attempts != 1 {
+		t.Errorf("Assertion Failed: %+v", j)
+	}
+	if _, ok, _ := db.ClaimJob(); ok {
+		t.Error("running job claimed again")
+	}
+
+	if err := db.CompleteJob(j.Id, db.RunJob(j)); err != nil {
+		t.Fatal(err)
+	}
+
+	i, _ = db.GetImageInfo(gid, aid, iid)
+	if i.Status != "" {
+		t.Errorf("%q != %q", i.Status, "")
+	}
+	if _, _, err := db.GetThumbnail(gid, aid, iid); err != nil {
+		t.Error(err)
+	}
+	if jobs, _ := db.GetJobs(""); len(jobs) != 0 {
+		t.Errorf("Assertion Failed: %+v", jobs)
+	}
+}
+
+func TestDatabase_RetryJob(t *testing.T) {
+	db, gid, aid := createAsyncTestDB()
+
+	// header is valid, but image data is truncated
+	img := createTestImage()
+	truncated := bytes.NewReader(img.Bytes()[:img.Len()/2])
+	iid, err := db.AddImage(gid, aid, "test-user", truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, _, _ := db.ClaimJob()
+	runErr := db.RunJob(j)
+	if runErr != ErrInvalidImage {
+		t.Errorf("%v != %v", runErr, ErrInvalidImage)
+	}
+	if err := db.CompleteJob(j.Id, runErr); err != nil {
+		t.Fatal(err)
+	}
+
+	failed, _ := db.GetJobs(JobFailed)
+	if len(failed) != 1 || failed[0].Error != ErrInvalidImage.Error() {
+		t.Fatalf("Assertion Failed: %+v", failed)
+	}
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Status != ImageFailed {
+		t.Errorf("%q != %q", i.Status, ImageFailed)
+	}
+
+	if _, err := db.RetryJob(j.Id + 1); err != ErrJobNotFound {
+		t.Errorf("%v != %v", err, ErrJobNotFound)
+	}
+
+	j, err = db.RetryJob(j.Id)
+	if err != nil || j.Status != JobQueued || j.Error != "" {
+		t.Fatalf("Assertion Failed: %+v %v", j, err)
+	}
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Status != ImagePending {
+		t.Errorf("%q != %q", i.Status, ImagePending)
+	}
+	if _, err := db.RetryJob(j.Id); err != ErrJobNotFailed {
+		t.Errorf("%v != %v", err, ErrJobNotFailed)
+	}
+
+	// job of deleted image is removed when it completes
+	_ = db.DeleteImage(gid, aid, iid)
+	j, _, _ = db.ClaimJob()
+	if err := db.CompleteJob(j.Id, db.RunJob(j)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetJob(j.Id); err != ErrJobNotFound {
+		t.Errorf("%v != %v", err, ErrJobNotFound)
+	}
+}
+
+func TestDatabase_RequeueRunningJobs(t *testing.T) {
+	db, gid, aid := createAsyncTestDB()
+
+	img := createTestImage()
+	_, _ = db.AddImage(gid, aid, "test-user", &img)
+	j, _, _ := db.ClaimJob()
+
+	if err := db.RequeueRunningJobs(); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ = db.GetJob(j.Id); j.Status != JobQueued {
+		t.Errorf("%q != %q", j.Status, JobQueued)
+	}
+}
+
+func TestDatabase_BatchMovePendingImage(t *testing.T) {
+	db, gid, aid := createAsyncTestDB()
+	to, _ := db.CreateAlbum(gid, "to", "test-user")
+
+	img := createTestImage()
+	iid, _ := db.AddImage(gid, aid, "test-user", &img)
+
+	r, err := db.Batch(gid, []BatchOperation{{Op: BatchMove, AlbumId: aid, ImageId: iid, ToAlbumId: to}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := r[0].Image
+
+	// job of old id is dropped, and moved image is processed by new one
+	for {
+		j, ok, _ := db.ClaimJob()
+		if !ok {
+			break
+		}
+		_ = db.CompleteJob(j.Id, db.RunJob(j))
+	}
+
+	if i, _ := db.GetImageInfo(gid, to, moved.Id); i.Status != "" {
+		t.Errorf("%q != %q", i.Status, "")
+	}
+	if jobs, _ := db.GetJobs(""); len(jobs) != 0 {
+		t.Errorf("Assertion Failed: %+v", jobs)
+	}
+}
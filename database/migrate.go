@@ -0,0 +1,36 @@
+package database
+
+import "github.com/boltdb/bolt"
+
+var migrationsBucket = []byte("migrations")
+
+// migrations upgrade records stored by earlier versions.
+// Each migration is applied once, in order, when database is opened.
+var migrations = []struct {
+	name    string
+	migrate func(tx *bolt.Tx) error
+}{
+	{"files", backfillFiles},
+	{"grants", grantOwners},
+}
+
+// migrate applies migrations not applied yet
+func migrate(tx *bolt.Tx) error {
+	applied, err := tx.CreateBucketIfNotExists(migrationsBucket)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied.Get([]byte(m.name)) != nil {
+			continue
+		}
+		if err := m.migrate(tx); err != nil {
+			return err
+		}
+		if err := applied.Put([]byte(m.name), []byte{1}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
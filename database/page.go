@@ -0,0 +1,114 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Page selects range of items ordered by order, then id.
+type Page struct {
+	// After is cursor returned with previous page. Zero means first page.
+	After Cursor
+	// Limit is maximum number of items. Zero means no limit.
+	Limit int
+	// Unlisted includes unlisted galleries and albums
+	Unlisted bool
+	// UserId includes unlisted galleries user has any role on
+	UserId string
+}
+
+// Cursor is position of item in listing ordered by order, then id.
+// Zero cursor is position before first item.
+type Cursor struct {
+	Order int
+	Id    uint64
+}
+
+// ParseCursor parses cursor formatted by Cursor.String
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	id := s
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		o, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return c, ErrInvalidCursor
+		}
+		c.Order, id = o, s[i+1:]
+	}
+	v, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	c.Id = v
+	return c, nil
+}
+
+// String formats cursor as id, prefixed by order and dot unless order is zero
+func (c Cursor) String() string {
+	if c.Order == 0 {
+		return strconv.FormatUint(c.Id, 10)
+	}
+	return fmt.Sprintf("%d.%d", c.Order, c.Id)
+}
+
+// before reports whether item at c is listed before item at o
+func (c Cursor) before(o Cursor) bool {
+	if c.Order != o.Order {
+		return c.Order < o.Order
+	}
+	return c.Id < o.Id
+}
+
+// keys returns keys of items of bucket b in page ordered by order, then id, and cursor of next page.
+// Items not accepted by listed are skipped, and never hold cursor of next page.
+func (p Page) keys(b *bolt.Bucket, listed func(*bolt.Bucket) bool) ([][]byte, Cursor) {
+	var items []Cursor
+	_ = b.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return nil
+		}
+		item := b.Bucket(k)
+		c := Cursor{Order: readOrder(item), Id: btoi(k)}
+		if p.After != (Cursor{}) && !p.After.before(c) {
+			return nil
+		}
+		if listed != nil && !listed(item) {
+			return nil
+		}
+		items = append(items, c)
+		return nil
+	})
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].before(items[j])
+	})
+
+	var next Cursor
+	if p.Limit > 0 && len(items) > p.Limit {
+		items, next = items[:p.Limit], items[p.Limit-1]
+	}
+
+	keys := make([][]byte, 0, len(items))
+	for _, c := range items {
+		keys = append(keys, itob(c.Id))
+	}
+	return keys, next
+}
+
+// slice pages items without stable ids, using id of After as offset.
+func (p Page) slice(items []Image) ([]Image, Cursor) {
+	if p.After.Id >= uint64(len(items)) {
+		return make([]Image, 0), Cursor{}
+	}
+	items = items[p.After.Id:]
+	if p.Limit > 0 && len(items) > p.Limit {
+		return items[:p.Limit], Cursor{Id: p.After.Id + uint64(p.Limit)}
+	}
+	return items, Cursor{}
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func galleryIds(g []Gallery) []uint64 {
+	result := make([]uint64, 0)
+	for _, i := range g {
+		result = append(result, i.Id)
+	}
+	return result
+}
+
+func TestDatabase_GetGalleriesPage(t *testing.T) {
+	db := createTestDB()
+	for i := 0; i < 5; i++ {
+		_, err := db.CreateGallery("test-gallery", "test-user")
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	for idx, v := range []struct {
+		page Page
+		ids  []uint64
+		next Cursor
+	}{
+		{Page{}, []uint64{1, 2, 3, 4, 5}, Cursor{}},
+		{Page{Limit: 2}, []uint64{1, 2}, Cursor{Id: 2}},
+		{Page{After: Cursor{Id: 2}, Limit: 2}, []uint64{3, 4}, Cursor{Id: 4}},
+		{Page{After: Cursor{Id: 4}, Limit: 2}, []uint64{5}, Cursor{}},
+		{Page{After: Cursor{Id: 3}, Limit: 2}, []uint64{4, 5}, Cursor{}},
+		{Page{After: Cursor{Id: 5}}, []uint64{}, Cursor{}},
+		{Page{Limit: 2, UserId: "test-user"}, []uint64{1, 2}, Cursor{Id: 2}},
+	} {
+		g, next, err := db.GetGalleriesPage(v.page)
+		if err != nil {
+			t.Error(idx, err)
+			continue
+		}
+		if !reflect.DeepEqual(galleryIds(g), v.ids) || next != v.next {
+			t.Errorf("Test %d failed. expected: %v %d, result:%v %v", idx, v.ids, v.next, galleryIds(g), next)
+		}
+	}
+
+	// unlisted galleries are listed to users with role on them
+	unlisted := VisibilityUnlisted
+	for _, id := range []uint64{2, 3} {
+		if _, err := db.UpdateGallery(id, GalleryPatch{Visibility: &unlisted}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SetGrant(3, Grant{UserId: "other-user", Role: RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+	for idx, v := range []struct {
+		page Page
+		ids  []uint64
+	}{
+		{Page{}, []uint64{1, 4, 5}},
+		{Page{UserId: "other-user"}, []uint64{1, 3, 4, 5}},
+		{Page{UserId: "test-user"}, []uint64{1, 2, 3, 4, 5}},
+		{Page{Unlisted: true}, []uint64{1, 2, 3, 4, 5}},
+	} {
+		if g, _, err := db.GetGalleriesPage(v.page); err != nil || !reflect.DeepEqual(galleryIds(g), v.ids) {
+			t.Errorf("Test %d failed. expected: %v, result:%v %v", idx, v.ids, galleryIds(g), err)
+		}
+	}
+}
+
+func TestDatabase_GetAlbumsPage(t *testing.T) {
+	db := createTestDB()
+	gid, err := db.CreateGallery("test-gallery", "test-user")
+	if err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := db.CreateAlbum(gid, "test-album", "test-user")
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	a, next, err := db.GetAlbumsPage(gid, Page{After: Cursor{Id: 1}, Limit: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(a) != 1 || a[0].Id != 2 || next != (Cursor{Id: 2}) {
+		t.Errorf("Assertion Failed: %+v %v", a, next)
+	}
+
+	// cursor is not returned when only unlisted albums follow
+	unlisted := VisibilityUnlisted
+	if _, err := db.UpdateAlbum(gid, 3, AlbumPatch{Visibility: &unlisted}, nil); err != nil {
+		t.Fatal(err)
+	}
+	a, next, err = db.GetAlbumsPage(gid, Page{After: Cursor{Id: 1}, Limit: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(a) != 1 || a[0].Id != 2 || next != (Cursor{}) {
+		t.Errorf("Assertion Failed: %+v %v", a, next)
+	}
+	a, next, err = db.GetAlbumsPage(gid, Page{After: Cursor{Id: 1}, Limit: 1, Unlisted: true})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(a) != 1 || a[0].Id != 2 || next != (Cursor{Id: 2}) {
+		t.Errorf("Assertion Failed: %+v %v", a, next)
+	}
+}
+
+func TestDatabase_GetImagesPage(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	img := createTestImage()
+	_, err := db.AddImage(gid, 1, "test-user", &img)
+	if err != nil {
+		t.Error(err)
+	}
+
+	i, next, err := db.GetImagesPage(gid, 1, Page{Limit: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(i) != 1 || i[0].Id != 1 || next != (Cursor{Id: 1}) {
+		t.Errorf("Assertion Failed: %+v %v", i, next)
+	}
+
+	aid, err := db.CreateSmartAlbum(gid, "smart-album", "test-user", SmartQuery{})
+	if err != nil {
+		t.Error(err)
+	}
+	i, next, err = db.GetImagesPage(gid, aid, Page{After: Cursor{Id: 1}, Limit: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(i) != 1 || next != (Cursor{Id: 2}) {
+		t.Errorf("Assertion Failed: %+v %v", i, next)
+	}
+	i, next, err = db.GetImagesPage(gid, aid, Page{After: Cursor{Id: 2}, Limit: 1})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(i) != 1 || next != (Cursor{}) {
+		t.Errorf("Assertion Failed: %+v %v", i, next)
+	}
+}
+
+func TestDatabase_PageOrder(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	for i := 0; i < 4; i++ {
+		if _, err := db.CreateAlbum(gid, "test-album", "test-user"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for id, order := range map[uint64]int{1: 2, 3: -1} {
+		order := order
+		if _, err := db.UpdateAlbum(gid, id, AlbumPatch{Order: &order}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for idx, v := range []struct {
+		page Page
+		ids  []uint64
+		next Cursor
+	}{
+		{Page{}, []uint64{3, 2, 4, 1}, Cursor{}},
+		{Page{Limit: 2}, []uint64{3, 2}, Cursor{Id: 2}},
+		{Page{After: Cursor{Id: 2}, Limit: 2}, []uint64{4, 1}, Cursor{}},
+		{Page{After: Cursor{Order: -1, Id: 3}, Limit: 1}, []uint64{2}, Cursor{Id: 2}},
+		{Page{After: Cursor{Id: 4}, Limit: 1}, []uint64{1}, Cursor{}},
+	} {
+		a, next, err := db.GetAlbumsPage(gid, v.page)
+		ids := make([]uint64, 0)
+		for _, i := range a {
+			ids = append(ids, i.Id)
+		}
+		if err != nil || !reflect.DeepEqual(ids, v.ids) || next != v.next {
+			t.Errorf("Test %d failed. expected: %v %v, result:%v %v %v", idx, v.ids, v.next, ids, next, err)
+		}
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	for idx, v := range []struct {
+		text   string
+		cursor Cursor
+		err    error
+	}{
+		{"0", Cursor{}, nil},
+		{"5", Cursor{Id: 5}, nil},
+		{"-1.3", Cursor{Order: -1, Id: 3}, nil},
+		{"2.", Cursor{}, ErrInvalidCursor},
+		{"a", Cursor{}, ErrInvalidCursor},
+	} {
+		c, err := ParseCursor(v.text)
+		if err != v.err || err == nil && c != v.cursor {
+			t.Errorf("Test %d failed. expected: %v %v, result:%v %v", idx, v.cursor, v.err, c, err)
+		}
+		if err == nil && v.text != "0" && c.String() != v.text {
+			t.Errorf("Test %d failed. expected: %s, result:%s", idx, v.text, c.String())
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	ErrInvalidVisibility = errors.New("invalid visibility")
+	ErrInvalidMetadata   = errors.New("invalid metadata")
+	ErrInvalidCover      = errors.New("cover is not image of album")
+)
+
+var (
+	visibilityKey = []byte("visibility")
+	orderKey      = []byte("order")
+	metadataKey   = []byte("metadata")
+	coverKey      = []byte("cover")
+)
+
+const (
+	maxMetadataKeys        = 32
+	maxMetadataKeyLength   = 64
+	maxMetadataValueLength = 1024
+)
+
+// Visibility controls whether gallery or album is listed.
+// Unlisted galleries and albums are still reachable by id.
+type Visibility string
+
+const (
+	VisibilityPublic   Visibility = "public"
+	VisibilityUnlisted Visibility = "unlisted"
+)
+
+func (v Visibility) Valid() bool {
+	return v == VisibilityPublic || v == VisibilityUnlisted
+}
+
+// GalleryPatch holds changed fields of gallery. Nil fields are left unchanged,
+// unless Replace is set, in which case they are reset.
+// Metadata keys with nil value are removed.
+type GalleryPatch struct {
+	Title      *string            `json:"title"`
+	Visibility *Visibility        `json:"visibility"`
+	Order      *int               `json:"order"`
+	Metadata   map[string]*string `json:"metadata"`
+	Replace    bool               `json:"-"`
+}
+
+// AlbumPatch holds changed fields of album. See GalleryPatch.
+// Zero Cover resets cover to first image of album.
+// Query can only be set on smart album, and is never reset by Replace.
+type AlbumPatch struct {
+	Title      *string            `json:"title"`
+	Cover      *uint64            `json:"cover"`
+	Visibility *Visibility        `json:"visibility"`
+	Order      *int               `json:"order"`
+	Metadata   map[string]*string `json:"metadata"`
+	Query      *SmartQuery        `json:"query"`
+	Replace    bool               `json:"-"`
+}
+
+// ImagePatch holds changed fields of image. See GalleryPatch.
+type ImagePatch struct {
+	Description *string            `json:"description"`
+	Order       *int               `json:"order"`
+	Metadata    map[string]*string `json:"metadata"`
+	Replace     bool               `json:"-"`
+}
+
+func readVisibility(b *bolt.Bucket) Visibility {
+	if v := b.Get(visibilityKey); v != nil {
+		return Visibility(v)
+	}
+	return ""
+}
+
+func readOrder(b *bolt.Bucket) int {
+	o, _ := strconv.Atoi(string(b.Get(orderKey)))
+	return o
+}
+
+func readMetadata(b *bolt.Bucket) map[string]string {
+	v := b.Get(metadataKey)
+	if v == nil {
+		return nil
+	}
+
+	var result map[string]string
+	if err := json.Unmarshal(v, &result); err != nil || len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// listed reports whether gallery or album bucket is listed to page
+func listed(b *bolt.Bucket, page Page) bool {
+	return page.Unlisted || readVisibility(b) != VisibilityUnlisted
+}
+
+func putString(b *bolt.Bucket, key []byte, value *string, replace bool) error {
+	if value == nil {
+		if !replace {
+			return nil
+		}
+		return b.Put(key, []byte(""))
+	}
+	return b.Put(key, []byte(*value))
+}
+
+func putVisibility(b *bolt.Bucket, v *Visibility, replace bool) error {
+	if v == nil {
+		if !replace {
+			return nil
+		}
+		return b.Delete(visibilityKey)
+	}
+	if !v.Valid() {
+		return ErrInvalidVisibility
+	}
+	if *v == VisibilityPublic {
+		return b.Delete(visibilityKey)
+	}
+	return b.Put(visibilityKey, []byte(*v))
+}
+
+func putOrder(b *bolt.Bucket, o *int, replace bool) error {
+	if o == nil && !replace {
+		return nil
+	}
+	if o == nil || *o == 0 {
+		return b.Delete(orderKey)
+	}
+	return b.Put(orderKey, []byte(strconv.Itoa(*o)))
+}
+
+// putMetadata merges patch into metadata, or replaces metadata with patch if replace is set.
+func putMetadata(b *bolt.Bucket, patch map[string]*string, replace bool) error {
+	if patch == nil && !replace {
+		return nil
+	}
+
+	m := readMetadata(b)
+	if m == nil || replace {
+		m = make(map[string]string)
+	}
+
+	for k, v := range patch {
+		if v == nil {
+			delete(m, k)
+			continue
+		}
+		if k == "" || utf8.RuneCountInString(k) > maxMetadataKeyLength || utf8.RuneCountInString(*v) > maxMetadataValueLength {
+			return ErrInvalidMetadata
+		}
+		m[k] = *v
+	}
+
+	if len(m) > maxMetadataKeys {
+		return ErrInvalidMetadata
+	}
+	if len(m) == 0 {
+		return b.Delete(metadataKey)
+	}
+
+	v, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return b.Put(metadataKey, v)
+}
+
+// UpdateGallery applies patch to gallery and returns updated gallery.
+// If check is not nil, it is called with current gallery in the same transaction,
+// and update is aborted when it returns error.
+func (d *Database) UpdateGallery(galleryId uint64, patch GalleryPatch, check func(Gallery) error) (Gallery, error) {
+	var result Gallery
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+
+		if check != nil {
+			if err := check(readGallery(galleryId, g)); err != nil {
+				return err
+			}
+		}
+
+		if err := putString(g, titleKey, patch.Title, patch.Replace); err != nil {
+			return err
+		}
+		if err := putVisibility(g, patch.Visibility, patch.Replace); err != nil {
+			return err
+		}
+		if err := putOrder(g, patch.Order, patch.Replace); err != nil {
+			return err
+		}
+		if err := putMetadata(g, patch.Metadata, patch.Replace); err != nil {
+			return err
+		}
+
+		result = readGallery(galleryId, g)
+		return indexDocument(tx, documentRef(galleryId, 0, 0), result.Title)
+	})
+
+	d.publish(err, Event{Entity: EntityGallery, Action: ActionUpdate, GalleryId: galleryId})
+	return result, err
+}
+
+// UpdateAlbum applies patch to album and returns updated album. See UpdateGallery.
+func (d *Database) UpdateAlbum(galleryId, albumId uint64, patch AlbumPatch, check func(Album) error) (Album, error) {
+	var result Album
+
+	var query *SmartQuery
+	if patch.Query != nil {
+		q, err := patch.Query.normalize()
+		if err != nil {
+			return result, err
+		}
+		query = &q
+	}
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+		a := g.Bucket(albumsBucket).Bucket(itob(albumId))
+		if a == nil {
+			return ErrAlbumNotFound
+		}
+
+		if check != nil {
+			current, err := readAlbum(albumId, a)
+			if err != nil {
+				return err
+			}
+			if err := check(current); err != nil {
+				return err
+			}
+		}
+
+		if query != nil {
+			if a.Get(queryKey) == nil {
+				return ErrNotSmartAlbum
+			}
+			if err := putSmartQuery(a, *query); err != nil {
+				return err
+			}
+		}
+
+		if patch.Cover != nil && *patch.Cover != 0 {
+			if a.Bucket(imagesBucket).Bucket(itob(*patch.Cover)) == nil {
+				return ErrInvalidCover
+			}
+			if err := a.Put(coverKey, itob(*patch.Cover)); err != nil {
+				return err
+			}
+		} else if patch.Cover != nil || patch.Replace {
+			if err := a.Delete(coverKey); err != nil {
+				return err
+			}
+		}
+
+		if err := putString(a, titleKey, patch.Title, patch.Replace); err != nil {
+			return err
+		}
+		if err := putVisibility(a, patch.Visibility, patch.Replace); err != nil {
+			return err
+		}
+		if err := putOrder(a, patch.Order, patch.Replace); err != nil {
+			return err
+		}
+		if err := putMetadata(a, patch.Metadata, patch.Replace); err != nil {
+			return err
+		}
+
+		var err error
+		result, err = readAlbum(albumId, a)
+		if err != nil {
+			return err
+		}
+		return indexDocument(tx, documentRef(galleryId, albumId, 0), result.Title)
+	})
+
+	d.publish(err, Event{Entity: EntityAlbum, Action: ActionUpdate, GalleryId: galleryId, AlbumId: albumId})
+	return result, err
+}
+
+// UpdateImage applies patch to image and returns updated image. See UpdateGallery.
+func (d *Database) UpdateImage(galleryId, albumId, imageId uint64, patch ImagePatch, check func(Image) error) (Image, error) {
+	var result Image
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		result, err = updateImage(tx, galleryId, albumId, imageId, patch, check)
+		return err
+	})
+
+	d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: galleryId, AlbumId: albumId, ImageId: imageId})
+	return result, err
+}
+
+func updateImage(tx *bolt.Tx, galleryId, albumId, imageId uint64, patch ImagePatch, check func(Image) error) (Image, error) {
+	g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+	if err != nil {
+		return Image{}, err
+	}
+
+	if check != nil {
+		if err := check(readImage(imageId, g, i)); err != nil {
+			return Image{}, err
+		}
+	}
+
+	if err := putString(i, descriptionKey, patch.Description, patch.Replace); err != nil {
+		return Image{}, err
+	}
+	if err := putOrder(i, patch.Order, patch.Replace); err != nil {
+		return Image{}, err
+	}
+	if err := putMetadata(i, patch.Metadata, patch.Replace); err != nil {
+		return Image{}, err
+	}
+
+	return readImage(imageId, g, i), indexDocument(tx, documentRef(galleryId, albumId, imageId), imageText(i))
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestDatabase_UpdateGallery(t *testing.T) {
+	db := createTestDB()
+	gid, err := db.CreateGallery("hello", "test-user")
+	if err != nil {
+		t.Error(err)
+	}
+
+	unlisted := VisibilityUnlisted
+	order := 3
+	g, err := db.UpdateGallery(gid, GalleryPatch{
+		Visibility: &unlisted,
+		Order:      &order,
+		Metadata:   map[string]*string{"location": stringPtr("Seoul"), "season": stringPtr("spring")},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := Gallery{Id: gid, Title: "hello", Owner: "test-user", Visibility: VisibilityUnlisted, Order: 3, Metadata: map[string]string{"location": "Seoul", "season": "spring"}}
+	if !reflect.DeepEqual(g, expected) {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+
+	g, err = db.UpdateGallery(gid, GalleryPatch{Title: stringPtr("world"), Metadata: map[string]*string{"season": nil}}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	expected.Title = "world"
+	expected.Metadata = map[string]string{"location": "Seoul"}
+	if !reflect.DeepEqual(g, expected) {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+
+	if g, err := db.GetGallery(gid); err != nil || !reflect.DeepEqual(g, expected) {
+		t.Errorf("Assertion Failed: %+v %v", g, err)
+	}
+
+	g, err = db.UpdateGallery(gid, GalleryPatch{Title: stringPtr("replaced"), Replace: true}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(g, Gallery{Id: gid, Title: "replaced", Owner: "test-user"}) {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+
+	errCheck := errors.New("check failed")
+	_, err = db.UpdateGallery(gid, GalleryPatch{Title: stringPtr("aborted")}, func(g Gallery) error {
+		if g.Title != "replaced" {
+			t.Errorf("Assertion Failed: %+v", g)
+		}
+		return errCheck
+	})
+	if err != errCheck {
+		t.Errorf("%v != %v", err, errCheck)
+	}
+	if g, _ := db.GetGallery(gid); g.Title != "replaced" {
+		t.Errorf("Assertion Failed: %+v", g)
+	}
+
+	invalid := Visibility("secret")
+	if _, err := db.UpdateGallery(gid, GalleryPatch{Visibility: &invalid}, nil); err != ErrInvalidVisibility {
+		t.Errorf("%v != %v", err, ErrInvalidVisibility)
+	}
+	long := strings.Repeat("a", maxMetadataValueLength+1)
+	if _, err := db.UpdateGallery(gid, GalleryPatch{Metadata: map[string]*string{"a": &long}}, nil); err != ErrInvalidMetadata {
+		t.Errorf("%v != %v", err, ErrInvalidMetadata)
+	}
+	if _, err := db.UpdateGallery(gid+1, GalleryPatch{}, nil); err != ErrGalleryNotFound {
+		t.Errorf("%v != %v", err, ErrGalleryNotFound)
+	}
+}
+
+func TestDatabase_UpdateAlbum(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("hello", "test-user")
+	aid, _ := db.CreateAlbum(gid, "album", "test-user")
+	for i := 0; i < 2; i++ {
+		img := createTestImage()
+		if _, err := db.AddImage(gid, aid, "test-user", &img); err != nil {
+			t.Error(err)
+		}
+	}
+
+	cover := uint64(2)
+	a, err := db.UpdateAlbum(gid, aid, AlbumPatch{Cover: &cover}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if a.Cover != 2 {
+		t.Errorf("Assertion Failed: %+v", a)
+	}
+
+	cover = 3
+	if _, err := db.UpdateAlbum(gid, aid, AlbumPatch{Cover: &cover}, nil); err != ErrInvalidCover {
+		t.Errorf("%v != %v", err, ErrInvalidCover)
+	}
+
+	if err := db.DeleteImage(gid, aid, 2); err != nil {
+		t.Error(err)
+	}
+	if a, _ := db.GetAlbum(gid, aid); a.Cover != 1 {
+		t.Errorf("Assertion Failed: %+v", a)
+	}
+
+	if _, err := db.UpdateAlbum(gid, aid, AlbumPatch{Query: &SmartQuery{}}, nil); err != ErrNotSmartAlbum {
+		t.Errorf("%v != %v", err, ErrNotSmartAlbum)
+	}
+
+	unlisted := VisibilityUnlisted
+	if _, err := db.UpdateAlbum(gid, aid, AlbumPatch{Visibility: &unlisted}, nil); err != nil {
+		t.Error(err)
+	}
+	if a, _, _ := db.GetAlbumsPage(gid, Page{}); len(a) != 0 {
+		t.Errorf("Assertion Failed: %+v", a)
+	}
+	if a, _, _ := db.GetAlbumsPage(gid, Page{Unlisted: true}); len(a) != 1 {
+		t.Errorf("Assertion Failed: %+v", a)
+	}
+}
+
+func TestDatabase_UpdateImage(t *testing.T) {
+	db, gid := createTaggedTestDB()
+
+	order := -1
+	i, err := db.UpdateImage(gid, 1, 1, ImagePatch{Description: stringPtr("searchable"), Order: &order, Metadata: map[string]*string{"lens": stringPtr("50mm")}}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if i.Description != "searchable" || i.Order != -1 || !reflect.DeepEqual(i.Metadata, map[string]string{"lens": "50mm"}) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	r, err := db.Search("searchable", 0, SearchScope{})
+	if err != nil || len(r) != 1 || r[0].ImageId != 1 {
+		t.Errorf("Assertion Failed: %+v %v", r, err)
+	}
+
+	i, err = db.UpdateImage(gid, 1, 1, ImagePatch{Replace: true}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if i.Description != "" || i.Order != 0 || i.Metadata != nil {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+}
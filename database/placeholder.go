@@ -0,0 +1,199 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+
+	"github.com/boltdb/bolt"
+	"github.com/nfnt/resize"
+)
+
+var placeholderKey = []byte("placeholder")
+
+const (
+	blurHashX   = 4
+	blurHashY   = 3
+	lqipSize    = 16
+	lqipQuality = 40
+	// sampleSize is edge length of image sampled for BlurHash and dominant color
+	sampleSize = 32
+)
+
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// placeholder is shown while image loads, and reserves its layout
+type placeholder struct {
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	BlurHash string `json:"blurHash"`
+	LQIP     string `json:"lqip"`
+	Color    string `json:"color"`
+}
+
+// newPlaceholder returns placeholder of img
+func newPlaceholder(img image.Image, interpolation resize.InterpolationFunction) (placeholder, error) {
+	b := img.Bounds()
+	p := placeholder{Width: b.Dx(), Height: b.Dy()}
+
+	sample := resize.Resize(sampleSize, sampleSize, img, interpolation)
+	p.BlurHash = blurHash(sample, blurHashX, blurHashY)
+	p.Color = dominantColor(sample)
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, resize.Thumbnail(lqipSize, lqipSize, img, interpolation), &jpeg.Options{Quality: lqipQuality})
+	if err != nil {
+		return p, err
+	}
+	p.LQIP = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	return p, nil
+}
+
+func putPlaceholder(data *bolt.Bucket, p placeholder) error {
+	v, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return data.Put(placeholderKey, v)
+}
+
+func readPlaceholder(data *bolt.Bucket) (placeholder, bool) {
+	var p placeholder
+	v := data.Get(placeholderKey)
+	if v == nil || json.Unmarshal(v, &p) != nil {
+		return p, false
+	}
+	return p, true
+}
+
+func encode83(value, length int) string {
+	result := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		result[i] = base83[value%83]
+		value /= 83
+	}
+	return string(result)
+}
+
+func sRGBToLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	c := math.Max(0, math.Min(1, v))
+	if c <= 0.0031308 {
+		return int(c*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(c, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// blurHash encodes img into BlurHash of x by y components.
+// See https://github.com/woltapp/blurhash for the algorithm.
+func blurHash(img image.Image, x, y int) string {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	factors := make([][3]float64, 0, x*y)
+	for j := 0; j < y; j++ {
+		for i := 0; i < x; i++ {
+			var f [3]float64
+			for py := 0; py < h; py++ {
+				for px := 0; px < w; px++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(px)/float64(w)) * math.Cos(math.Pi*float64(j)*float64(py)/float64(h))
+					c := color.NRGBAModel.Convert(img.At(b.Min.X+px, b.Min.Y+py)).(color.NRGBA)
+					f[0] += basis * sRGBToLinear(c.R)
+					f[1] += basis * sRGBToLinear(c.G)
+					f[2] += basis * sRGBToLinear(c.B)
+				}
+			}
+
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			scale := normalisation / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	hash := encode83((x-1)+(y-1)*9, 1)
+
+	maximum := 1.0
+	if ac := factors[1:]; len(ac) > 0 {
+		actual := 0.0
+		for _, f := range ac {
+			for _, c := range f {
+				actual = math.Max(actual, math.Abs(c))
+			}
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actual*166-0.5))))
+		maximum = float64(quantised+1) / 166
+		hash += encode83(quantised, 1)
+	} else {
+		hash += encode83(0, 1)
+	}
+
+	dc := factors[0]
+	hash += encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4)
+
+	for _, f := range factors[1:] {
+		var q [3]int
+		for idx, c := range f {
+			q[idx] = int(math.Max(0, math.Min(18, math.Floor(signPow(c/maximum, 0.5)*9+9.5))))
+		}
+		hash += encode83(q[0]*19*19+q[1]*19+q[2], 2)
+	}
+
+	return hash
+}
+
+// dominantColor returns the most frequent color of img in #rrggbb.
+// Colors are counted in buckets of 4 bits per channel, and pixels of the most frequent bucket are averaged.
+func dominantColor(img image.Image) string {
+	type sum struct {
+		n, r, g, b int
+	}
+	buckets := make(map[int]*sum)
+
+	var best *sum
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			k := int(c.R>>4)<<8 | int(c.G>>4)<<4 | int(c.B>>4)
+
+			s, ok := buckets[k]
+			if !ok {
+				s = &sum{}
+				buckets[k] = s
+			}
+			s.n++
+			s.r += int(c.R)
+			s.g += int(c.G)
+			s.b += int(c.B)
+
+			if best == nil || s.n > best.n {
+				best = s
+			}
+		}
+	}
+
+	if best == nil {
+		return "#000000"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.n, best.g/best.n, best.b/best.n)
+}
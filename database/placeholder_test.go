@@ -0,0 +1,107 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+func TestNewPlaceholder(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			c := color.RGBA{R: 200, G: 30, B: 40, A: 255}
+			if x < 64 {
+				c = color.RGBA{R: 20, G: 20, B: 220, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	p, err := newPlaceholder(img, resize.Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Width != 320 || p.Height != 240 {
+		t.Errorf("Assertion Failed: %dx%d", p.Width, p.Height)
+	}
+	if c := dominantColor(img); c != "#c81e28" {
+		t.Errorf("%q != %q", c, "#c81e28")
+	}
+	if !strings.HasPrefix(p.Color, "#c") {
+		t.Errorf("Assertion Failed: %q", p.Color)
+	}
+	if len(p.BlurHash) != 6+2*(blurHashX*blurHashY-1) {
+		t.Errorf("Assertion Failed: %q", p.BlurHash)
+	}
+
+	const prefix = "data:image/jpeg;base64,"
+	if !strings.HasPrefix(p.LQIP, prefix) {
+		t.Fatalf("Assertion Failed: %q", p.LQIP)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(p.LQIP, prefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lqip, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lqip.Bounds() != image.Rect(0, 0, 16, 12) {
+		t.Errorf("%v != %v", lqip.Bounds(), image.Rect(0, 0, 16, 12))
+	}
+}
+
+func TestBlurHash(t *testing.T) {
+	for idx, c := range []struct {
+		color    color.Color
+		blurHash string
+	}{
+		{color.Black, "L00000fQfQfQfQfQfQfQfQfQfQfQ"},
+		{color.White, "L9TSUA~qfQ~q~qoffQoffQfQfQfQ"},
+	} {
+		img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				img.Set(x, y, c.color)
+			}
+		}
+		if h := blurHash(img, blurHashX, blurHashY); h != c.blurHash {
+			t.Errorf("%d: %q != %q", idx, h, c.blurHash)
+		}
+	}
+}
+
+func TestDatabase_GetImagePlaceholder(t *testing.T) {
+	db, gid, aid := createAsyncTestDB()
+
+	img := createTestImage()
+	iid, err := db.AddImage(gid, aid, "test-user", &img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// placeholder is available while image is processed
+	i, err := db.GetImageInfo(gid, aid, iid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := processedTestImage(Image{Id: iid, Owner: "test-user", Status: i.Status})
+	expected.Files.Display, expected.Files.Thumbnail = nil, nil
+	if i.Status == "" || !reflect.DeepEqual(i, expected) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	runJobs(db)
+
+	if i, _ := db.GetImageInfo(gid, aid, iid); !reflect.DeepEqual(i, processedTestImage(Image{Id: iid, Owner: "test-user"})) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+}
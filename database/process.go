@@ -0,0 +1,254 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/nfnt/resize"
+)
+
+var ErrImageNotReady = errors.New("image is not processed yet")
+
+var (
+	originalKey = []byte("original")
+	statusKey   = []byte("status")
+	renderedKey = []byte("rendered")
+)
+
+// Processing status of image. Processed images have no status.
+const (
+	ImagePending = "pending"
+	ImageFailed  = "failed"
+)
+
+// clearStatus marks image bucket i processed.
+// Absent status is not deleted, as bolt fails deleting missing key sorted right before nested bucket.
+func clearStatus(i *bolt.Bucket) error {
+	if i.Get(statusKey) == nil {
+		return nil
+	}
+	return i.Delete(statusKey)
+}
+
+// touchRendered records that renditions held by bucket b changed now
+func touchRendered(b *bolt.Bucket) error {
+	return b.Put(renderedKey, itob(uint64(time.Now().UnixNano())))
+}
+
+// renderedTime returns time renditions served for image bucket i last changed.
+// Renditions are changed by image itself, and by images sharing its blob. Upload time is used for images rendered before.
+func renderedTime(i *bolt.Bucket) time.Time {
+	t := time.Unix(1, 0)
+	for _, v := range [][]byte{i.Get(timestampKey), i.Get(renderedKey), imageData(i).Get(renderedKey)} {
+		if v == nil {
+			continue
+		}
+		if mt := time.Unix(0, int64(btoi(v))); mt.After(t) {
+			t = mt
+		}
+	}
+	return t
+}
+
+// rendition is data derived from uploaded image
+type rendition struct {
+	image       []byte
+	thumbnail   []byte
+	deepZoom    DeepZoom
+	tiles       map[string][]byte
+	hash        uint64
+	placeholder placeholder
+	watermarked watermarked
+}
+
+// checkImage returns error unless header of data is of supported image format
+func checkImage(data []byte) error {
+	_, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return ErrUnsupportedFormat
+	}
+	if err != nil {
+		return ErrInvalidImage
+	}
+	return nil
+}
+
+// decodeImage decodes uploaded image
+func decodeImage(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	return img, nil
+}
+
+// encodeThumbnail encodes img scaled down to thumbnail size
+func (d *Database) encodeThumbnail(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, resize.Thumbnail(640, 360, img, d.cfg.Interpolation), &jpeg.Options{Quality: d.cfg.Quality})
+	return buf.Bytes(), err
+}
+
+// render decodes uploaded image, applies edits to it, and encodes display image, thumbnail and tile pyramid of it.
+// Tile pyramid is generated if tiled is set, or image is larger than deep zoom threshold.
+// Renditions are also encoded with watermark of gallery state s, if any.
+func (d *Database) render(data []byte, edits []Edit, tiled bool, s watermarkState) (rendition, error) {
+	var r rendition
+
+	img, err := decodeImage(data)
+	if err != nil {
+		return r, err
+	}
+	img = applyEdits(img, edits)
+
+	r.thumbnail, err = d.encodeThumbnail(img)
+	if err != nil {
+		return r, err
+	}
+
+	var iBuff bytes.Buffer
+	err = jpeg.Encode(&iBuff, img, &jpeg.Options{Quality: d.cfg.Quality})
+	if err != nil {
+		return r, err
+	}
+	r.image = iBuff.Bytes()
+	r.hash = perceptualHash(img)
+
+	r.placeholder, err = newPlaceholder(img, d.cfg.Interpolation)
+	if err != nil {
+		return r, err
+	}
+
+	tiled = tiled || d.cfg.DeepZoomThreshold > 0 && (img.Bounds().Dx() > d.cfg.DeepZoomThreshold || img.Bounds().Dy() > d.cfg.DeepZoomThreshold)
+	if tiled {
+		r.deepZoom, r.tiles, err = generateTiles(img, d.cfg.TileSize, d.cfg.Interpolation, d.cfg.Quality)
+		if err != nil {
+			return r, err
+		}
+	}
+
+	r.watermarked, err = d.watermark(img, tiled, s)
+	return r, err
+}
+
+// put stores rendition in data of image bucket i referred by ref, and marks image processed
+func (r rendition) put(tx *bolt.Tx, ref []byte, i *bolt.Bucket) error {
+	data := imageData(i)
+
+	err := data.Put(thumbnailKey, r.thumbnail)
+	if err != nil {
+		return err
+	}
+
+	err = data.Put(imageKey, r.image)
+	if err != nil {
+		return err
+	}
+
+	if r.tiles != nil {
+		err = putTiles(data, r.deepZoom, r.tiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = data.Put(hashKey, itob(r.hash))
+	if err != nil {
+		return err
+	}
+
+	err = putPlaceholder(data, r.placeholder)
+	if err != nil {
+		return err
+	}
+
+	err = putFiles(data)
+	if err != nil {
+		return err
+	}
+
+	err = touchRendered(data)
+	if err != nil {
+		return err
+	}
+
+	err = putWatermarked(tx, ref, i, r.watermarked)
+	if err != nil {
+		return err
+	}
+
+	err = putHash(tx, i, ref, r.hash)
+	if err != nil {
+		return err
+	}
+
+	return clearStatus(i)
+}
+
+// ProcessImage renders stored original of image.
+// Image is marked failed if its original can not be decoded.
+// Image sharing blob rendered by job of identical upload is not rendered again.
+func (d *Database) ProcessImage(galleryId, albumId, imageId uint64) error {
+	var original, display []byte
+	var edits []Edit
+	var s watermarkState
+	rendered, tiled := false, false
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+		s, err = readWatermarkState(g)
+		if err != nil {
+			return err
+		}
+		data := imageData(i)
+		rendered = data.Get(imageKey) != nil
+		original = append([]byte{}, readOriginal(data)...)
+		display = append([]byte{}, data.Get(imageKey)...)
+		tiled = data.Get(deepZoomKey) != nil
+		edits, err = readEdits(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	var r rendition
+	var renderErr error
+	if rendered {
+		r.watermarked, renderErr = d.watermarkShared(display, tiled, s)
+	} else {
+		r, renderErr = d.render(original, edits, false, s)
+	}
+
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+		ref := documentRef(galleryId, albumId, imageId)
+		switch {
+		case renderErr != nil:
+			return i.Put(statusKey, []byte(ImageFailed))
+		case rendered:
+			return putShared(tx, ref, i, r.watermarked)
+		default:
+			return r.put(tx, ref, i)
+		}
+	})
+	d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: galleryId, AlbumId: albumId, ImageId: imageId})
+
+	if err != nil {
+		return err
+	}
+	return renderErr
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// imagePath is gallery, album and image id of image
+type imagePath [3]uint64
+
+// after reports whether r comes after cursor in id order. Every image comes after empty cursor.
+func (r imagePath) after(cursor []uint64) bool {
+	if len(cursor) != len(r) {
+		return true
+	}
+	for idx := range r {
+		if r[idx] != cursor[idx] {
+			return r[idx] > cursor[idx]
+		}
+	}
+	return false
+}
+
+// scopeImages returns images of album in id order.
+// Zero albumId returns images of every album of gallery, and zero galleryId of every gallery.
+func scopeImages(tx *bolt.Tx, galleryId, albumId uint64) ([]imagePath, error) {
+	result := make([]imagePath, 0)
+
+	galleries := tx.Bucket(galleryBucket)
+	err := galleries.ForEach(func(gk, gv []byte) error {
+		gid := btoi(gk)
+		if gv != nil || galleryId != 0 && gid != galleryId {
+			return nil
+		}
+
+		albums := galleries.Bucket(gk).Bucket(albumsBucket)
+		return albums.ForEach(func(ak, av []byte) error {
+			aid := btoi(ak)
+			if av != nil || albumId != 0 && aid != albumId {
+				return nil
+			}
+
+			images := albums.Bucket(ak).Bucket(imagesBucket)
+			return images.ForEach(func(ik, iv []byte) error {
+				if iv == nil {
+					result = append(result, imagePath{gid, aid, btoi(ik)})
+				}
+				return nil
+			})
+		})
+	})
+
+	return result, err
+}
+
+// RegenerateImages queues job rendering images of album again from their originals, with current config.
+// Zero albumId regenerates every album of gallery, and zero galleryId every gallery.
+// Queued or running job of the same scope is returned instead of queueing another one.
+func (d *Database) RegenerateImages(galleryId, albumId uint64) (Job, error) {
+	var j Job
+	queued := false
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		if galleryId != 0 || albumId != 0 {
+			g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+			if g == nil {
+				return ErrGalleryNotFound
+			}
+			if albumId != 0 && g.Bucket(albumsBucket).Bucket(itob(albumId)) == nil {
+				return ErrAlbumNotFound
+			}
+		}
+
+		found := false
+		err := tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+			job, err := readJob(v)
+			if err != nil {
+				return err
+			}
+			if job.Kind == JobRegenerateImages && job.GalleryId == galleryId && job.AlbumId == albumId && job.Status != JobFailed {
+				j, found = job, true
+			}
+			return nil
+		})
+		if err != nil || found {
+			return err
+		}
+
+		refs, err := scopeImages(tx, galleryId, albumId)
+		if err != nil {
+			return err
+		}
+
+		j, err = addJob(tx, Job{Kind: JobRegenerateImages, GalleryId: galleryId, AlbumId: albumId, Progress: &Progress{Total: len(refs)}})
+		queued = true
+		return err
+	})
+
+	if err == nil && queued {
+		d.notifyJobs()
+	}
+	return j, err
+}
+
+// regenerateImages renders images in scope of job, continuing after its cursor.
+// Progress and cursor of job are stored with each rendition, so that job can resume after restart.
+// Images uploaded before originals were stored keep their display image, and get other renditions from it.
+func (d *Database) regenerateImages(j Job) error {
+	var refs []imagePath
+	err := d.db.View(func(tx *bolt.Tx) error {
+		var err error
+		refs, err = scopeImages(tx, j.GalleryId, j.AlbumId)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if j.Progress == nil {
+		j.Progress = &Progress{}
+	}
+	j.Progress.Total = len(refs)
+
+	for _, ref := range refs {
+		if !ref.after(j.Cursor) {
+			continue
+		}
+
+		var source []byte
+		var edits []Edit
+		var legacy, tiled bool
+		var s watermarkState
+		err := d.db.View(func(tx *bolt.Tx) error {
+			g, i, err := imageBuckets(tx, ref[0], ref[1], ref[2])
+			if err == ErrGalleryNotFound || err == ErrAlbumNotFound || err == ErrImageNotFound {
+				// deleted meanwhile
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			// pending images are rendered by their own job
+			if i.Get(statusKey) != nil {
+				return nil
+			}
+
+			s, err = readWatermarkState(g)
+			if err != nil {
+				return err
+			}
+
+			data := imageData(i)
+			source = readOriginal(data)
+			if source == nil {
+				source, legacy = data.Get(imageKey), true
+			}
+			source = append([]byte(nil), source...)
+			tiled = data.Get(deepZoomKey) != nil
+			edits, err = readEdits(data)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		var r rendition
+		var renderErr error
+		if len(source) > 0 {
+			r, renderErr = d.render(source, edits, tiled, s)
+			if legacy {
+				r.image = source
+			}
+		}
+
+		updated := false
+		err = d.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(jobsBucket)
+			if b.Get(itob(j.Id)) == nil {
+				return ErrJobNotFound
+			}
+
+			switch {
+			case len(source) == 0:
+				j.Progress.Skipped++
+			case renderErr != nil:
+				j.Progress.Failed++
+			default:
+				_, i, err := imageBuckets(tx, ref[0], ref[1], ref[2])
+				if err == ErrGalleryNotFound || err == ErrAlbumNotFound || err == ErrImageNotFound {
+					j.Progress.Skipped++
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if err := r.put(tx, documentRef(ref[0], ref[1], ref[2]), i); err != nil {
+					return err
+				}
+				j.Progress.Processed++
+				updated = true
+			}
+
+			j.Cursor = []uint64{ref[0], ref[1], ref[2]}
+			j.UpdatedAt = time.Now().UTC()
+			return putJob(b, j)
+		})
+		if updated {
+			d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: ref[0], AlbumId: ref[1], ImageId: ref[2]})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
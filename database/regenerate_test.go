@@ -0,0 +1,166 @@
+package database
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func runJobs(db *Database) {
+	for {
+		j, ok, _ := db.ClaimJob()
+		if !ok {
+			return
+		}
+		_ = db.CompleteJob(j.Id, db.RunJob(j))
+	}
+}
+
+func TestImagePath_After(t *testing.T) {
+	testCases := []struct {
+		path   imagePath
+		cursor []uint64
+		after  bool
+	}{
+		{imagePath{1, 1, 1}, nil, true},
+		{imagePath{1, 1, 1}, []uint64{1, 1, 1}, false},
+		{imagePath{1, 1, 2}, []uint64{1, 1, 1}, true},
+		{imagePath{1, 2, 1}, []uint64{1, 1, 3}, true},
+		{imagePath{1, 1, 3}, []uint64{1, 2, 1}, false},
+		{imagePath{2, 1, 1}, []uint64{1, 9, 9}, true},
+	}
+
+	for _, tc := range testCases {
+		if a := tc.path.after(tc.cursor); a != tc.after {
+			t.Errorf("%v after %v: %t != %t", tc.path, tc.cursor, a, tc.after)
+		}
+	}
+}
+
+func TestDatabase_RegenerateImages(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	other, _ := db.CreateAlbum(gid, "other", "test-user")
+
+	var ids []uint64
+	for n, a := range []uint64{aid, aid, other} {
+		img := createGradientImage(1280, 960+n, false)
+		iid, err := db.AddImage(gid, a, "test-user", &img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, iid)
+	}
+	before, _, _ := db.GetThumbnail(gid, aid, ids[0])
+	outside, _, _ := db.GetThumbnail(gid, other, ids[2])
+
+	if _, err := db.RegenerateImages(gid+1, 0); err != ErrGalleryNotFound {
+		t.Errorf("%v != %v", err, ErrGalleryNotFound)
+	}
+	if _, err := db.RegenerateImages(gid, other+1); err != ErrAlbumNotFound {
+		t.Errorf("%v != %v", err, ErrAlbumNotFound)
+	}
+
+	j, err := db.RegenerateImages(gid, aid)
+	if err != nil || j.Kind != JobRegenerateImages || j.Progress == nil || j.Progress.Total != 2 {
+		t.Fatalf("Assertion Failed: %+v %v", j, err)
+	}
+	if again, _ := db.RegenerateImages(gid, aid); again.Id != j.Id {
+		t.Errorf("%d != %d", again.Id, j.Id)
+	}
+
+	db.cfg.Quality = 20
+	runJobs(db)
+
+	after, _, _ := db.GetThumbnail(gid, aid, ids[0])
+	if bytes.Equal(before, after) {
+		t.Error("thumbnail not regenerated")
+	}
+	if t2, _, _ := db.GetThumbnail(gid, other, ids[2]); !bytes.Equal(outside, t2) {
+		t.Error("thumbnail out of scope regenerated")
+	}
+	if jobs, _ := db.GetJobs(""); len(jobs) != 0 {
+		t.Errorf("Assertion Failed: %+v", jobs)
+	}
+}
+
+func TestDatabase_RegenerateImagesResume(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+
+	var ids []uint64
+	var thumbs [][]byte
+	for n := 0; n < 3; n++ {
+		img := createGradientImage(1280, 960+n, false)
+		iid, _ := db.AddImage(gid, aid, "test-user", &img)
+		ids = append(ids, iid)
+		thumb, _, _ := db.GetThumbnail(gid, aid, iid)
+		thumbs = append(thumbs, thumb)
+	}
+
+	// image uploaded before originals were stored holds its renditions itself
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, gid, aid, ids[2])
+		if err != nil {
+			return err
+		}
+		data := imageData(i)
+		for _, k := range [][]byte{imageKey, thumbnailKey} {
+			if err := i.Put(k, append([]byte{}, data.Get(k)...)); err != nil {
+				return err
+			}
+		}
+		if err := releaseBlob(i); err != nil {
+			return err
+		}
+		return i.Delete(blobKey)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, _, _ := db.GetImage(gid, aid, ids[2])
+
+	_, _ = db.RegenerateImages(0, 0)
+	j, _, _ := db.ClaimJob()
+
+	// previous process stopped after the first image
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		j.Cursor = []uint64{gid, aid, ids[0]}
+		j.Progress.Processed = 1
+		return putJob(tx.Bucket(jobsBucket), j)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RequeueRunningJobs(); err != nil {
+		t.Fatal(err)
+	}
+
+	db.cfg.Quality = 20
+	j, _, _ = db.ClaimJob()
+	if err := db.RunJob(j); err != nil {
+		t.Fatal(err)
+	}
+
+	j, _ = db.GetJob(j.Id)
+	if p := j.Progress; p.Total != 3 || p.Processed != 3 || p.Failed != 0 || p.Skipped != 0 {
+		t.Errorf("Assertion Failed: %+v", p)
+	}
+
+	if t0, _, _ := db.GetThumbnail(gid, aid, ids[0]); !bytes.Equal(t0, thumbs[0]) {
+		t.Error("thumbnail regenerated before cursor")
+	}
+	if t1, _, _ := db.GetThumbnail(gid, aid, ids[1]); bytes.Equal(t1, thumbs[1]) {
+		t.Error("thumbnail not regenerated after cursor")
+	}
+
+	if i, _, _ := db.GetImage(gid, aid, ids[2]); !bytes.Equal(i, legacy) {
+		t.Error("display image of legacy image changed")
+	}
+	if t2, _, _ := db.GetThumbnail(gid, aid, ids[2]); bytes.Equal(t2, thumbs[2]) {
+		t.Error("thumbnail of legacy image not regenerated")
+	}
+}
@@ -0,0 +1,329 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	searchBucket    = []byte("search")
+	postingsBucket  = []byte("postings")
+	documentsBucket = []byte("documents")
+)
+
+const (
+	SearchTypeGallery = "gallery"
+	SearchTypeAlbum   = "album"
+	SearchTypeImage   = "image"
+)
+
+// prefixWeight is score multiplier of tokens matched by prefix only.
+const prefixWeight = 0.5
+
+// SearchResult is gallery, album or image matching search query.
+// Text is title of gallery and album, or description of image.
+type SearchResult struct {
+	Type      string   `json:"type"`
+	GalleryId uint64   `json:"galleryId"`
+	AlbumId   uint64   `json:"albumId,omitempty"`
+	ImageId   uint64   `json:"imageId,omitempty"`
+	Text      string   `json:"text"`
+	Tags      []string `json:"tags,omitempty"`
+	Score     float64  `json:"score"`
+}
+
+// documentRef is search index key of gallery, album or image.
+// Zero albumId and imageId refers to gallery, zero imageId refers to album.
+func documentRef(galleryId, albumId, imageId uint64) []byte {
+	return append(append(itob(galleryId), itob(albumId)...), itob(imageId)...)
+}
+
+func isHangul(r rune) bool {
+	return unicode.Is(unicode.Hangul, r)
+}
+
+// Tokenize splits text into lowercase search tokens.
+// Korean words are split into character bigrams, since particles are
+// attached to nouns without spaces.
+func Tokenize(text string) []string {
+	result := make([]string, 0)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	for _, w := range words {
+		runes := []rune(w)
+		hangul := false
+		for _, r := range runes {
+			if isHangul(r) {
+				hangul = true
+				break
+			}
+		}
+
+		if !hangul || len(runes) < 3 {
+			result = append(result, w)
+			continue
+		}
+
+		for i := 0; i+1 < len(runes); i++ {
+			result = append(result, string(runes[i:i+2]))
+		}
+	}
+
+	return result
+}
+
+func imageText(i *bolt.Bucket) string {
+	return string(i.Get(descriptionKey)) + " " + strings.Join(readTags(i), " ")
+}
+
+// indexDocument replaces indexed tokens of document with tokens of text.
+func indexDocument(tx *bolt.Tx, ref []byte, text string) error {
+	err := unindexDocuments(tx, ref)
+	if err != nil {
+		return err
+	}
+
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	s := tx.Bucket(searchBucket)
+	postings := s.Bucket(postingsBucket)
+
+	counts := make(map[string]uint64)
+	for _, t := range tokens {
+		counts[t]++
+	}
+
+	for t, c := range counts {
+		p, err := postings.CreateBucketIfNotExists([]byte(t))
+		if err != nil {
+			return err
+		}
+		err = p.Put(ref, itob(c))
+		if err != nil {
+			return err
+		}
+	}
+
+	v, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	return s.Bucket(documentsBucket).Put(ref, v)
+}
+
+// unindexDocuments removes every document whose ref starts with prefix.
+func unindexDocuments(tx *bolt.Tx, prefix []byte) error {
+	s := tx.Bucket(searchBucket)
+	postings := s.Bucket(postingsBucket)
+	docs := s.Bucket(documentsBucket)
+
+	var refs [][]byte
+	c := docs.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var tokens []string
+		if err := json.Unmarshal(v, &tokens); err != nil {
+			return err
+		}
+
+		for _, t := range tokens {
+			p := postings.Bucket([]byte(t))
+			if p == nil {
+				continue
+			}
+			if err := p.Delete(k); err != nil {
+				return err
+			}
+			if f, _ := p.Cursor().First(); f == nil {
+				if err := postings.DeleteBucket([]byte(t)); err != nil {
+					return err
+				}
+			}
+		}
+
+		refs = append(refs, append([]byte{}, k...))
+	}
+
+	for _, r := range refs {
+		if err := docs.Delete(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildSearchIndex indexes every gallery, album and image from scratch.
+func rebuildSearchIndex(tx *bolt.Tx) error {
+	if tx.Bucket(searchBucket) != nil {
+		if err := tx.DeleteBucket(searchBucket); err != nil {
+			return err
+		}
+	}
+
+	s, err := tx.CreateBucket(searchBucket)
+	if err != nil {
+		return err
+	}
+	if _, err = s.CreateBucket(postingsBucket); err != nil {
+		return err
+	}
+	if _, err = s.CreateBucket(documentsBucket); err != nil {
+		return err
+	}
+
+	return tx.Bucket(galleryBucket).ForEach(func(gk, _ []byte) error {
+		g := tx.Bucket(galleryBucket).Bucket(gk)
+		gid := btoi(gk)
+		if err := indexDocument(tx, documentRef(gid, 0, 0), string(g.Get(titleKey))); err != nil {
+			return err
+		}
+
+		albums := g.Bucket(albumsBucket)
+		return albums.ForEach(func(ak, _ []byte) error {
+			a := albums.Bucket(ak)
+			aid := btoi(ak)
+			if err := indexDocument(tx, documentRef(gid, aid, 0), string(a.Get(titleKey))); err != nil {
+				return err
+			}
+
+			images := a.Bucket(imagesBucket)
+			return images.ForEach(func(ik, _ []byte) error {
+				return indexDocument(tx, documentRef(gid, aid, btoi(ik)), imageText(images.Bucket(ik)))
+			})
+		})
+	})
+}
+
+// RebuildSearchIndex discards search index and indexes every content again.
+func (d *Database) RebuildSearchIndex() error {
+	return d.db.Update(rebuildSearchIndex)
+}
+
+// SearchScope selects unlisted galleries and albums search returns, along with their content.
+// Zero value returns listed ones only.
+type SearchScope struct {
+	// UserId sees unlisted content of galleries user has any role on.
+	UserId string
+	// Unlisted sees every unlisted content.
+	Unlisted bool
+}
+
+// includes reports whether unlisted content of gallery bucket g is in scope
+func (s SearchScope) includes(g *bolt.Bucket) bool {
+	return s.Unlisted || granted(g, s.UserId)
+}
+
+// Search returns galleries, albums and images matching query and visible to scope, highest score first.
+// Score is sum of tf-idf of query tokens; tokens matched by prefix only
+// are weighted by prefixWeight.
+func (d *Database) Search(query string, limit int, scope SearchScope) ([]SearchResult, error) {
+	result := make([]SearchResult, 0)
+
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return result, nil
+	}
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		s := tx.Bucket(searchBucket)
+		postings := s.Bucket(postingsBucket)
+		total := float64(s.Bucket(documentsBucket).Stats().KeyN)
+
+		scores := make(map[string]float64)
+		for _, t := range tokens {
+			c := postings.Cursor()
+			for k, _ := c.Seek([]byte(t)); k != nil && bytes.HasPrefix(k, []byte(t)); k, _ = c.Next() {
+				weight := 1.0
+				if string(k) != t {
+					weight = prefixWeight
+				}
+
+				p := postings.Bucket(k)
+				df := float64(p.Stats().KeyN)
+				idf := math.Log(1 + total/df)
+
+				err := p.ForEach(func(ref, tf []byte) error {
+					scores[string(ref)] += weight * float64(btoi(tf)) * idf
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		refs := make([]string, 0, len(scores))
+		for r := range scores {
+			refs = append(refs, r)
+		}
+		sort.Slice(refs, func(i, j int) bool {
+			if scores[refs[i]] != scores[refs[j]] {
+				return scores[refs[i]] > scores[refs[j]]
+			}
+			return refs[i] < refs[j]
+		})
+
+		for _, r := range refs {
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+			if sr, ok := resolveDocument(tx, []byte(r), scope); ok {
+				sr.Score = scores[r]
+				result = append(result, sr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func resolveDocument(tx *bolt.Tx, ref []byte, scope SearchScope) (SearchResult, bool) {
+	r := SearchResult{GalleryId: btoi(ref[:8]), AlbumId: btoi(ref[8:16]), ImageId: btoi(ref[16:])}
+
+	g := tx.Bucket(galleryBucket).Bucket(ref[:8])
+	if g == nil {
+		return r, false
+	}
+	page := Page{Unlisted: scope.includes(g)}
+	if !listed(g, page) {
+		return r, false
+	}
+	if r.AlbumId == 0 {
+		r.Type = SearchTypeGallery
+		r.Text = string(g.Get(titleKey))
+		return r, true
+	}
+
+	a := g.Bucket(albumsBucket).Bucket(ref[8:16])
+	if a == nil || !listed(a, page) {
+		return r, false
+	}
+	if r.ImageId == 0 {
+		r.Type = SearchTypeAlbum
+		r.Text = string(a.Get(titleKey))
+		return r, true
+	}
+
+	i := a.Bucket(imagesBucket).Bucket(ref[16:])
+	if i == nil {
+		return r, false
+	}
+	r.Type = SearchTypeImage
+	r.Text = string(i.Get(descriptionKey))
+	r.Tags = readTags(i)
+	return r, true
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	for idx, v := range []struct {
+		text   string
+		result []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"서울에서 찍은 사진", []string{"서울", "울에", "에서", "찍은", "사진"}},
+		{"Seoul 야경 2020", []string{"seoul", "야경", "2020"}},
+		{"  ", []string{}},
+	} {
+		if result := Tokenize(v.text); !reflect.DeepEqual(result, v.result) {
+			t.Errorf("Test %d failed. expected: %v, result:%v", idx, v.result, result)
+		}
+	}
+}
+
+func searchTypes(r []SearchResult) []string {
+	result := make([]string, 0)
+	for _, i := range r {
+		result = append(result, i.Type)
+	}
+	return result
+}
+
+func TestDatabase_Search(t *testing.T) {
+	db := createTestDB()
+	gid, err := db.CreateGallery("Seoul trip", "test-user")
+	if err != nil {
+		t.Error(err)
+	}
+	aid, err := db.CreateAlbum(gid, "한강 야경", "test-user")
+	if err != nil {
+		t.Error(err)
+	}
+	img := createTestImage()
+	iid, err := db.AddImage(gid, aid, "test-user", &img)
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.SetImageDescription(gid, aid, iid, "서울에서 본 야경")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = db.AddImageTags(gid, aid, iid, []string{"night"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	for idx, v := range []struct {
+		query string
+		types []string
+	}{
+		{"seoul", []string{SearchTypeGallery}},
+		{"서울", []string{SearchTypeImage}},
+		{"야경", []string{SearchTypeAlbum, SearchTypeImage}},
+		{"nig", []string{SearchTypeImage}},
+		{"tokyo", []string{}},
+	} {
+		r, err := db.Search(v.query, 0, SearchScope{})
+		if err != nil {
+			t.Error(idx, err)
+			continue
+		}
+		if !reflect.DeepEqual(searchTypes(r), v.types) {
+			t.Errorf("Test %d failed. expected: %v, result:%+v", idx, v.types, r)
+		}
+	}
+
+	r, err := db.Search("서울", 0, SearchScope{})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(r) != 1 || r[0].GalleryId != gid || r[0].AlbumId != aid || r[0].ImageId != iid || r[0].Text != "서울에서 본 야경" {
+		t.Errorf("Assertion Failed: %+v", r)
+	}
+
+	err = db.SetGalleryTitle(gid, "Busan trip")
+	if err != nil {
+		t.Error(err)
+	}
+	if r, _ := db.Search("seoul", 0, SearchScope{}); len(r) != 0 {
+		t.Errorf("Assertion Failed: %+v", r)
+	}
+
+	err = db.DeleteAlbum(gid, aid)
+	if err != nil {
+		t.Error(err)
+	}
+	if r, _ := db.Search("야경", 0, SearchScope{}); len(r) != 0 {
+		t.Errorf("Assertion Failed: %+v", r)
+	}
+}
+
+func TestDatabase_SearchUnlisted(t *testing.T) {
+	db := createTestDB()
+	unlisted := VisibilityUnlisted
+
+	gid, _ := db.CreateGallery("hidden trip", "test-user")
+	if _, err := db.UpdateGallery(gid, GalleryPatch{Visibility: &unlisted}, nil); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := db.CreateGallery("public", "other-user")
+	aid, _ := db.CreateAlbum(other, "hidden album", "other-user")
+	if _, err := db.UpdateAlbum(other, aid, AlbumPatch{Visibility: &unlisted}, nil); err != nil {
+		t.Fatal(err)
+	}
+	img := createTestImage()
+	iid, _ := db.AddImage(other, aid, "other-user", &img)
+	if err := db.SetImageDescription(other, aid, iid, "hidden image"); err != nil {
+		t.Fatal(err)
+	}
+
+	for idx, v := range []struct {
+		scope SearchScope
+		count int
+	}{
+		{SearchScope{}, 0},
+		{SearchScope{UserId: "test-user"}, 1},
+		{SearchScope{UserId: "other-user"}, 2},
+		{SearchScope{Unlisted: true}, 3},
+	} {
+		r, err := db.Search("hidden", 0, v.scope)
+		if err != nil || len(r) != v.count {
+			t.Errorf("Test %d failed: %+v %v", idx, r, err)
+		}
+	}
+}
+
+func TestDatabase_RebuildSearchIndex(t *testing.T) {
+	db := createTestDB()
+	_, err := db.CreateGallery("Seoul trip", "test-user")
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.RebuildSearchIndex()
+	if err != nil {
+		t.Error(err)
+	}
+	r, err := db.Search("trip", 0, SearchScope{})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(searchTypes(r), []string{SearchTypeGallery}) {
+		t.Errorf("Assertion Failed: %+v", r)
+	}
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/boltdb/bolt"
+)
+
+// Size is pixel dimensions of image
+type Size struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Fit returns size scaled down to fit in maxWidth and maxHeight, keeping aspect ratio.
+// Zero bound is unbounded.
+func (s Size) Fit(maxWidth, maxHeight int) Size {
+	if maxWidth > 0 && s.Width > maxWidth {
+		s = Size{maxWidth, s.Height * maxWidth / s.Width}
+	}
+	if maxHeight > 0 && s.Height > maxHeight {
+		s = Size{s.Width * maxHeight / s.Height, maxHeight}
+	}
+	return s
+}
+
+// Fits reports whether size is within maxWidth and maxHeight. Zero bound is unbounded.
+func (s Size) Fits(maxWidth, maxHeight int) bool {
+	return (maxWidth <= 0 || s.Width <= maxWidth) && (maxHeight <= 0 || s.Height <= maxHeight)
+}
+
+// decodeSize reads size from header of encoded image
+func decodeSize(b []byte) Size {
+	c, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return Size{}
+	}
+	return Size{c.Width, c.Height}
+}
+
+// GetImageSizes returns size of image and of its thumbnail
+func (d *Database) GetImageSizes(galleryId, albumId, imageId uint64) (Size, Size, error) {
+	var img, thumb Size
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+
+		data := imageData(i)
+		if data.Get(imageKey) == nil {
+			return ErrImageNotReady
+		}
+
+		img = decodeSize(data.Get(imageKey))
+		thumb = decodeSize(data.Get(thumbnailKey))
+		return nil
+	})
+
+	return img, thumb, err
+}
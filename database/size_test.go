@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestSize_Fit(t *testing.T) {
+	tests := []struct {
+		size      Size
+		maxWidth  int
+		maxHeight int
+		expected  Size
+	}{
+		{Size{800, 600}, 0, 0, Size{800, 600}},
+		{Size{800, 600}, 400, 0, Size{400, 300}},
+		{Size{800, 600}, 0, 300, Size{400, 300}},
+		{Size{800, 600}, 400, 100, Size{133, 100}},
+		{Size{800, 600}, 1000, 1000, Size{800, 600}},
+	}
+
+	for _, test := range tests {
+		if r := test.size.Fit(test.maxWidth, test.maxHeight); r != test.expected {
+			t.Errorf("Assertion Failed: %v.Fit(%d, %d) = %v != %v", test.size, test.maxWidth, test.maxHeight, r, test.expected)
+		}
+		if !test.expected.Fits(test.maxWidth, test.maxHeight) {
+			t.Errorf("Assertion Failed: %v not fits in %d, %d", test.expected, test.maxWidth, test.maxHeight)
+		}
+	}
+
+	db, gid := createTaggedTestDB()
+	img, thumb, err := db.GetImageSizes(gid, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Width == 0 || thumb.Width == 0 || !thumb.Fits(img.Width, img.Height) {
+		t.Errorf("Assertion Failed: %v %v", img, thumb)
+	}
+	if _, _, err := db.GetImageSizes(gid, 1, 9); err != ErrImageNotFound {
+		t.Errorf("%v != %v", err, ErrImageNotFound)
+	}
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	ErrSmartAlbum    = errors.New("album is smart album")
+	ErrNotSmartAlbum = errors.New("album is not smart album")
+	ErrInvalidQuery  = errors.New("invalid smart album query")
+)
+
+var queryKey = []byte("query")
+
+// SmartQuery selects images listed by smart album.
+// Every non-zero condition must match.
+type SmartQuery struct {
+	// Tags images must carry all of
+	Tags []string `json:"tags,omitempty"`
+	// Since and Until bound capture time, or upload time of images without EXIF data
+	Since *time.Time `json:"since,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+	// Camera is case-insensitive substring of camera make and model
+	Camera string `json:"camera,omitempty"`
+	// Galleries to search. Empty means gallery of the smart album.
+	Galleries []uint64 `json:"galleries,omitempty"`
+}
+
+func (q SmartQuery) normalize() (SmartQuery, error) {
+	tags := make([]string, 0, len(q.Tags))
+	for _, t := range q.Tags {
+		n, err := NormalizeTag(t)
+		if err != nil {
+			return q, err
+		}
+		tags = append(tags, n)
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	q.Tags = tags
+
+	if q.Since != nil && q.Until != nil && q.Since.After(*q.Until) {
+		return q, ErrInvalidQuery
+	}
+
+	q.Camera = strings.TrimSpace(q.Camera)
+	return q, nil
+}
+
+func (q SmartQuery) match(i *bolt.Bucket) bool {
+	if len(q.Tags) > 0 {
+		t := i.Bucket(tagsBucket)
+		if t == nil {
+			return false
+		}
+		for _, tag := range q.Tags {
+			if t.Get([]byte(tag)) == nil {
+				return false
+			}
+		}
+	}
+
+	if q.Since != nil || q.Until != nil {
+		c := imageTime(i)
+		if q.Since != nil && c.Before(*q.Since) {
+			return false
+		}
+		if q.Until != nil && c.After(*q.Until) {
+			return false
+		}
+	}
+
+	if q.Camera != "" && !strings.Contains(strings.ToLower(string(i.Get(cameraKey))), strings.ToLower(q.Camera)) {
+		return false
+	}
+
+	return true
+}
+
+// imageTime returns capture time of image, or upload time if unknown.
+func imageTime(i *bolt.Bucket) time.Time {
+	if c := i.Get(capturedKey); c != nil {
+		return time.Unix(0, int64(btoi(c)))
+	}
+	if t := i.Get(timestampKey); t != nil {
+		return time.Unix(0, int64(btoi(t)))
+	}
+	return time.Unix(1, 0)
+}
+
+func readSmartQuery(a *bolt.Bucket) (*SmartQuery, error) {
+	v := a.Get(queryKey)
+	if v == nil {
+		return nil, nil
+	}
+
+	var q SmartQuery
+	if err := json.Unmarshal(v, &q); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+func putSmartQuery(a *bolt.Bucket, q SmartQuery) error {
+	v, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	return a.Put(queryKey, v)
+}
+
+// resolveSmartQuery returns images matching query, oldest first.
+// Unlisted albums are searched only in gallery of the smart album, and only if unlisted is set.
+// Unlisted galleries other than the smart album's are not searched.
+func resolveSmartQuery(tx *bolt.Tx, galleryId uint64, q SmartQuery, unlisted bool) ([]Image, error) {
+	type match struct {
+		time  time.Time
+		image Image
+	}
+	matches := make([]match, 0)
+
+	scope := q.Galleries
+	if len(scope) == 0 {
+		scope = []uint64{galleryId}
+	}
+
+	for _, gid := range scope {
+		g := tx.Bucket(galleryBucket).Bucket(itob(gid))
+		if g == nil || (gid != galleryId && !listed(g, Page{})) {
+			continue
+		}
+		page := Page{Unlisted: unlisted && gid == galleryId}
+
+		albums := g.Bucket(albumsBucket)
+		add := func(albumId, imageId []byte) {
+			a := albums.Bucket(albumId)
+			if a == nil || a.Get(queryKey) != nil || !listed(a, page) {
+				return
+			}
+			i := a.Bucket(imagesBucket).Bucket(imageId)
+			if i == nil || !q.match(i) {
+				return
+			}
+
+			img := readImage(btoi(imageId), g, i)
+			img.GalleryId = gid
+			img.AlbumId = btoi(albumId)
+			matches = append(matches, match{time: imageTime(i), image: img})
+		}
+
+		// use reverse index of first tag instead of scanning every album
+		if len(q.Tags) > 0 {
+			index := g.Bucket(tagsBucket)
+			if index == nil {
+				continue
+			}
+			t := index.Bucket([]byte(q.Tags[0]))
+			if t == nil {
+				continue
+			}
+			c := t.Cursor()
+			for k, _ := c.First(); k != nil; k, _ = c.Next() {
+				add(k[:8], k[8:])
+			}
+			continue
+		}
+
+		err := albums.ForEach(func(ak, _ []byte) error {
+			return albums.Bucket(ak).Bucket(imagesBucket).ForEach(func(ik, _ []byte) error {
+				add(ak, ik)
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].time.Before(matches[j].time)
+	})
+
+	result := make([]Image, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, m.image)
+	}
+	return result, nil
+}
+
+// CreateSmartAlbum creates album listing images matching query
+func (d *Database) CreateSmartAlbum(galleryId uint64, title, owner string, query SmartQuery) (uint64, error) {
+	q, err := query.normalize()
+	if err != nil {
+		return 0, err
+	}
+	return d.createAlbum(galleryId, title, owner, &q)
+}
+
+// SetSmartQuery replaces query of smart album
+func (d *Database) SetSmartQuery(galleryId, albumId uint64, query SmartQuery) error {
+	q, err := query.normalize()
+	if err != nil {
+		return err
+	}
+
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+		a := g.Bucket(albumsBucket).Bucket(itob(albumId))
+		if a == nil {
+			return ErrAlbumNotFound
+		}
+		if a.Get(queryKey) == nil {
+			return ErrNotSmartAlbum
+		}
+		return putSmartQuery(a, q)
+	})
+
+	d.publish(err, Event{Entity: EntityAlbum, Action: ActionUpdate, GalleryId: galleryId, AlbumId: albumId})
+	return err
+}
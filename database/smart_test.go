@@ -0,0 +1,86 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDatabase_CreateSmartAlbum(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	_, err := db.AddImageTags(gid, 2, 1, []string{"sunset"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	aid, err := db.CreateSmartAlbum(gid, "smart-album", "test-user", SmartQuery{Tags: []string{"Sunset"}})
+	if err != nil {
+		t.Error(err)
+	}
+
+	a, err := db.GetAlbum(gid, aid)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(a, Album{Id: aid, Title: "smart-album", Owner: "test-user", Smart: true, Query: &SmartQuery{Tags: []string{"sunset"}}}) {
+		t.Errorf("Assertion Failed: %+v", a)
+	}
+
+	i, err := db.GetImages(gid, aid)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(i, []Image{processedTestImage(Image{Id: 1, GalleryId: gid, AlbumId: 2, Owner: "test-user", Tags: []string{"sunset"}})}) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	img := createTestImage()
+	if _, err := db.AddImage(gid, aid, "test-user", &img); err != ErrSmartAlbum {
+		t.Errorf("%v != %v", err, ErrSmartAlbum)
+	}
+}
+
+func TestDatabase_SetSmartQuery(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	past := time.Now().Add(-time.Hour)
+
+	aid, err := db.CreateSmartAlbum(gid, "smart-album", "test-user", SmartQuery{Until: &past})
+	if err != nil {
+		t.Error(err)
+	}
+	i, err := db.GetImages(gid, aid)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(i) != 0 {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	err = db.SetSmartQuery(gid, aid, SmartQuery{Since: &past})
+	if err != nil {
+		t.Error(err)
+	}
+	i, err = db.GetImages(gid, aid)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(i) != 2 || i[0].AlbumId != 1 || i[1].AlbumId != 2 {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	future := time.Now().Add(time.Hour)
+	if err := db.SetSmartQuery(gid, aid, SmartQuery{Since: &future, Until: &past}); err != ErrInvalidQuery {
+		t.Errorf("%v != %v", err, ErrInvalidQuery)
+	}
+	if err := db.SetSmartQuery(gid, 1, SmartQuery{}); err != ErrNotSmartAlbum {
+		t.Errorf("%v != %v", err, ErrNotSmartAlbum)
+	}
+}
+
+func TestReadExif(t *testing.T) {
+	img := createTestImage()
+	captured, camera := readExif(img.Bytes())
+	if !captured.IsZero() || camera != "" {
+		t.Errorf("Assertion Failed: %v %s", captured, camera)
+	}
+}
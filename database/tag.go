@@ -0,0 +1,303 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+// tagsBucket holds tag set in image bucket,
+// and reverse index from tag to images in gallery bucket.
+var tagsBucket = []byte("tags")
+
+const maxTagLength = 64
+
+// TagCount is number of images carrying tag in a gallery.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag trims and lowercases tag.
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || strings.ContainsAny(tag, "/?#") {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// imageRef is reverse index key of image in gallery.
+func imageRef(albumId, imageId uint64) []byte {
+	return append(itob(albumId), itob(imageId)...)
+}
+
+func readTags(i *bolt.Bucket) []string {
+	t := i.Bucket(tagsBucket)
+	if t == nil {
+		return nil
+	}
+
+	var result []string
+	c := t.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		result = append(result, string(k))
+	}
+	return result
+}
+
+// untagImage removes image from reverse index of gallery bucket g.
+func untagImage(g *bolt.Bucket, albumId, imageId uint64, i *bolt.Bucket) error {
+	index := g.Bucket(tagsBucket)
+	if index == nil {
+		return nil
+	}
+
+	for _, tag := range readTags(i) {
+		if err := removeFromIndex(index, tag, albumId, imageId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeFromIndex(index *bolt.Bucket, tag string, albumId, imageId uint64) error {
+	t := index.Bucket([]byte(tag))
+	if t == nil {
+		return nil
+	}
+	if err := t.Delete(imageRef(albumId, imageId)); err != nil {
+		return err
+	}
+	if k, _ := t.Cursor().First(); k == nil {
+		return index.DeleteBucket([]byte(tag))
+	}
+	return nil
+}
+
+// imageBuckets returns gallery and image buckets
+func imageBuckets(tx *bolt.Tx, galleryId, albumId, imageId uint64) (*bolt.Bucket, *bolt.Bucket, error) {
+	g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+	if g == nil {
+		return nil, nil, ErrGalleryNotFound
+	}
+	a := g.Bucket(albumsBucket).Bucket(itob(albumId))
+	if a == nil {
+		return nil, nil, ErrAlbumNotFound
+	}
+	i := a.Bucket(imagesBucket).Bucket(itob(imageId))
+	if i == nil {
+		return nil, nil, ErrImageNotFound
+	}
+	return g, i, nil
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		t, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, t)
+	}
+	return normalized, nil
+}
+
+// AddImageTags adds tags to image and returns resulting tag set.
+func (d *Database) AddImageTags(galleryId, albumId, imageId uint64, tags []string) ([]string, error) {
+	var result []string
+
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		result, err = addImageTags(tx, galleryId, albumId, imageId, normalized)
+		return err
+	})
+
+	d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: galleryId, AlbumId: albumId, ImageId: imageId})
+	return result, err
+}
+
+// addImageTags adds normalized tags to image and returns resulting tag set.
+func addImageTags(tx *bolt.Tx, galleryId, albumId, imageId uint64, tags []string) ([]string, error) {
+	g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := i.CreateBucketIfNotExists(tagsBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := g.CreateBucketIfNotExists(tagsBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		err = t.Put([]byte(tag), []byte{})
+		if err != nil {
+			return nil, err
+		}
+
+		ti, err := index.CreateBucketIfNotExists([]byte(tag))
+		if err != nil {
+			return nil, err
+		}
+
+		err = ti.Put(imageRef(albumId, imageId), []byte{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return readTags(i), indexDocument(tx, documentRef(galleryId, albumId, imageId), imageText(i))
+}
+
+// RemoveImageTag removes tag from image.
+func (d *Database) RemoveImageTag(galleryId, albumId, imageId uint64, tag string) error {
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		return removeImageTag(tx, galleryId, albumId, imageId, tag)
+	})
+
+	d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: galleryId, AlbumId: albumId, ImageId: imageId})
+	return err
+}
+
+// removeImageTag removes normalized tag from image.
+func removeImageTag(tx *bolt.Tx, galleryId, albumId, imageId uint64, tag string) error {
+	g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+	if err != nil {
+		return err
+	}
+
+	t := i.Bucket(tagsBucket)
+	if t == nil || t.Get([]byte(tag)) == nil {
+		return ErrTagNotFound
+	}
+
+	err = t.Delete([]byte(tag))
+	if err != nil {
+		return err
+	}
+
+	if index := g.Bucket(tagsBucket); index != nil {
+		err = removeFromIndex(index, tag, albumId, imageId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return indexDocument(tx, documentRef(galleryId, albumId, imageId), imageText(i))
+}
+
+// GetTags returns every tag used in gallery with number of images.
+// Images of unlisted albums are counted only if unlisted is set, and tags of no counted image are omitted.
+func (d *Database) GetTags(galleryId uint64, unlisted bool) ([]TagCount, error) {
+	result := make([]TagCount, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+
+		index := g.Bucket(tagsBucket)
+		if index == nil {
+			return nil
+		}
+
+		albums := g.Bucket(albumsBucket)
+		page := Page{Unlisted: unlisted}
+		c := index.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			count := 0
+			ic := index.Bucket(k).Cursor()
+			for ik, _ := ic.First(); ik != nil; ik, _ = ic.Next() {
+				if a := albums.Bucket(ik[:8]); a != nil && listed(a, page) {
+					count++
+				}
+			}
+			if count > 0 {
+				result = append(result, TagCount{Tag: string(k), Count: count})
+			}
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// GetImagesByTag returns every image carrying tag in gallery, across albums, ordered by order, then album and id.
+// Images of unlisted albums are returned only if unlisted is set.
+func (d *Database) GetImagesByTag(galleryId uint64, tag string, unlisted bool) ([]Image, error) {
+	result := make([]Image, 0)
+
+	tag, err := NormalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.db.View(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+
+		index := g.Bucket(tagsBucket)
+		if index == nil {
+			return nil
+		}
+		t := index.Bucket([]byte(tag))
+		if t == nil {
+			return nil
+		}
+
+		albums := g.Bucket(albumsBucket)
+		page := Page{Unlisted: unlisted}
+		c := t.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			albumId, imageId := btoi(k[:8]), btoi(k[8:])
+			a := albums.Bucket(k[:8])
+			if a == nil || !listed(a, page) {
+				continue
+			}
+			i := a.Bucket(imagesBucket).Bucket(k[8:])
+			if i == nil {
+				continue
+			}
+			img := readImage(imageId, g, i)
+			img.AlbumId = albumId
+			result = append(result, img)
+		}
+
+		// index lists images by album, then id
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].Order < result[j].Order
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
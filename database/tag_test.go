@@ -0,0 +1,158 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func createTaggedTestDB() (*Database, uint64) {
+	db := createTestDB()
+	gid, err := db.CreateGallery("test-gallery", "test-user")
+	if err != nil {
+		panic(err)
+	}
+	for _, title := range []string{"album-1", "album-2"} {
+		aid, err := db.CreateAlbum(gid, title, "test-user")
+		if err != nil {
+			panic(err)
+		}
+		img := createTestImage()
+		_, err = db.AddImage(gid, aid, "test-user", &img)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return db, gid
+}
+
+func TestNormalizeTag(t *testing.T) {
+	for idx, v := range []struct {
+		tag    string
+		result string
+		err    error
+	}{
+		{" Sunset ", "sunset", nil},
+		{"바다", "바다", nil},
+		{"", "", ErrInvalidTag},
+		{"a/b", "", ErrInvalidTag},
+	} {
+		if result, err := NormalizeTag(v.tag); result != v.result || err != v.err {
+			t.Errorf("Test %d failed. expected: %v %v, result:%v %v", idx, v.result, v.err, result, err)
+		}
+	}
+}
+
+func TestDatabase_AddImageTags(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	tags, err := db.AddImageTags(gid, 1, 1, []string{"Sunset", "sea"})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(tags, []string{"sea", "sunset"}) {
+		t.Errorf("Assertion Failed: %+v", tags)
+	}
+	i, err := db.GetImageInfo(gid, 1, 1)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(i.Tags, []string{"sea", "sunset"}) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+}
+
+func TestDatabase_GetTags(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	_, err := db.AddImageTags(gid, 1, 1, []string{"sunset", "sea"})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = db.AddImageTags(gid, 2, 1, []string{"sunset"})
+	if err != nil {
+		t.Error(err)
+	}
+	tags, err := db.GetTags(gid, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(tags, []TagCount{{Tag: "sea", Count: 1}, {Tag: "sunset", Count: 2}}) {
+		t.Errorf("Assertion Failed: %+v", tags)
+	}
+
+	// images of unlisted albums are counted only if unlisted is set
+	unlisted := VisibilityUnlisted
+	if _, err := db.UpdateAlbum(gid, 1, AlbumPatch{Visibility: &unlisted}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ := db.GetTags(gid, false); !reflect.DeepEqual(tags, []TagCount{{Tag: "sunset", Count: 1}}) {
+		t.Errorf("Assertion Failed: %+v", tags)
+	}
+	if tags, _ := db.GetTags(gid, true); !reflect.DeepEqual(tags, []TagCount{{Tag: "sea", Count: 1}, {Tag: "sunset", Count: 2}}) {
+		t.Errorf("Assertion Failed: %+v", tags)
+	}
+}
+
+func TestDatabase_GetImagesByTag(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	_, err := db.AddImageTags(gid, 1, 1, []string{"sunset"})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = db.AddImageTags(gid, 2, 1, []string{"sunset"})
+	if err != nil {
+		t.Error(err)
+	}
+	i, err := db.GetImagesByTag(gid, "SUNSET", false)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := []Image{
+		processedTestImage(Image{Id: 1, AlbumId: 1, Owner: "test-user", Tags: []string{"sunset"}}),
+		processedTestImage(Image{Id: 1, AlbumId: 2, Owner: "test-user", Tags: []string{"sunset"}}),
+	}
+	if !reflect.DeepEqual(i, expected) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+
+	// images of unlisted albums are returned only if unlisted is set
+	unlisted := VisibilityUnlisted
+	if _, err := db.UpdateAlbum(gid, 2, AlbumPatch{Visibility: &unlisted}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if i, _ := db.GetImagesByTag(gid, "sunset", false); !reflect.DeepEqual(i, expected[:1]) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+	if i, _ := db.GetImagesByTag(gid, "sunset", true); !reflect.DeepEqual(i, expected) {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+}
+
+func TestDatabase_RemoveImageTag(t *testing.T) {
+	db, gid := createTaggedTestDB()
+	_, err := db.AddImageTags(gid, 1, 1, []string{"sunset"})
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = db.AddImageTags(gid, 2, 1, []string{"sunset"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.RemoveImageTag(gid, 1, 1, "sunset")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := db.RemoveImageTag(gid, 1, 1, "sunset"); err != ErrTagNotFound {
+		t.Errorf("%v != %v", err, ErrTagNotFound)
+	}
+
+	err = db.DeleteAlbum(gid, 2)
+	if err != nil {
+		t.Error(err)
+	}
+	tags, err := db.GetTags(gid, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(tags, []TagCount{}) {
+		t.Errorf("Assertion Failed: %+v", tags)
+	}
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/nfnt/resize"
+)
+
+var (
+	ErrTilesNotFound = errors.New("tiles not found")
+	ErrTileNotFound  = errors.New("tile not found")
+)
+
+var (
+	deepZoomKey = []byte("deepzoom")
+	tilesBucket = []byte("tiles")
+)
+
+const (
+	defaultTileSize = 254
+	tileOverlap     = 1
+)
+
+// DeepZoom describes tile pyramid of image, in Deep Zoom (DZI) layout.
+// Level 0 is single pixel, and the last level is full size image.
+type DeepZoom struct {
+	TileSize int    `json:"tileSize"`
+	Overlap  int    `json:"overlap"`
+	Format   string `json:"format"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// MaxLevel returns level of full size image
+func (z DeepZoom) MaxLevel() int {
+	d := z.Width
+	if z.Height > d {
+		d = z.Height
+	}
+	return int(math.Ceil(math.Log2(float64(d))))
+}
+
+// LevelSize returns size of image at level
+func (z DeepZoom) LevelSize(level int) (int, int) {
+	scale := math.Pow(2, float64(z.MaxLevel()-level))
+	return int(math.Ceil(float64(z.Width) / scale)), int(math.Ceil(float64(z.Height) / scale))
+}
+
+// Tiles returns number of tile columns and rows at level
+func (z DeepZoom) Tiles(level int) (int, int) {
+	w, h := z.LevelSize(level)
+	return (w + z.TileSize - 1) / z.TileSize, (h + z.TileSize - 1) / z.TileSize
+}
+
+// TileRect returns region of tile at level, including overlap
+func (z DeepZoom) TileRect(level, col, row int) image.Rectangle {
+	w, h := z.LevelSize(level)
+	r := image.Rect(col*z.TileSize, row*z.TileSize, (col+1)*z.TileSize, (row+1)*z.TileSize)
+	return image.Rect(r.Min.X-z.Overlap, r.Min.Y-z.Overlap, r.Max.X+z.Overlap, r.Max.Y+z.Overlap).Intersect(image.Rect(0, 0, w, h))
+}
+
+type subImager interface {
+	SubImage(image.Rectangle) image.Image
+}
+
+func tileKey(level, col, row int) []byte {
+	k := make([]byte, 12)
+	binary.BigEndian.PutUint32(k, uint32(level))
+	binary.BigEndian.PutUint32(k[4:], uint32(col))
+	binary.BigEndian.PutUint32(k[8:], uint32(row))
+	return k
+}
+
+// generateTiles encodes tile pyramid of img. Tiles are keyed by tileKey.
+func generateTiles(img image.Image, tileSize int, interpolation resize.InterpolationFunction, quality int) (DeepZoom, map[string][]byte, error) {
+	if tileSize <= 0 {
+		tileSize = defaultTileSize
+	}
+
+	b := img.Bounds()
+	z := DeepZoom{TileSize: tileSize, Overlap: tileOverlap, Format: "jpg", Width: b.Dx(), Height: b.Dy()}
+	tiles := make(map[string][]byte)
+
+	// each level is scaled down from the level above
+	level := img
+	for l := z.MaxLevel(); l >= 0; l-- {
+		w, h := z.LevelSize(l)
+		if lb := level.Bounds(); lb.Dx() != w || lb.Dy() != h {
+			level = resize.Resize(uint(w), uint(h), level, interpolation)
+		}
+		sub, ok := level.(subImager)
+		if !ok {
+			rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+			draw.Draw(rgba, rgba.Bounds(), level, level.Bounds().Min, draw.Src)
+			level, sub = rgba, rgba
+		}
+
+		cols, rows := z.Tiles(l)
+		for col := 0; col < cols; col++ {
+			for row := 0; row < rows; row++ {
+				var buf bytes.Buffer
+				r := z.TileRect(l, col, row).Add(level.Bounds().Min)
+				if err := jpeg.Encode(&buf, sub.SubImage(r), &jpeg.Options{Quality: quality}); err != nil {
+					return z, nil, err
+				}
+				tiles[string(tileKey(l, col, row))] = buf.Bytes()
+			}
+		}
+	}
+
+	return z, tiles, nil
+}
+
+// putTiles replaces tile pyramid in image data bucket
+func putTiles(i *bolt.Bucket, z DeepZoom, tiles map[string][]byte) error {
+	if i.Bucket(tilesBucket) != nil {
+		if err := i.DeleteBucket(tilesBucket); err != nil {
+			return err
+		}
+	}
+	t, err := i.CreateBucket(tilesBucket)
+	if err != nil {
+		return err
+	}
+	for k, v := range tiles {
+		if err := t.Put([]byte(k), v); err != nil {
+			return err
+		}
+	}
+
+	v, err := json.Marshal(z)
+	if err != nil {
+		return err
+	}
+	return i.Put(deepZoomKey, v)
+}
+
+func readDeepZoom(i *bolt.Bucket) (DeepZoom, bool) {
+	var z DeepZoom
+	v := i.Get(deepZoomKey)
+	if v == nil || json.Unmarshal(v, &z) != nil {
+		return z, false
+	}
+	return z, true
+}
+
+// GenerateTiles renders image again from its original with tile pyramid, replacing existing one.
+// Images uploaded before originals were stored get tiles from their display image.
+func (d *Database) GenerateTiles(galleryId, albumId, imageId uint64) (DeepZoom, error) {
+	var source []byte
+	var edits []Edit
+	var legacy bool
+	var s watermarkState
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+
+		data := imageData(i)
+		if data.Get(imageKey) == nil {
+			return ErrImageNotReady
+		}
+		s, err = readWatermarkState(g)
+		if err != nil {
+			return err
+		}
+
+		source = readOriginal(data)
+		if source == nil {
+			source, legacy = data.Get(imageKey), true
+		}
+		source = append([]byte(nil), source...)
+		edits, err = readEdits(data)
+		return err
+	})
+	if err != nil {
+		return DeepZoom{}, err
+	}
+
+	r, err := d.render(source, edits, true, s)
+	if err != nil {
+		return DeepZoom{}, err
+	}
+	if legacy {
+		r.image = source
+	}
+
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+		return r.put(tx, documentRef(galleryId, albumId, imageId), i)
+	})
+	d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: galleryId, AlbumId: albumId, ImageId: imageId})
+
+	return r.deepZoom, err
+}
+
+// GetDeepZoom returns tile pyramid description of image
+func (d *Database) GetDeepZoom(galleryId, albumId, imageId uint64) (DeepZoom, error) {
+	var z DeepZoom
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+
+		data, err := servedData(g, i)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		if z, ok = readDeepZoom(data); !ok {
+			return ErrTilesNotFound
+		}
+		return nil
+	})
+
+	return z, err
+}
+
+// GetTile returns tile of image at level, column and row, and time renditions of image last changed
+func (d *Database) GetTile(galleryId, albumId, imageId uint64, level, col, row int) ([]byte, time.Time, error) {
+	var tile []byte
+	timestamp := time.Unix(1, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g, i, err := imageBuckets(tx, galleryId, albumId, imageId)
+		if err != nil {
+			return err
+		}
+
+		data, err := servedData(g, i)
+		if err != nil {
+			return err
+		}
+
+		t := data.Bucket(tilesBucket)
+		if t == nil {
+			return ErrTilesNotFound
+		}
+		if level < 0 || col < 0 || row < 0 {
+			return ErrTileNotFound
+		}
+		v := t.Get(tileKey(level, col, row))
+		if v == nil {
+			return ErrTileNotFound
+		}
+		tile = append([]byte{}, v...)
+
+		timestamp = renderedTime(i)
+		return nil
+	})
+
+	return tile, timestamp, err
+}
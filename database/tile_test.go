@@ -0,0 +1,100 @@
+package database
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/nfnt/resize"
+)
+
+func TestDeepZoom_Levels(t *testing.T) {
+	z := DeepZoom{TileSize: 254, Overlap: 1, Width: 1280, Height: 600}
+	if z.MaxLevel() != 11 {
+		t.Errorf("Assertion Failed: %d != %d", z.MaxLevel(), 11)
+	}
+
+	tests := []struct {
+		level, width, height, cols, rows int
+	}{
+		{11, 1280, 600, 6, 3},
+		{10, 640, 300, 3, 2},
+		{1, 2, 1, 1, 1},
+		{0, 1, 1, 1, 1},
+	}
+	for _, test := range tests {
+		w, h := z.LevelSize(test.level)
+		cols, rows := z.Tiles(test.level)
+		if w != test.width || h != test.height || cols != test.cols || rows != test.rows {
+			t.Errorf("Assertion Failed: level %d: %d %d %d %d", test.level, w, h, cols, rows)
+		}
+	}
+
+	if r := z.TileRect(11, 0, 0); r != image.Rect(0, 0, 255, 255) {
+		t.Errorf("Assertion Failed: %v", r)
+	}
+	if r := z.TileRect(11, 5, 2); r != image.Rect(1269, 507, 1280, 600) {
+		t.Errorf("Assertion Failed: %v", r)
+	}
+}
+
+func TestDatabase_Tiles(t *testing.T) {
+	db, err := New(createTestBolt(), &config.Config{Interpolation: resize.Lanczos3, Quality: 80, DeepZoomThreshold: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+
+	img := createTestImage()
+	iid, err := db.AddImage(gid, aid, "test-user", &img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, _ := db.GetImageInfo(gid, aid, iid)
+	if !i.Tiled {
+		t.Error("image not tiled")
+	}
+
+	z, err := db.GetDeepZoom(gid, aid, iid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if z != (DeepZoom{TileSize: defaultTileSize, Overlap: 1, Format: "jpg", Width: 1280, Height: 1280}) {
+		t.Errorf("Assertion Failed: %+v", z)
+	}
+
+	tile, _, err := db.GetTile(gid, aid, iid, 11, 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := jpeg.DecodeConfig(bytes.NewReader(tile))
+	if err != nil || c.Width != 11 || c.Height != 11 {
+		t.Errorf("Assertion Failed: %+v %v", c, err)
+	}
+	if _, _, err := db.GetTile(gid, aid, iid, 11, 6, 0); err != ErrTileNotFound {
+		t.Errorf("%v != %v", err, ErrTileNotFound)
+	}
+
+	// small image is not tiled until requested
+	small := new(bytes.Buffer)
+	_ = jpeg.Encode(small, image.NewRGBA(image.Rect(0, 0, 300, 200)), nil)
+	sid, _ := db.AddImage(gid, aid, "test-user", small)
+	if _, err := db.GetDeepZoom(gid, aid, sid); err != ErrTilesNotFound {
+		t.Errorf("%v != %v", err, ErrTilesNotFound)
+	}
+	if _, _, err := db.GetTile(gid, aid, sid, 0, 0, 0); err != ErrTilesNotFound {
+		t.Errorf("%v != %v", err, ErrTilesNotFound)
+	}
+
+	z, err = db.GenerateTiles(gid, aid, sid)
+	if err != nil || z.MaxLevel() != 9 {
+		t.Fatalf("Assertion Failed: %+v %v", z, err)
+	}
+	if _, _, err := db.GetTile(gid, aid, sid, 9, 1, 0); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,488 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/nfnt/resize"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var (
+	ErrWatermarkNotFound = errors.New("watermark not found")
+	ErrInvalidWatermark  = errors.New("invalid watermark")
+)
+
+var (
+	watermarkKey        = []byte("watermark")
+	watermarkVersionKey = []byte("watermarkVersion")
+	watermarkedBucket   = []byte("watermarked")
+)
+
+// JobWatermarkImages applies current watermark of gallery to images watermarked with former one
+const JobWatermarkImages = "images.watermark"
+
+// Position of watermark on image
+type Position string
+
+const (
+	PositionTopLeft     Position = "top-left"
+	PositionTopRight    Position = "top-right"
+	PositionBottomLeft  Position = "bottom-left"
+	PositionBottomRight Position = "bottom-right"
+	PositionCenter      Position = "center"
+)
+
+// Valid reports whether p is one of positions
+func (p Position) Valid() bool {
+	switch p {
+	case PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight, PositionCenter:
+		return true
+	}
+	return false
+}
+
+// Watermark is drawn on display images, thumbnails and tiles of gallery, either PNG Logo or Text.
+// Scale is width of watermark relative to image, and Opacity is from 0 to 1.
+// Originals are never watermarked.
+type Watermark struct {
+	Text     string   `json:"text,omitempty"`
+	Logo     []byte   `json:"logo,omitempty"`
+	Position Position `json:"position"`
+	Opacity  float64  `json:"opacity"`
+	Scale    float64  `json:"scale"`
+}
+
+// mark returns image drawn as watermark, before scaling
+func (w Watermark) mark() (image.Image, error) {
+	if w.Logo != nil {
+		return png.Decode(bytes.NewReader(w.Logo))
+	}
+
+	face := basicfont.Face7x13
+	m := face.Metrics()
+	width := font.MeasureString(face, w.Text).Ceil()
+	img := image.NewNRGBA(image.Rect(0, 0, width+2, m.Height.Ceil()+2))
+
+	// dark outline keeps text readable on bright images
+	d := font.Drawer{Dst: img, Face: face}
+	for _, o := range []image.Point{{0, 1}, {2, 1}, {1, 0}, {1, 2}} {
+		d.Src = image.NewUniform(color.NRGBA{A: 0xa0})
+		d.Dot = fixed.P(o.X, o.Y+m.Ascent.Ceil())
+		d.DrawString(w.Text)
+	}
+	d.Src = image.White
+	d.Dot = fixed.P(1, 1+m.Ascent.Ceil())
+	d.DrawString(w.Text)
+	return img, nil
+}
+
+// validate returns ErrInvalidWatermark unless w has either decodable PNG logo or text, and valid position, opacity and scale
+func (w Watermark) validate() error {
+	if (w.Text == "") == (w.Logo == nil) {
+		return ErrInvalidWatermark
+	}
+	if w.Logo != nil {
+		if _, err := png.DecodeConfig(bytes.NewReader(w.Logo)); err != nil {
+			return ErrInvalidWatermark
+		}
+	}
+	if !w.Position.Valid() || w.Opacity <= 0 || w.Opacity > 1 || w.Scale <= 0 || w.Scale > 1 {
+		return ErrInvalidWatermark
+	}
+	return nil
+}
+
+// apply returns img with watermark drawn on it
+func (w Watermark) apply(img image.Image, interpolation resize.InterpolationFunction) (image.Image, error) {
+	mark, err := w.mark()
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	width := int(float64(b.Dx()) * w.Scale)
+	if width < 1 {
+		width = 1
+	}
+	mark = resize.Resize(uint(width), 0, mark, interpolation)
+	size := mark.Bounds().Size()
+
+	margin := b.Dx() / 50
+	if b.Dy() < b.Dx() {
+		margin = b.Dy() / 50
+	}
+	at := b.Min.Add(image.Pt(margin, margin))
+	switch w.Position {
+	case PositionTopRight:
+		at.X = b.Max.X - margin - size.X
+	case PositionBottomLeft:
+		at.Y = b.Max.Y - margin - size.Y
+	case PositionBottomRight:
+		at = b.Max.Sub(image.Pt(margin, margin)).Sub(size)
+	case PositionCenter:
+		at = b.Min.Add(b.Size().Sub(size).Div(2))
+	}
+
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+	opacity := image.NewUniform(color.Alpha{A: uint8(w.Opacity * 0xff)})
+	draw.DrawMask(dst, image.Rectangle{Min: at, Max: at.Add(size)}, mark, mark.Bounds().Min, opacity, image.Point{}, draw.Over)
+	return dst, nil
+}
+
+// watermarkState is watermark of gallery, and version counting changes of it.
+// Images record version of watermark drawn on them.
+type watermarkState struct {
+	watermark *Watermark
+	version   uint64
+}
+
+func readWatermarkState(g *bolt.Bucket) (watermarkState, error) {
+	var s watermarkState
+	if v := g.Get(watermarkVersionKey); v != nil {
+		s.version = btoi(v)
+	}
+	if v := g.Get(watermarkKey); v != nil {
+		s.watermark = &Watermark{}
+		if err := json.Unmarshal(v, s.watermark); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// watermarked is display image, thumbnail and tile pyramid of image with watermark of version drawn on them.
+// They are kept by image rather than its shared blob, as watermark differs by gallery.
+// Renditions are nil if gallery has no watermark, and tiles are nil unless image has tiles.
+type watermarked struct {
+	image     []byte
+	thumbnail []byte
+	deepZoom  DeepZoom
+	tiles     map[string][]byte
+	version   uint64
+}
+
+// watermark returns renditions of display image img with watermark of state s drawn on it
+func (d *Database) watermark(img image.Image, tiled bool, s watermarkState) (watermarked, error) {
+	w := watermarked{version: s.version}
+	if s.watermark == nil {
+		return w, nil
+	}
+
+	m, err := s.watermark.apply(img, d.cfg.Interpolation)
+	if err != nil {
+		return w, err
+	}
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, m, &jpeg.Options{Quality: d.cfg.Quality})
+	if err != nil {
+		return w, err
+	}
+	w.image = buf.Bytes()
+
+	w.thumbnail, err = d.encodeThumbnail(m)
+	if err != nil {
+		return w, err
+	}
+
+	if tiled {
+		w.deepZoom, w.tiles, err = generateTiles(m, d.cfg.TileSize, d.cfg.Interpolation, d.cfg.Quality)
+	}
+	return w, err
+}
+
+// watermarkShared returns renditions of encoded display image of shared blob with watermark of state s drawn on them
+func (d *Database) watermarkShared(display []byte, tiled bool, s watermarkState) (watermarked, error) {
+	if s.watermark == nil {
+		return watermarked{version: s.version}, nil
+	}
+	img, err := decodeImage(display)
+	if err != nil {
+		return watermarked{}, err
+	}
+	return d.watermark(img, tiled, s)
+}
+
+// putWatermarked replaces watermarked renditions of image bucket i referred by ref with w, and records time they changed.
+// They are held by bucket of image laid out like data bucket.
+// Job is queued if watermark of gallery changed since, to watermark it again.
+func putWatermarked(tx *bolt.Tx, ref []byte, i *bolt.Bucket, w watermarked) error {
+	if i.Bucket(watermarkedBucket) != nil {
+		if err := i.DeleteBucket(watermarkedBucket); err != nil {
+			return err
+		}
+	}
+
+	if w.image != nil {
+		b, err := i.CreateBucket(watermarkedBucket)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(imageKey, w.image); err != nil {
+			return err
+		}
+		if err := b.Put(thumbnailKey, w.thumbnail); err != nil {
+			return err
+		}
+		if w.tiles != nil {
+			if err := putTiles(b, w.deepZoom, w.tiles); err != nil {
+				return err
+			}
+		}
+		if err := putFiles(b); err != nil {
+			return err
+		}
+	}
+
+	err := i.Put(watermarkVersionKey, itob(w.version))
+	if err != nil {
+		return err
+	}
+	err = touchRendered(i)
+	if err != nil {
+		return err
+	}
+
+	galleryId := btoi(ref[:8])
+	s, err := readWatermarkState(tx.Bucket(galleryBucket).Bucket(ref[:8]))
+	if err != nil || s.version == w.version {
+		return err
+	}
+	_, err = queueWatermarkJob(tx, galleryId)
+	return err
+}
+
+// servedData returns bucket holding renditions served for image bucket i of gallery bucket g.
+// Images of gallery with watermark are served renditions watermarked with its current watermark only, never shared ones,
+// so images not watermarked again since watermark changed are not ready.
+func servedData(g, i *bolt.Bucket) (*bolt.Bucket, error) {
+	s, err := readWatermarkState(g)
+	if err != nil {
+		return nil, err
+	}
+	if s.watermark == nil {
+		return imageData(i), nil
+	}
+	w := i.Bucket(watermarkedBucket)
+	v := i.Get(watermarkVersionKey)
+	if w == nil || v == nil || btoi(v) != s.version {
+		return nil, ErrImageNotReady
+	}
+	return w, nil
+}
+
+// servedSize returns size of rendition at key served for image bucket i of gallery bucket g, or zero if none is served
+func servedSize(g, i *bolt.Bucket, key []byte) int {
+	data, err := servedData(g, i)
+	if err != nil {
+		return 0
+	}
+	return len(data.Get(key))
+}
+
+// queueWatermarkJob queues job watermarking images of gallery unless one is queued already.
+// Running job may have read former watermark, so another job is queued.
+func queueWatermarkJob(tx *bolt.Tx, galleryId uint64) (bool, error) {
+	found := false
+	err := tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+		j, err := readJob(v)
+		if err != nil {
+			return err
+		}
+		if j.Kind == JobWatermarkImages && j.GalleryId == galleryId && j.Status == JobQueued {
+			found = true
+		}
+		return nil
+	})
+	if err != nil || found {
+		return false, err
+	}
+
+	_, err = addJob(tx, Job{Kind: JobWatermarkImages, GalleryId: galleryId, Progress: &Progress{}})
+	return err == nil, err
+}
+
+// GetWatermark returns watermark of gallery
+func (d *Database) GetWatermark(galleryId uint64) (Watermark, error) {
+	var w Watermark
+	err := d.db.View(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+		s, err := readWatermarkState(g)
+		if err != nil {
+			return err
+		}
+		if s.watermark == nil {
+			return ErrWatermarkNotFound
+		}
+		w = *s.watermark
+		return nil
+	})
+	return w, err
+}
+
+// SetWatermark sets watermark of gallery, and queues job watermarking its images again.
+// Nil watermark removes watermark.
+func (d *Database) SetWatermark(galleryId uint64, w *Watermark) error {
+	if w != nil {
+		if err := w.validate(); err != nil {
+			return err
+		}
+	}
+
+	queued := false
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		g := tx.Bucket(galleryBucket).Bucket(itob(galleryId))
+		if g == nil {
+			return ErrGalleryNotFound
+		}
+
+		s, err := readWatermarkState(g)
+		if err != nil {
+			return err
+		}
+		if w == nil && s.watermark == nil {
+			return ErrWatermarkNotFound
+		}
+
+		if w == nil {
+			err = g.Delete(watermarkKey)
+		} else {
+			var v []byte
+			v, err = json.Marshal(w)
+			if err == nil {
+				err = g.Put(watermarkKey, v)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		err = g.Put(watermarkVersionKey, itob(s.version+1))
+		if err != nil {
+			return err
+		}
+
+		queued, err = queueWatermarkJob(tx, galleryId)
+		return err
+	})
+
+	if err == nil && queued {
+		d.notifyJobs()
+	}
+	d.publish(err, Event{Entity: EntityGallery, Action: ActionUpdate, GalleryId: galleryId})
+	return err
+}
+
+// watermarkImages watermarks images of gallery of job again, unless watermarked with current watermark.
+// Images being processed are watermarked by their own job.
+func (d *Database) watermarkImages(j Job) error {
+	var refs []imagePath
+	err := d.db.View(func(tx *bolt.Tx) error {
+		var err error
+		refs, err = scopeImages(tx, j.GalleryId, 0)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if j.Progress == nil {
+		j.Progress = &Progress{}
+	}
+	j.Progress.Total = len(refs)
+
+	for _, ref := range refs {
+		var display []byte
+		var tiled bool
+		var s watermarkState
+		err := d.db.View(func(tx *bolt.Tx) error {
+			g, i, err := imageBuckets(tx, ref[0], ref[1], ref[2])
+			if err == ErrGalleryNotFound || err == ErrAlbumNotFound || err == ErrImageNotFound {
+				// deleted meanwhile
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if i.Get(statusKey) != nil {
+				return nil
+			}
+
+			s, err = readWatermarkState(g)
+			if err != nil {
+				return err
+			}
+			if v := i.Get(watermarkVersionKey); v != nil && btoi(v) == s.version || v == nil && s.version == 0 {
+				return nil
+			}
+			data := imageData(i)
+			display = append([]byte(nil), data.Get(imageKey)...)
+			tiled = data.Get(deepZoomKey) != nil
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		var w watermarked
+		var watermarkErr error
+		if len(display) > 0 {
+			w, watermarkErr = d.watermarkShared(display, tiled, s)
+		}
+
+		updated := false
+		err = d.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(jobsBucket)
+			if b.Get(itob(j.Id)) == nil {
+				return ErrJobNotFound
+			}
+
+			switch {
+			case len(display) == 0:
+				j.Progress.Skipped++
+			case watermarkErr != nil:
+				j.Progress.Failed++
+			default:
+				_, i, err := imageBuckets(tx, ref[0], ref[1], ref[2])
+				if err == ErrGalleryNotFound || err == ErrAlbumNotFound || err == ErrImageNotFound {
+					j.Progress.Skipped++
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if err := putWatermarked(tx, documentRef(ref[0], ref[1], ref[2]), i, w); err != nil {
+					return err
+				}
+				j.Progress.Processed++
+				updated = true
+			}
+
+			j.UpdatedAt = time.Now().UTC()
+			return putJob(b, j)
+		})
+		if updated {
+			d.publish(err, Event{Entity: EntityImage, Action: ActionUpdate, GalleryId: ref[0], AlbumId: ref[1], ImageId: ref[2]})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
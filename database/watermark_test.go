@@ -0,0 +1,322 @@
+package database
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/nfnt/resize"
+)
+
+func createTestLogo() []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 32))
+	for idx := range img.Pix {
+		img.Pix[idx] = 0xff
+	}
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		panic(err)
+	}
+	return b.Bytes()
+}
+
+func TestWatermark_Validate(t *testing.T) {
+	logo := createTestLogo()
+
+	for idx, c := range []struct {
+		watermark Watermark
+		valid     bool
+	}{
+		{Watermark{Text: "hello", Position: PositionBottomRight, Opacity: 0.5, Scale: 0.2}, true},
+		{Watermark{Logo: logo, Position: PositionCenter, Opacity: 1, Scale: 1}, true},
+		{Watermark{Position: PositionBottomRight, Opacity: 0.5, Scale: 0.2}, false},
+		{Watermark{Text: "hello", Logo: logo, Position: PositionBottomRight, Opacity: 0.5, Scale: 0.2}, false},
+		{Watermark{Logo: []byte("hello"), Position: PositionBottomRight, Opacity: 0.5, Scale: 0.2}, false},
+		{Watermark{Text: "hello", Position: "middle", Opacity: 0.5, Scale: 0.2}, false},
+		{Watermark{Text: "hello", Position: PositionBottomRight, Opacity: 0, Scale: 0.2}, false},
+		{Watermark{Text: "hello", Position: PositionBottomRight, Opacity: 0.5, Scale: 1.5}, false},
+	} {
+		if err := c.watermark.validate(); (err == nil) != c.valid {
+			t.Errorf("%d: %v", idx, err)
+		}
+	}
+}
+
+func TestWatermark_Apply(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 500, 400))
+	draw.Draw(img, img.Bounds(), image.Black, image.Point{}, draw.Src)
+	w := Watermark{Logo: createTestLogo(), Position: PositionBottomRight, Opacity: 1, Scale: 0.2}
+
+	m, err := w.apply(img, resize.Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100x50 logo with margin of 8 pixels
+	for _, c := range []struct {
+		at    image.Point
+		color color.RGBA
+	}{
+		{image.Pt(450, 350), color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+		{image.Pt(395, 345), color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+		{image.Pt(390, 350), color.RGBA{A: 0xff}},
+		{image.Pt(450, 395), color.RGBA{A: 0xff}},
+		{image.Pt(10, 10), color.RGBA{A: 0xff}},
+	} {
+		if p := color.RGBAModel.Convert(m.At(c.at.X, c.at.Y)); p != c.color {
+			t.Errorf("%v: %v != %v", c.at, p, c.color)
+		}
+	}
+	if img.At(450, 350) != (color.RGBA{A: 0xff}) {
+		t.Error("source image is modified")
+	}
+
+	w = Watermark{Text: "hello", Position: PositionTopLeft, Opacity: 0.5, Scale: 0.5}
+	m, err = w.apply(img, resize.Lanczos3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != img.Bounds() {
+		t.Errorf("%v != %v", m.Bounds(), img.Bounds())
+	}
+}
+
+func TestDatabase_SetWatermark(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	other, _ := db.CreateGallery("other", "test-user")
+	oaid, _ := db.CreateAlbum(other, "test-album", "test-user")
+
+	if _, err := db.GetWatermark(gid); err != ErrWatermarkNotFound {
+		t.Errorf("%v != %v", err, ErrWatermarkNotFound)
+	}
+	if err := db.SetWatermark(gid, &Watermark{Text: "hello"}); err != ErrInvalidWatermark {
+		t.Errorf("%v != %v", err, ErrInvalidWatermark)
+	}
+	if err := db.SetWatermark(9, nil); err != ErrGalleryNotFound {
+		t.Errorf("%v != %v", err, ErrGalleryNotFound)
+	}
+
+	w := Watermark{Text: "hello", Position: PositionBottomRight, Opacity: 0.5, Scale: 0.2}
+	if err := db.SetWatermark(gid, &w); err != nil {
+		t.Fatal(err)
+	}
+	if g, err := db.GetWatermark(gid); err != nil || g.Text != "hello" {
+		t.Errorf("Assertion Failed: %+v %v", g, err)
+	}
+	runJobs(db)
+
+	img := createTestImage()
+	original := img.Bytes()
+	iid, err := db.AddImage(gid, aid, "test-user", bytes.NewReader(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oiid, _ := db.AddImage(other, oaid, "test-user", bytes.NewReader(original))
+
+	watermarked, _, _ := db.GetImage(gid, aid, iid)
+	clean, _, _ := db.GetUnwatermarkedImage(gid, aid, iid)
+	if len(watermarked) == 0 || bytes.Equal(watermarked, clean) {
+		t.Error("display image is not watermarked")
+	}
+	if decodeSize(watermarked) != decodeSize(clean) {
+		t.Errorf("%v != %v", decodeSize(watermarked), decodeSize(clean))
+	}
+
+	// thumbnail is watermarked too
+	thumb, _, _ := db.GetThumbnail(gid, aid, iid)
+	cleanThumb, _, _ := db.GetUnwatermarkedThumbnail(gid, aid, iid)
+	if len(thumb) == 0 || bytes.Equal(thumb, cleanThumb) {
+		t.Error("thumbnail is not watermarked")
+	}
+
+	// identical upload to gallery without watermark shares clean renditions
+	if i, _, _ := db.GetImage(other, oaid, oiid); !bytes.Equal(i, clean) {
+		t.Error("watermark leaks to other gallery")
+	}
+	if i, _, _ := db.GetThumbnail(other, oaid, oiid); !bytes.Equal(i, cleanThumb) {
+		t.Error("watermark leaks to thumbnail of other gallery")
+	}
+
+	// original is never watermarked
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Files.Original.Bytes != len(original) {
+		t.Errorf("%d != %d", i.Files.Original.Bytes, len(original))
+	}
+
+	// files describe watermarked renditions served
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Files.Display.Bytes != len(watermarked) || i.Files.Thumbnail.Bytes != len(thumb) {
+		t.Errorf("Assertion Failed: %+v %+v", i.Files.Display, i.Files.Thumbnail)
+	}
+
+	// removing watermark removes it from stored images
+	if err := db.SetWatermark(gid, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetWatermark(gid, nil); err != ErrWatermarkNotFound {
+		t.Errorf("%v != %v", err, ErrWatermarkNotFound)
+	}
+	if i, _, _ := db.GetImage(gid, aid, iid); !bytes.Equal(i, clean) {
+		t.Error("watermark is not removed")
+	}
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Files.Display.Bytes != len(clean) {
+		t.Errorf("%d != %d", i.Files.Display.Bytes, len(clean))
+	}
+	runJobs(db)
+	if i, _, _ := db.GetImage(gid, aid, iid); !bytes.Equal(i, clean) {
+		t.Error("watermark is not removed")
+	}
+
+	// setting watermark again watermarks stored images, which are not served unwatermarked meanwhile
+	_, removed, _ := db.GetImage(gid, aid, iid)
+	if err := db.SetWatermark(gid, &w); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.GetImage(gid, aid, iid); err != ErrImageNotReady {
+		t.Errorf("%v != %v", err, ErrImageNotReady)
+	}
+	if _, _, err := db.GetThumbnail(gid, aid, iid); err != ErrImageNotReady {
+		t.Errorf("%v != %v", err, ErrImageNotReady)
+	}
+	if i, _ := db.GetImageInfo(gid, aid, iid); i.Files.Display != nil || i.Files.Thumbnail != nil || i.Width == 0 {
+		t.Errorf("Assertion Failed: %+v", i)
+	}
+	if i, _, _ := db.GetUnwatermarkedImage(gid, aid, iid); !bytes.Equal(i, clean) {
+		t.Error("unwatermarked image is changed")
+	}
+	w.Position = PositionTopLeft
+	if err := db.SetWatermark(gid, &w); err != nil {
+		t.Fatal(err)
+	}
+	if jobs, _ := db.GetJobs(JobQueued); len(jobs) != 1 || jobs[0].Kind != JobWatermarkImages || jobs[0].GalleryId != gid {
+		t.Errorf("Assertion Failed: %+v", jobs)
+	}
+	runJobs(db)
+	if i, modified, _ := db.GetImage(gid, aid, iid); bytes.Equal(i, clean) || bytes.Equal(i, watermarked) || !modified.After(removed) {
+		t.Error("display image is not watermarked again:", modified, removed)
+	}
+}
+
+func TestDatabase_WatermarkProcessImageJob(t *testing.T) {
+	db, gid, aid := createAsyncTestDB()
+
+	w := Watermark{Logo: createTestLogo(), Position: PositionCenter, Opacity: 0.8, Scale: 0.5}
+	if err := db.SetWatermark(gid, &w); err != nil {
+		t.Fatal(err)
+	}
+
+	img := createTestImage()
+	iid, _ := db.AddImage(gid, aid, "test-user", &img)
+	runJobs(db)
+
+	watermarked, _, err := db.GetImage(gid, aid, iid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, _, _ := db.GetUnwatermarkedImage(gid, aid, iid)
+	if bytes.Equal(watermarked, clean) {
+		t.Error("display image is not watermarked")
+	}
+
+	// regenerated image keeps watermark
+	if _, err := db.RegenerateImages(gid, 0); err != nil {
+		t.Fatal(err)
+	}
+	runJobs(db)
+	if i, _, _ := db.GetImage(gid, aid, iid); !bytes.Equal(i, watermarked) {
+		t.Error("regenerated image is not watermarked")
+	}
+}
+
+func TestPutWatermarked_Stale(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	img := createTestImage()
+	iid, _ := db.AddImage(gid, aid, "test-user", &img)
+
+	if err := db.SetWatermark(gid, &Watermark{Text: "hello", Position: PositionCenter, Opacity: 1, Scale: 1}); err != nil {
+		t.Fatal(err)
+	}
+	runJobs(db)
+
+	// image watermarked while watermark changed is watermarked again
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		_, i, err := imageBuckets(tx, gid, aid, iid)
+		if err != nil {
+			return err
+		}
+		return putWatermarked(tx, documentRef(gid, aid, iid), i, watermarked{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jobs, _ := db.GetJobs(JobQueued); len(jobs) != 1 || jobs[0].Kind != JobWatermarkImages {
+		t.Fatalf("Assertion Failed: %+v", jobs)
+	}
+
+	clean, _, _ := db.GetUnwatermarkedImage(gid, aid, iid)
+	runJobs(db)
+	if i, _, _ := db.GetImage(gid, aid, iid); bytes.Equal(i, clean) {
+		t.Error("display image is not watermarked again")
+	}
+}
+
+func TestDatabase_WatermarkTiles(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+	other, _ := db.CreateGallery("other", "test-user")
+	oaid, _ := db.CreateAlbum(other, "test-album", "test-user")
+
+	w := Watermark{Logo: createTestLogo(), Position: PositionCenter, Opacity: 1, Scale: 1}
+	if err := db.SetWatermark(gid, &w); err != nil {
+		t.Fatal(err)
+	}
+	runJobs(db)
+
+	img := createTestImage()
+	original := img.Bytes()
+	iid, _ := db.AddImage(gid, aid, "test-user", bytes.NewReader(original))
+	oiid, _ := db.AddImage(other, oaid, "test-user", bytes.NewReader(original))
+
+	if _, err := db.GenerateTiles(gid, aid, iid); err != nil {
+		t.Fatal(err)
+	}
+	watermarked, _, err := db.GetTile(gid, aid, iid, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tiles generated in watermarked gallery are shared clean
+	clean, _, err := db.GetTile(other, oaid, oiid, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(watermarked, clean) {
+		t.Error("watermark leaks to tiles of other gallery")
+	}
+
+	// shared tiles are not served before image is watermarked
+	if err := db.SetWatermark(other, &w); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetDeepZoom(other, oaid, oiid); err != ErrImageNotReady {
+		t.Errorf("%v != %v", err, ErrImageNotReady)
+	}
+	if _, _, err := db.GetTile(other, oaid, oiid, 0, 0, 0); err != ErrImageNotReady {
+		t.Errorf("%v != %v", err, ErrImageNotReady)
+	}
+	runJobs(db)
+	if tile, _, err := db.GetTile(other, oaid, oiid, 0, 0, 0); err != nil || bytes.Equal(tile, clean) {
+		t.Error("tiles are not watermarked:", err)
+	}
+	if z, err := db.GetDeepZoom(other, oaid, oiid); err != nil || z.Width != 1280 {
+		t.Errorf("Assertion Failed: %+v %v", z, err)
+	}
+}
@@ -0,0 +1,340 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+)
+
+var (
+	webhooksBucket   = []byte("webhooks")
+	deliveriesBucket = []byte("deliveries")
+)
+
+// Webhook subscribes URL to events.
+// Events are audit actions such as "image.create", "image.*" for every image action, or "*".
+// Payloads are signed with Secret.
+type Webhook struct {
+	Id        uint64    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Subscribes reports whether webhook subscribes to event
+func (w Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == "*" || e == event {
+			return true
+		}
+		if strings.HasSuffix(e, ".*") && strings.HasPrefix(event, strings.TrimSuffix(e, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w Webhook) valid() bool {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	if len(w.Events) == 0 || w.Secret == "" {
+		return false
+	}
+	for _, e := range w.Events {
+		if e == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Delivery is queued payload of event to webhook.
+// Delivered entries are removed from queue.
+// Entries are kept with Failed set once retries are exhausted.
+type Delivery struct {
+	Id          uint64          `json:"id"`
+	WebhookId   uint64          `json:"webhookId"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"nextAttempt"`
+	LastStatus  int             `json:"lastStatus,omitempty"`
+	LastError   string          `json:"lastError,omitempty"`
+	Failed      bool            `json:"failed,omitempty"`
+}
+
+func readWebhook(v []byte) (Webhook, error) {
+	var w Webhook
+	err := json.Unmarshal(v, &w)
+	return w, err
+}
+
+func putJSON(b *bolt.Bucket, id uint64, v interface{}) error {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(itob(id), j)
+}
+
+// enqueueDeliveries queues payload of entry to every webhook subscribing to its action.
+func enqueueDeliveries(tx *bolt.Tx, entry AuditEntry) (int, error) {
+	webhooks := tx.Bucket(webhooksBucket)
+	deliveries := tx.Bucket(deliveriesBucket)
+
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	err = webhooks.ForEach(func(k, v []byte) error {
+		w, err := readWebhook(v)
+		if err != nil {
+			return err
+		}
+		if !w.Subscribes(entry.Action) {
+			return nil
+		}
+
+		id, err := deliveries.NextSequence()
+		if err != nil {
+			return err
+		}
+		queued++
+		return putJSON(deliveries, id, Delivery{
+			Id:          id,
+			WebhookId:   w.Id,
+			Event:       entry.Action,
+			Payload:     payload,
+			NextAttempt: entry.Timestamp,
+		})
+	})
+	return queued, err
+}
+
+// DeliveryQueued returns channel receiving when delivery is queued
+func (d *Database) DeliveryQueued() <-chan struct{} {
+	return d.queued
+}
+
+func (d *Database) notifyQueued() {
+	select {
+	case d.queued <- struct{}{}:
+	default:
+	}
+}
+
+// CreateWebhook creates webhook and returns its id
+func (d *Database) CreateWebhook(w Webhook) (uint64, error) {
+	if !w.valid() {
+		return 0, ErrInvalidWebhook
+	}
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(webhooksBucket)
+
+		var err error
+		w.Id, err = b.NextSequence()
+		if err != nil {
+			return err
+		}
+		w.CreatedAt = time.Now()
+
+		return putJSON(b, w.Id, w)
+	})
+
+	return w.Id, err
+}
+
+// GetWebhooks returns every webhook
+func (d *Database) GetWebhooks() ([]Webhook, error) {
+	result := make([]Webhook, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhooksBucket).ForEach(func(k, v []byte) error {
+			w, err := readWebhook(v)
+			if err != nil {
+				return err
+			}
+			result = append(result, w)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetWebhook returns webhook
+func (d *Database) GetWebhook(id uint64) (Webhook, error) {
+	var result Webhook
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(webhooksBucket).Get(itob(id))
+		if v == nil {
+			return ErrWebhookNotFound
+		}
+
+		var err error
+		result, err = readWebhook(v)
+		return err
+	})
+
+	return result, err
+}
+
+// DeleteWebhook deletes webhook with its queued deliveries
+func (d *Database) DeleteWebhook(id uint64) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(webhooksBucket)
+		if b.Get(itob(id)) == nil {
+			return ErrWebhookNotFound
+		}
+		if err := b.Delete(itob(id)); err != nil {
+			return err
+		}
+
+		deliveries := tx.Bucket(deliveriesBucket)
+
+		var keys [][]byte
+		err := deliveries.ForEach(func(k, v []byte) error {
+			var dl Delivery
+			if err := json.Unmarshal(v, &dl); err != nil {
+				return err
+			}
+			if dl.WebhookId == id {
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range keys {
+			if err := deliveries.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDeliveries returns queued and failed deliveries of webhook, oldest first
+func (d *Database) GetDeliveries(webhookId uint64) ([]Delivery, error) {
+	result := make([]Delivery, 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(webhooksBucket).Get(itob(webhookId)) == nil {
+			return ErrWebhookNotFound
+		}
+
+		return tx.Bucket(deliveriesBucket).ForEach(func(k, v []byte) error {
+			var dl Delivery
+			if err := json.Unmarshal(v, &dl); err != nil {
+				return err
+			}
+			if dl.WebhookId == webhookId {
+				result = append(result, dl)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DueDeliveries returns up to limit pending deliveries due at now, with their webhooks.
+func (d *Database) DueDeliveries(now time.Time, limit int) ([]Delivery, map[uint64]Webhook, error) {
+	var result []Delivery
+	webhooks := make(map[uint64]Webhook)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		w := tx.Bucket(webhooksBucket)
+
+		c := tx.Bucket(deliveriesBucket).Cursor()
+		for k, v := c.First(); k != nil && len(result) < limit; k, v = c.Next() {
+			var dl Delivery
+			if err := json.Unmarshal(v, &dl); err != nil {
+				return err
+			}
+			if dl.Failed || dl.NextAttempt.After(now) {
+				continue
+			}
+
+			if _, ok := webhooks[dl.WebhookId]; !ok {
+				wv := w.Get(itob(dl.WebhookId))
+				if wv == nil {
+					continue
+				}
+				wh, err := readWebhook(wv)
+				if err != nil {
+					return err
+				}
+				webhooks[dl.WebhookId] = wh
+			}
+
+			result = append(result, dl)
+		}
+		return nil
+	})
+
+	return result, webhooks, err
+}
+
+// NextDeliveryAt returns time pending delivery is due earliest.
+// False is returned if no delivery is pending.
+func (d *Database) NextDeliveryAt() (time.Time, bool, error) {
+	var result time.Time
+	found := false
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deliveriesBucket).ForEach(func(k, v []byte) error {
+			var dl Delivery
+			if err := json.Unmarshal(v, &dl); err != nil {
+				return err
+			}
+			if !dl.Failed && (!found || dl.NextAttempt.Before(result)) {
+				result, found = dl.NextAttempt, true
+			}
+			return nil
+		})
+	})
+
+	return result, found, err
+}
+
+// CompleteDelivery removes delivered entry from queue
+func (d *Database) CompleteDelivery(id uint64) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(deliveriesBucket).Delete(itob(id))
+	})
+}
+
+// UpdateDelivery stores result of failed attempt.
+// Delivery removed meanwhile, such as by deleting its webhook, is left removed.
+func (d *Database) UpdateDelivery(dl Delivery) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(deliveriesBucket)
+		if b.Get(itob(dl.Id)) == nil {
+			return nil
+		}
+		return putJSON(b, dl.Id, dl)
+	})
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWebhook_Subscribes(t *testing.T) {
+	for idx, v := range []struct {
+		events []string
+		event  string
+		result bool
+	}{
+		{[]string{"*"}, "image.create", true},
+		{[]string{"image.create"}, "image.create", true},
+		{[]string{"image.*"}, "image.delete", true},
+		{[]string{"image.*"}, "imagex.delete", false},
+		{[]string{"gallery.*", "album.create"}, "album.delete", false},
+	} {
+		if r := (Webhook{Events: v.events}).Subscribes(v.event); r != v.result {
+			t.Errorf("%d: %v != %v", idx, r, v.result)
+		}
+	}
+}
+
+func TestDatabase_Webhooks(t *testing.T) {
+	db := createTestDB()
+
+	for idx, w := range []Webhook{
+		{URL: "ftp://example.com", Events: []string{"*"}, Secret: "s"},
+		{URL: "http://example.com", Secret: "s"},
+		{URL: "http://example.com", Events: []string{"*"}},
+	} {
+		if _, err := db.CreateWebhook(w); err != ErrInvalidWebhook {
+			t.Errorf("%d: %v != %v", idx, err, ErrInvalidWebhook)
+		}
+	}
+
+	images, err := db.CreateWebhook(Webhook{URL: "http://example.com/images", Events: []string{"image.*"}, Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := db.CreateWebhook(Webhook{URL: "http://example.com/all", Events: []string{"*"}, Secret: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w, err := db.GetWebhooks(); err != nil || len(w) != 2 || w[0].Id != images || w[1].URL != "http://example.com/all" {
+		t.Errorf("Assertion Failed: %+v %v", w, err)
+	}
+
+	for _, action := range []string{"gallery.create", "image.create"} {
+		if _, err := db.AppendAudit(AuditEntry{Action: action, GalleryId: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-db.DeliveryQueued():
+	default:
+		t.Error("queued delivery not notified")
+	}
+
+	if d, err := db.GetDeliveries(images); err != nil || len(d) != 1 || d[0].Event != "image.create" {
+		t.Errorf("Assertion Failed: %+v %v", d, err)
+	}
+
+	due, webhooks, err := db.DueDeliveries(time.Now(), 10)
+	if err != nil || len(due) != 3 || len(webhooks) != 2 {
+		t.Fatalf("Assertion Failed: %+v %v", due, err)
+	}
+	var e AuditEntry
+	if err := json.Unmarshal(due[0].Payload, &e); err != nil || e.Action != "gallery.create" || e.GalleryId != 1 {
+		t.Errorf("Assertion Failed: %+v %v", e, err)
+	}
+
+	if err := db.CompleteDelivery(due[0].Id); err != nil {
+		t.Error(err)
+	}
+	retry := due[1]
+	retry.Attempts++
+	retry.NextAttempt = time.Now().Add(time.Hour)
+	if err := db.UpdateDelivery(retry); err != nil {
+		t.Error(err)
+	}
+
+	if due, _, err := db.DueDeliveries(time.Now(), 10); err != nil || len(due) != 1 || due[0].Id != 3 {
+		t.Errorf("Assertion Failed: %+v %v", due, err)
+	}
+	if next, ok, err := db.NextDeliveryAt(); err != nil || !ok || next.After(time.Now()) {
+		t.Errorf("Assertion Failed: %v %v %v", next, ok, err)
+	}
+
+	if err := db.DeleteWebhook(all); err != nil {
+		t.Error(err)
+	}
+	if _, err := db.GetDeliveries(all); err != ErrWebhookNotFound {
+		t.Errorf("%v != %v", err, ErrWebhookNotFound)
+	}
+	if next, ok, err := db.NextDeliveryAt(); err != nil || !ok || !next.After(time.Now()) {
+		t.Errorf("Assertion Failed: %v %v %v", next, ok, err)
+	}
+}
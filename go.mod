@@ -7,4 +7,6 @@ require (
 	github.com/dfkdream/hugocms v0.2.0
 	github.com/gorilla/mux v1.7.4
 	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+	github.com/rwcarlsen/goexif v0.0.0-20190401172101-9e8deecbddbd
+	golang.org/x/image v0.0.0-20200430140353-33d19683fad8
 )
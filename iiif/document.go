@@ -0,0 +1,155 @@
+package iiif
+
+const PresentationContext = "http://iiif.io/api/presentation/3/context.json"
+
+// Size is size listed in image information
+type Size struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Info is image information document, served as info.json
+type Info struct {
+	Context        string   `json:"@context"`
+	Id             string   `json:"id"`
+	Type           string   `json:"type"`
+	Protocol       string   `json:"protocol"`
+	Profile        string   `json:"profile"`
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	MaxWidth       int      `json:"maxWidth"`
+	MaxHeight      int      `json:"maxHeight"`
+	MaxArea        int      `json:"maxArea"`
+	Sizes          []Size   `json:"sizes,omitempty"`
+	ExtraQualities []string `json:"extraQualities"`
+	ExtraFormats   []string `json:"extraFormats"`
+	ExtraFeatures  []string `json:"extraFeatures"`
+}
+
+// NewInfo returns image information of image service id, for image of width and height.
+// sizes are sizes which are served without scaling.
+func NewInfo(id string, width, height int, sizes ...Size) Info {
+	return Info{
+		Context:        ImageContext,
+		Id:             id,
+		Type:           "ImageService3",
+		Protocol:       Protocol,
+		Profile:        Profile,
+		Width:          width,
+		Height:         height,
+		MaxWidth:       MaxDimension,
+		MaxHeight:      MaxDimension,
+		MaxArea:        MaxArea,
+		Sizes:          sizes,
+		ExtraQualities: []string{QualityGray, QualityBitonal},
+		ExtraFormats:   []string{"gif"},
+		ExtraFeatures:  []string{"baseUriRedirect", "cors", "jsonldMediaType", "mirroring", "regionSquare", "sizeUpscaling"},
+	}
+}
+
+// LanguageMap maps language to values. Key "none" is used for values without language.
+type LanguageMap map[string][]string
+
+// Label returns language map of s without language
+func Label(s string) LanguageMap {
+	return LanguageMap{"none": {s}}
+}
+
+type MetadataEntry struct {
+	Label LanguageMap `json:"label"`
+	Value LanguageMap `json:"value"`
+}
+
+type Service struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Profile string `json:"profile"`
+}
+
+// Resource is content resource, such as image
+type Resource struct {
+	Id      string    `json:"id"`
+	Type    string    `json:"type"`
+	Format  string    `json:"format"`
+	Width   int       `json:"width,omitempty"`
+	Height  int       `json:"height,omitempty"`
+	Service []Service `json:"service,omitempty"`
+}
+
+type Annotation struct {
+	Id         string   `json:"id"`
+	Type       string   `json:"type"`
+	Motivation string   `json:"motivation"`
+	Body       Resource `json:"body"`
+	Target     string   `json:"target"`
+}
+
+type AnnotationPage struct {
+	Id    string       `json:"id"`
+	Type  string       `json:"type"`
+	Items []Annotation `json:"items"`
+}
+
+type Canvas struct {
+	Id        string           `json:"id"`
+	Type      string           `json:"type"`
+	Label     LanguageMap      `json:"label,omitempty"`
+	Width     int              `json:"width"`
+	Height    int              `json:"height"`
+	Thumbnail []Resource       `json:"thumbnail,omitempty"`
+	Items     []AnnotationPage `json:"items"`
+}
+
+// Manifest is Presentation API manifest
+type Manifest struct {
+	Context   string          `json:"@context"`
+	Id        string          `json:"id"`
+	Type      string          `json:"type"`
+	Label     LanguageMap     `json:"label"`
+	Metadata  []MetadataEntry `json:"metadata,omitempty"`
+	Thumbnail []Resource      `json:"thumbnail,omitempty"`
+	Items     []Canvas        `json:"items"`
+}
+
+// NewManifest returns empty manifest of id
+func NewManifest(id, label string) Manifest {
+	return Manifest{
+		Context: PresentationContext,
+		Id:      id,
+		Type:    "Manifest",
+		Label:   Label(label),
+		Items:   make([]Canvas, 0),
+	}
+}
+
+// NewCanvas returns canvas of id painted with image served by image service
+func NewCanvas(id, label, service string, width, height int) Canvas {
+	c := Canvas{
+		Id:     id,
+		Type:   "Canvas",
+		Width:  width,
+		Height: height,
+		Items: []AnnotationPage{{
+			Id:   id + "/page",
+			Type: "AnnotationPage",
+			Items: []Annotation{{
+				Id:         id + "/page/image",
+				Type:       "Annotation",
+				Motivation: "painting",
+				Body: Resource{
+					Id:      service + "/full/max/0/default.jpg",
+					Type:    "Image",
+					Format:  ContentType("jpg"),
+					Width:   width,
+					Height:  height,
+					Service: []Service{{Id: service, Type: "ImageService3", Profile: Profile}},
+				},
+				Target: id,
+			}},
+		}},
+	}
+	if label != "" {
+		c.Label = Label(label)
+	}
+	return c
+}
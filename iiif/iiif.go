@@ -0,0 +1,410 @@
+// Package iiif implements IIIF Image API 3.0 requests over decoded images,
+// and documents of IIIF Image and Presentation API.
+package iiif
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nfnt/resize"
+)
+
+const (
+	// MaxDimension is largest width or height of image returned
+	MaxDimension = 10000
+	// MaxArea is largest number of pixels of image returned, bounding memory of rendering it
+	MaxArea = 4096 * 4096
+
+	Protocol     = "http://iiif.io/api/image"
+	ImageContext = "http://iiif.io/api/image/3/context.json"
+	Profile      = "level2"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid IIIF image request")
+	ErrNotImplemented = errors.New("IIIF image request not implemented")
+)
+
+const (
+	QualityDefault = "default"
+	QualityColor   = "color"
+	QualityGray    = "gray"
+	QualityBitonal = "bitonal"
+)
+
+// formats maps supported formats to MIME type
+var formats = map[string]string{
+	"jpg": "image/jpeg",
+	"png": "image/png",
+	"gif": "image/gif",
+}
+
+// unsupportedFormats are formats of IIIF specification which are not implemented
+var unsupportedFormats = map[string]bool{"tif": true, "jp2": true, "pdf": true, "webp": true}
+
+// ContentType returns MIME type of format
+func ContentType(format string) string {
+	return formats[format]
+}
+
+// Request is parsed image request, resolved against size of image
+type Request struct {
+	// Region of source image, in pixels
+	Region image.Rectangle
+	// Width and Height of region after scaling, before rotation
+	Width, Height int
+	// Rotation is clockwise rotation in degrees, multiple of 90
+	Rotation int
+	// Mirror flips image horizontally before rotation
+	Mirror  bool
+	Quality string
+	Format  string
+}
+
+// Parse parses path segments of image request, for image of width and height.
+// Region is never scaled up beyond size of image.
+func Parse(region, size, rotation, quality, format string, width, height int) (Request, error) {
+	var r Request
+	var err error
+
+	r.Region, err = parseRegion(region, width, height)
+	if err != nil {
+		return r, err
+	}
+	r.Width, r.Height, err = parseSize(size, r.Region.Dx(), r.Region.Dy(), width, height)
+	if err != nil {
+		return r, err
+	}
+	r.Rotation, r.Mirror, err = parseRotation(rotation)
+	if err != nil {
+		return r, err
+	}
+
+	switch quality {
+	case QualityDefault, QualityColor, QualityGray, QualityBitonal:
+		r.Quality = quality
+	default:
+		return r, ErrInvalidRequest
+	}
+
+	if _, ok := formats[format]; !ok {
+		if unsupportedFormats[format] {
+			return r, ErrNotImplemented
+		}
+		return r, ErrInvalidRequest
+	}
+	r.Format = format
+
+	return r, nil
+}
+
+// parseFloats parses comma separated non-negative numbers
+func parseFloats(s string, n int) ([]float64, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, false
+	}
+	result := make([]float64, n)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, false
+		}
+		result[i] = f
+	}
+	return result, true
+}
+
+func parseRegion(s string, width, height int) (image.Rectangle, error) {
+	bounds := image.Rect(0, 0, width, height)
+
+	switch {
+	case s == "full":
+		return bounds, nil
+	case s == "square":
+		side := width
+		if height < side {
+			side = height
+		}
+		x, y := (width-side)/2, (height-side)/2
+		return image.Rect(x, y, x+side, y+side), nil
+	}
+
+	pct := strings.HasPrefix(s, "pct:")
+	v, ok := parseFloats(strings.TrimPrefix(s, "pct:"), 4)
+	if !ok || v[2] == 0 || v[3] == 0 {
+		return image.Rectangle{}, ErrInvalidRequest
+	}
+	if pct {
+		v[0], v[2] = v[0]*float64(width)/100, v[2]*float64(width)/100
+		v[1], v[3] = v[1]*float64(height)/100, v[3]*float64(height)/100
+	}
+
+	r := image.Rect(int(v[0]), int(v[1]), int(math.Ceil(v[0]+v[2])), int(math.Ceil(v[1]+v[3]))).Intersect(bounds)
+	if r.Empty() {
+		return image.Rectangle{}, ErrInvalidRequest
+	}
+	return r, nil
+}
+
+// parseSize parses size of region of width and height, cut from image of sourceWidth and sourceHeight
+func parseSize(s string, width, height, sourceWidth, sourceHeight int) (int, int, error) {
+	upscale := strings.HasPrefix(s, "^")
+	s = strings.TrimPrefix(s, "^")
+
+	var w, h int
+	switch {
+	case s == "max" || s == "full":
+		w, h = width, height
+		if w > MaxDimension || h > MaxDimension {
+			// fit in MaxDimension keeping aspect ratio
+			w, h = confine(width, height, MaxDimension, MaxDimension)
+		}
+		w, h = confineArea(w, h)
+		return w, h, nil
+	case strings.HasPrefix(s, "pct:"):
+		v, ok := parseFloats(strings.TrimPrefix(s, "pct:"), 1)
+		if !ok || v[0] == 0 {
+			return 0, 0, ErrInvalidRequest
+		}
+		w, h = int(math.Round(float64(width)*v[0]/100)), int(math.Round(float64(height)*v[0]/100))
+	case strings.HasPrefix(s, "!"):
+		v, ok := parseInts(strings.TrimPrefix(s, "!"))
+		if !ok || v[0] == 0 || v[1] == 0 {
+			return 0, 0, ErrInvalidRequest
+		}
+		// upscaling fits in size of image
+		w, h = confine(width, height, minInt(v[0], sourceWidth), minInt(v[1], sourceHeight))
+		if !upscale && (w > width || h > height) {
+			w, h = width, height
+		}
+	default:
+		v, ok := parseInts(s)
+		if !ok {
+			return 0, 0, ErrInvalidRequest
+		}
+		w, h = v[0], v[1]
+		switch {
+		case w == 0 && h == 0:
+			return 0, 0, ErrInvalidRequest
+		case w == 0:
+			w = int(math.Round(float64(width) * float64(h) / float64(height)))
+		case h == 0:
+			h = int(math.Round(float64(height) * float64(w) / float64(width)))
+		}
+	}
+
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	if !upscale && (w > width || h > height) {
+		return 0, 0, ErrInvalidRequest
+	}
+	if w > sourceWidth || h > sourceHeight {
+		return 0, 0, ErrInvalidRequest
+	}
+	if w > MaxDimension || h > MaxDimension || w*h > MaxArea {
+		return 0, 0, ErrInvalidRequest
+	}
+	return w, h, nil
+}
+
+// parseInts parses "w,h", where either one may be empty and is returned as 0
+func parseInts(s string) ([]int, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 || parts[0] == "" && parts[1] == "" {
+		return nil, false
+	}
+	result := make([]int, 2)
+	for i, p := range parts {
+		if p == "" {
+			continue
+		}
+		v, err := strconv.Atoi(p)
+		if err != nil || v <= 0 {
+			return nil, false
+		}
+		result[i] = v
+	}
+	return result, true
+}
+
+// confine returns largest size within maxWidth and maxHeight with aspect ratio of width and height
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// confineArea scales width and height down keeping aspect ratio, to fit in MaxArea
+func confineArea(width, height int) (int, int) {
+	if width*height <= MaxArea {
+		return width, height
+	}
+	scale := math.Sqrt(float64(MaxArea) / float64(width*height))
+	w, h := int(float64(width)*scale), int(float64(height)*scale)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+func confine(width, height, maxWidth, maxHeight int) (int, int) {
+	scale := math.Min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+	w, h := int(math.Round(float64(width)*scale)), int(math.Round(float64(height)*scale))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+func parseRotation(s string) (int, bool, error) {
+	mirror := strings.HasPrefix(s, "!")
+	v, err := strconv.ParseFloat(strings.TrimPrefix(s, "!"), 64)
+	if err != nil || v < 0 || v > 360 {
+		return 0, false, ErrInvalidRequest
+	}
+	if math.Mod(v, 90) != 0 {
+		return 0, false, ErrNotImplemented
+	}
+	return int(v) % 360, mirror, nil
+}
+
+// Size returns width and height of result image
+func (r Request) Size() (int, int) {
+	if r.Rotation%180 != 0 {
+		return r.Height, r.Width
+	}
+	return r.Width, r.Height
+}
+
+// Apply returns img transformed by request
+func (r Request) Apply(img image.Image, interpolation resize.InterpolationFunction) image.Image {
+	var result image.Image = crop(img, r.Region)
+
+	if r.Width != r.Region.Dx() || r.Height != r.Region.Dy() {
+		result = resize.Resize(uint(r.Width), uint(r.Height), result, interpolation)
+	}
+	if r.Mirror || r.Rotation != 0 {
+		result = transform(result, r.Mirror, r.Rotation)
+	}
+
+	switch r.Quality {
+	case QualityGray:
+		result = gray(result)
+	case QualityBitonal:
+		result = bitonal(result)
+	}
+	return result
+}
+
+func crop(img image.Image, region image.Rectangle) image.Image {
+	region = region.Add(img.Bounds().Min)
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(region)
+	}
+
+	result := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(result, result.Bounds(), img, region.Min, draw.Src)
+	return result
+}
+
+// transform mirrors img horizontally if mirror is set, then rotates it clockwise by rotation degrees
+func transform(img image.Image, mirror bool, rotation int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if rotation%180 != 0 {
+		dw, dh = h, w
+	}
+	result := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx := x
+			if mirror {
+				sx = w - 1 - x
+			}
+			c := img.At(b.Min.X+sx, b.Min.Y+y)
+
+			switch rotation {
+			case 90:
+				result.Set(h-1-y, x, c)
+			case 180:
+				result.Set(w-1-x, h-1-y, c)
+			case 270:
+				result.Set(y, w-1-x, c)
+			default:
+				result.Set(x, y, c)
+			}
+		}
+	}
+	return result
+}
+
+func gray(img image.Image) *image.Gray {
+	b := img.Bounds()
+	result := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(result, result.Bounds(), img, b.Min, draw.Src)
+	return result
+}
+
+func bitonal(img image.Image) *image.Gray {
+	result := gray(img)
+	for i, v := range result.Pix {
+		if v < 128 {
+			result.Pix[i] = 0
+		} else {
+			result.Pix[i] = 255
+		}
+	}
+	return result
+}
+
+// Encode writes img in format. Quality is used for jpg.
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		if g, ok := img.(*image.Gray); ok {
+			return gif.Encode(w, grayPaletted(g), nil)
+		}
+		return gif.Encode(w, img, nil)
+	default:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	}
+}
+
+// grayPaletted converts g to paletted image, so that gif keeps every gray level
+func grayPaletted(g *image.Gray) *image.Paletted {
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = color.Gray{Y: uint8(i)}
+	}
+	result := image.NewPaletted(g.Rect, palette)
+	copy(result.Pix, g.Pix)
+	return result
+}
@@ -0,0 +1,103 @@
+package iiif
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/nfnt/resize"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		region, size, rotation, quality, format string
+
+		expected Request
+		err      error
+	}{
+		{"full", "max", "0", "default", "jpg", Request{image.Rect(0, 0, 400, 200), 400, 200, 0, false, "default", "jpg"}, nil},
+		{"square", "100,", "!90", "gray", "png", Request{image.Rect(100, 0, 300, 200), 100, 100, 90, true, "gray", "png"}, nil},
+		{"10,20,100,50", ",25", "180", "color", "gif", Request{image.Rect(10, 20, 110, 70), 50, 25, 180, false, "color", "gif"}, nil},
+		{"pct:50,50,50,50", "pct:50", "270", "bitonal", "jpg", Request{image.Rect(200, 100, 400, 200), 100, 50, 270, false, "bitonal", "jpg"}, nil},
+		{"350,150,100,100", "max", "0", "default", "jpg", Request{image.Rect(350, 150, 400, 200), 50, 50, 0, false, "default", "jpg"}, nil},
+		{"full", "!100,100", "360", "default", "jpg", Request{image.Rect(0, 0, 400, 200), 100, 50, 0, false, "default", "jpg"}, nil},
+		{"0,0,200,100", "^!400,400", "0", "default", "jpg", Request{image.Rect(0, 0, 200, 100), 400, 200, 0, false, "default", "jpg"}, nil},
+		{"0,0,200,100", "^400,", "0", "default", "jpg", Request{image.Rect(0, 0, 200, 100), 400, 200, 0, false, "default", "jpg"}, nil},
+		{"full", "^!800,800", "0", "default", "jpg", Request{image.Rect(0, 0, 400, 200), 400, 200, 0, false, "default", "jpg"}, nil},
+		{"full", "^800,", "0", "default", "jpg", Request{}, ErrInvalidRequest},
+		{"full", "800,", "0", "default", "jpg", Request{}, ErrInvalidRequest},
+		{"full", "^20000,", "0", "default", "jpg", Request{}, ErrInvalidRequest},
+		{"full", ",", "0", "default", "jpg", Request{}, ErrInvalidRequest},
+		{"500,0,10,10", "max", "0", "default", "jpg", Request{}, ErrInvalidRequest},
+		{"0,0,0,10", "max", "0", "default", "jpg", Request{}, ErrInvalidRequest},
+		{"bogus", "max", "0", "default", "jpg", Request{}, ErrInvalidRequest},
+		{"full", "max", "45", "default", "jpg", Request{}, ErrNotImplemented},
+		{"full", "max", "-90", "default", "jpg", Request{}, ErrInvalidRequest},
+		{"full", "max", "0", "sepia", "jpg", Request{}, ErrInvalidRequest},
+		{"full", "max", "0", "default", "webp", Request{}, ErrNotImplemented},
+		{"full", "max", "0", "default", "bmp", Request{}, ErrInvalidRequest},
+	}
+
+	for idx, test := range tests {
+		r, err := Parse(test.region, test.size, test.rotation, test.quality, test.format, 400, 200)
+		if err != test.err {
+			t.Errorf("Assertion Failed: %d: %v != %v", idx, err, test.err)
+			continue
+		}
+		if err == nil && r != test.expected {
+			t.Errorf("Assertion Failed: %d: %+v != %+v", idx, r, test.expected)
+		}
+	}
+}
+
+func TestParse_MaxArea(t *testing.T) {
+	r, err := Parse("full", "max", "0", "default", "jpg", 8000, 6000)
+	if err != nil || r.Width*r.Height > MaxArea || r.Width != 4729 || r.Height != 3547 {
+		t.Errorf("Assertion Failed: %+v %v", r, err)
+	}
+	if _, err := Parse("full", "8000,", "0", "default", "jpg", 8000, 6000); err != ErrInvalidRequest {
+		t.Errorf("Assertion Failed: %v != %v", err, ErrInvalidRequest)
+	}
+}
+
+func TestRequest_Apply(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	src.Set(0, 0, color.White)
+
+	r, err := Parse("full", "max", "90", "default", "png", 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := r.Apply(src, resize.NearestNeighbor)
+	if w, h := r.Size(); img.Bounds().Dx() != w || img.Bounds().Dy() != h || w != 2 || h != 4 {
+		t.Fatalf("Assertion Failed: %v %d %d", img.Bounds(), w, h)
+	}
+	// top left corner is rotated to top right
+	if c := color.GrayModel.Convert(img.At(1, 0)).(color.Gray); c.Y != 255 {
+		t.Errorf("Assertion Failed: %v", c)
+	}
+
+	r, _ = Parse("full", "max", "!0", "bitonal", "gif", 4, 2)
+	img = r.Apply(src, resize.NearestNeighbor)
+	if c := img.At(3, 0).(color.Gray); c.Y != 255 {
+		t.Errorf("Assertion Failed: %v", c)
+	}
+	if c := img.At(0, 0).(color.Gray); c.Y != 0 {
+		t.Errorf("Assertion Failed: %v", c)
+	}
+
+	r, _ = Parse("2,0,2,2", "1,1", "0", "default", "jpg", 4, 2)
+	if img = r.Apply(src, resize.NearestNeighbor); img.Bounds().Dx() != 1 || img.Bounds().Dy() != 1 {
+		t.Errorf("Assertion Failed: %v", img.Bounds())
+	}
+
+	var b bytes.Buffer
+	if err := Encode(&b, gray(src), "gif", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gif.Decode(&b); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,6 +13,8 @@ import (
 	"github.com/boltdb/bolt"
 	"github.com/dfkdream/gallery-plugin/config"
 	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/dfkdream/gallery-plugin/webhook"
+	"github.com/dfkdream/gallery-plugin/worker"
 	"github.com/dfkdream/hugocms/plugin"
 )
 
@@ -23,6 +26,15 @@ var (
 )
 
 func main() {
+	cfg := config.Get()
+
+	if len(os.Args) > 1 && os.Args[1] == "regenerate" {
+		if err := regenerate(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	p := plugin.New(plugin.Info{
 		Name:        "Gallery",
 		Author:      "HugoCMS",
@@ -31,8 +43,6 @@ func main() {
 		IconClass:   "fas fa-images",
 	}, "gallery")
 
-	cfg := config.Get()
-
 	fmt.Println(cfg)
 
 	p.HandleAdminPage("/", "Manage gallery", http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
@@ -69,7 +79,12 @@ func main() {
 		log.Fatal(err)
 	}
 
-	a := api.New(db)
+	go webhook.New(db, nil, cfg.WebhookMaxAttempts).Run(context.Background())
+
+	// uploads are not queued without processing workers, but regeneration jobs are
+	go worker.New(db, cfg.ProcessingWorkers).Run(context.Background())
+
+	a := api.New(db, cfg)
 
 	a.SetupHandlers(p.APIRouter())
 
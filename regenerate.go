@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+const progressInterval = time.Second
+
+// regenerate regenerates thumbnails and renditions of images from stored originals, reporting progress.
+// Other queued jobs are run as well. Interrupted regeneration resumes on next run, or by server.
+//
+//	gallery-plugin regenerate [-gallery id] [-album id]
+func regenerate(cfg *config.Config, args []string) error {
+	f := flag.NewFlagSet("regenerate", flag.ExitOnError)
+	galleryId := f.Uint64("gallery", 0, "id of gallery to regenerate, or every gallery if zero")
+	albumId := f.Uint64("album", 0, "id of album to regenerate, or every album of gallery if zero")
+	_ = f.Parse(args)
+
+	b, err := bolt.Open(cfg.BoltPath, os.FileMode(0644), &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("open %s: %v (stop server first)", cfg.BoltPath, err)
+	}
+	defer b.Close()
+
+	db, err := database.New(b, cfg)
+	if err != nil {
+		return err
+	}
+
+	// resume job of interrupted run
+	if err := db.RequeueRunningJobs(); err != nil {
+		return err
+	}
+
+	j, err := db.RegenerateImages(*galleryId, *albumId)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		t := time.NewTicker(progressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if p, err := db.GetJob(j.Id); err == nil {
+					printProgress(p)
+				}
+			}
+		}
+	}()
+
+	for {
+		next, ok, err := db.ClaimJob()
+		if err != nil || !ok {
+			return err
+		}
+
+		runErr := db.RunJob(next)
+		if runErr != nil {
+			log.Printf("job %d: %s failed: %v", next.Id, next.Kind, runErr)
+		}
+		if next.Id == j.Id {
+			if p, err := db.GetJob(j.Id); err == nil {
+				printProgress(p)
+			}
+		}
+
+		if err := db.CompleteJob(next.Id, runErr); err != nil {
+			return err
+		}
+	}
+}
+
+func printProgress(j database.Job) {
+	if p := j.Progress; p != nil {
+		fmt.Printf("regenerated %d/%d images (%d skipped, %d failed)\n", p.Processed+p.Skipped+p.Failed, p.Total, p.Skipped, p.Failed)
+	}
+}
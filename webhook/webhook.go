@@ -0,0 +1,185 @@
+// Package webhook delivers queued events of database to subscribed webhooks.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+const (
+	// HeaderEvent holds audit action of payload
+	HeaderEvent = "X-Gallery-Event"
+	// HeaderDelivery holds id of delivery, which stays the same across retries
+	HeaderDelivery = "X-Gallery-Delivery"
+	// HeaderSignature holds "sha256=" and hex HMAC-SHA256 of body keyed by webhook secret
+	HeaderSignature = "X-Gallery-Signature"
+)
+
+const (
+	batchSize    = 32
+	idleInterval = time.Minute
+)
+
+// Sign returns value of HeaderSignature for body
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	_, _ = m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// Verify reports whether signature is valid signature of body
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Dispatcher delivers queued deliveries, retrying failed ones with exponential backoff.
+type Dispatcher struct {
+	db     *database.Database
+	client *http.Client
+
+	// MaxAttempts is number of attempts before delivery is marked failed
+	MaxAttempts int
+	// Backoff is delay before first retry, doubled on each following retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	now func() time.Time
+}
+
+// New returns dispatcher of db. http.Client with 10 seconds timeout is used if client is nil.
+func New(db *database.Database, client *http.Client, maxAttempts int) *Dispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Dispatcher{
+		db:          db,
+		client:      client,
+		MaxAttempts: maxAttempts,
+		Backoff:     10 * time.Second,
+		MaxBackoff:  time.Hour,
+		now:         time.Now,
+	}
+}
+
+// backoff returns delay after attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	b := d.Backoff
+	for i := 1; i < attempts && b < d.MaxBackoff; i++ {
+		b *= 2
+	}
+	if b > d.MaxBackoff {
+		b = d.MaxBackoff
+	}
+	return b
+}
+
+// Run delivers due deliveries until ctx is done.
+// It wakes up when delivery is queued, or when retry is due.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		err := d.DeliverDue(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+
+		wait := idleInterval
+		if next, ok, err := d.db.NextDeliveryAt(); err != nil {
+			log.Println(err)
+		} else if ok {
+			if w := next.Sub(d.now()); w < wait {
+				wait = w
+			}
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-d.db.DeliveryQueued():
+			t.Stop()
+		case <-t.C:
+		}
+	}
+}
+
+// DeliverDue attempts every delivery due now once.
+func (d *Dispatcher) DeliverDue(ctx context.Context) error {
+	for {
+		due, webhooks, err := d.db.DueDeliveries(d.now(), batchSize)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		for _, dl := range due {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			status, err := d.deliver(ctx, webhooks[dl.WebhookId], dl)
+			if err == nil {
+				err = d.db.CompleteDelivery(dl.Id)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			dl.Attempts++
+			dl.LastStatus = status
+			dl.LastError = err.Error()
+			dl.NextAttempt = d.now().Add(d.backoff(dl.Attempts))
+			dl.Failed = dl.Attempts >= d.MaxAttempts
+			if dl.Failed {
+				log.Printf("webhook %d: delivery %d failed after %d attempts: %v", dl.WebhookId, dl.Id, dl.Attempts, err)
+			}
+
+			err = d.db.UpdateDelivery(dl)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// deliver posts payload of delivery to webhook, and returns response status
+func (d *Dispatcher) deliver(ctx context.Context, w database.Webhook, dl database.Delivery) (int, error) {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gallery-plugin-webhook")
+	req.Header.Set(HeaderEvent, dl.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(dl.Id, 10))
+	req.Header.Set(HeaderSignature, Sign(w.Secret, dl.Payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/nfnt/resize"
+)
+
+func createTestDB() *database.Database {
+	dpath, err := ioutil.TempDir("", "gallery-plugin-test-")
+	if err != nil {
+		panic(err)
+	}
+	b, err := bolt.Open(path.Join(dpath, "gallery.db"), os.FileMode(0644), nil)
+	if err != nil {
+		panic(err)
+	}
+	db, err := database.New(b, &config.Config{Interpolation: resize.Lanczos3, Quality: 80})
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+type receiver struct {
+	sync.Mutex
+	fail     int
+	bodies   [][]byte
+	headers  []http.Header
+	received chan struct{}
+}
+
+func (r *receiver) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+
+	b, _ := ioutil.ReadAll(req.Body)
+	r.bodies = append(r.bodies, b)
+	r.headers = append(r.headers, req.Header)
+
+	if r.fail > 0 {
+		r.fail--
+		res.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case r.received <- struct{}{}:
+	default:
+	}
+}
+
+func TestSign(t *testing.T) {
+	s := Sign("secret", []byte(`{}`))
+	if !Verify("secret", []byte(`{}`), s) {
+		t.Error("signature not verified:", s)
+	}
+	if Verify("other", []byte(`{}`), s) || Verify("secret", []byte(`{ }`), s) {
+		t.Error("invalid signature verified")
+	}
+}
+
+func TestDispatcher_DeliverDue(t *testing.T) {
+	r := &receiver{fail: 1, received: make(chan struct{}, 1)}
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	db := createTestDB()
+	wid, err := db.CreateWebhook(database.Webhook{URL: s.URL, Events: []string{"image.*"}, Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.AppendAudit(database.AuditEntry{Action: "image.create", GalleryId: 1, AlbumId: 1, ImageId: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	d := New(db, nil, 3)
+	d.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if err := d.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	dl, _ := db.GetDeliveries(wid)
+	if len(dl) != 1 || dl[0].Attempts != 1 || dl[0].LastStatus != 503 || !dl[0].NextAttempt.Equal(now.Add(d.Backoff)) {
+		t.Fatalf("Assertion Failed: %+v", dl)
+	}
+
+	// not due yet
+	if err := d.DeliverDue(ctx); err != nil || len(r.bodies) != 1 {
+		t.Error("delivered before backoff:", len(r.bodies), err)
+	}
+
+	now = now.Add(d.Backoff)
+	if err := d.DeliverDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if dl, _ := db.GetDeliveries(wid); len(dl) != 0 {
+		t.Errorf("delivery not completed: %+v", dl)
+	}
+
+	if len(r.bodies) != 2 || string(r.bodies[0]) != string(r.bodies[1]) {
+		t.Fatalf("Assertion Failed: %q", r.bodies)
+	}
+	h := r.headers[1]
+	if h.Get(HeaderEvent) != "image.create" || h.Get(HeaderDelivery) != r.headers[0].Get(HeaderDelivery) || !Verify("secret", r.bodies[1], h.Get(HeaderSignature)) {
+		t.Errorf("Assertion Failed: %v", h)
+	}
+}
+
+func TestDispatcher_MaxAttempts(t *testing.T) {
+	r := &receiver{fail: 10, received: make(chan struct{}, 1)}
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	db := createTestDB()
+	wid, _ := db.CreateWebhook(database.Webhook{URL: s.URL, Events: []string{"*"}, Secret: "secret"})
+	_, _ = db.AppendAudit(database.AuditEntry{Action: "gallery.delete", GalleryId: 1})
+
+	d := New(db, nil, 2)
+	d.Backoff = 0
+
+	if err := d.DeliverDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	dl, _ := db.GetDeliveries(wid)
+	if len(dl) != 1 || !dl[0].Failed || dl[0].Attempts != 2 || len(r.bodies) != 2 {
+		t.Errorf("Assertion Failed: %+v", dl)
+	}
+}
+
+func TestDispatcher_Run(t *testing.T) {
+	r := &receiver{received: make(chan struct{}, 1)}
+	s := httptest.NewServer(r)
+	defer s.Close()
+
+	db := createTestDB()
+	_, _ = db.CreateWebhook(database.Webhook{URL: s.URL, Events: []string{"*"}, Secret: "secret"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go New(db, nil, 3).Run(ctx)
+
+	_, _ = db.AppendAudit(database.AuditEntry{Action: "album.create", GalleryId: 1, AlbumId: 1})
+
+	select {
+	case <-r.received:
+	case <-time.After(5 * time.Second):
+		t.Error("queued delivery not delivered")
+	}
+}
+
+func TestDispatcher_backoff(t *testing.T) {
+	d := New(nil, nil, 8)
+	for idx, v := range []struct {
+		attempts int
+		result   time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, time.Hour},
+	} {
+		if r := d.backoff(v.attempts); r != v.result {
+			t.Errorf("%d: %v != %v", idx, r, v.result)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package worker runs queued jobs of database with bounded concurrency.
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dfkdream/gallery-plugin/database"
+)
+
+const idleInterval = time.Minute
+
+// Pool runs queued jobs on fixed number of workers
+type Pool struct {
+	db      *database.Database
+	workers int
+}
+
+// New returns pool of db running at most workers jobs at once
+func New(db *database.Database, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{db: db, workers: workers}
+}
+
+// Run runs queued jobs until ctx is done, and waits for running jobs to finish.
+// Jobs left running by previous process are queued again first.
+func (p *Pool) Run(ctx context.Context) {
+	if err := p.db.RequeueRunningJobs(); err != nil {
+		log.Println(err)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	slots := make(chan struct{}, p.workers)
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		j, ok, err := p.db.ClaimJob()
+		if err != nil {
+			log.Println(err)
+		}
+		if !ok {
+			<-slots
+
+			t := time.NewTimer(idleInterval)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-p.db.JobsNotify():
+				t.Stop()
+			case <-t.C:
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			p.run(j)
+		}()
+	}
+}
+
+// RunQueued runs queued jobs one by one until queue has no queued job, and returns number of jobs run.
+func (p *Pool) RunQueued() (int, error) {
+	n := 0
+	for {
+		j, ok, err := p.db.ClaimJob()
+		if err != nil || !ok {
+			return n, err
+		}
+		p.run(j)
+		n++
+	}
+}
+
+func (p *Pool) run(j database.Job) {
+	err := p.db.RunJob(j)
+	if err != nil {
+		log.Printf("job %d: %s of image %d/%d/%d failed: %v", j.Id, j.Kind, j.GalleryId, j.AlbumId, j.ImageId, err)
+	}
+
+	if err := p.db.CompleteJob(j.Id, err); err != nil {
+		log.Println(err)
+	}
+}
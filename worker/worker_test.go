@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/dfkdream/gallery-plugin/config"
+	"github.com/dfkdream/gallery-plugin/database"
+	"github.com/nfnt/resize"
+)
+
+func createTestDB() *database.Database {
+	dpath, err := ioutil.TempDir("", "gallery-plugin-test-")
+	if err != nil {
+		panic(err)
+	}
+	b, err := bolt.Open(path.Join(dpath, "gallery.db"), os.FileMode(0644), nil)
+	if err != nil {
+		panic(err)
+	}
+	db, err := database.New(b, &config.Config{Interpolation: resize.Lanczos3, Quality: 80, ProcessingWorkers: 2})
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func createTestImage() *bytes.Buffer {
+	return createSizedTestImage(640, 480)
+}
+
+func createSizedTestImage(w, h int) *bytes.Buffer {
+	var b bytes.Buffer
+	err := jpeg.Encode(&b, image.NewRGBA(image.Rect(0, 0, w, h)), nil)
+	if err != nil {
+		panic(err)
+	}
+	return &b
+}
+
+func TestPool_Run(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+
+	events, cancelEvents := db.Subscribe(gid)
+	defer cancelEvents()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		New(db, 2).Run(ctx)
+		close(done)
+	}()
+
+	// distinct uploads, so that none of them shares renditions of already processed one
+	ids := make(map[uint64]bool)
+	for i := 0; i < 3; i++ {
+		iid, err := db.AddImage(gid, aid, "test-user", createSizedTestImage(640+i, 480))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[iid] = true
+	}
+
+	timeout := time.After(10 * time.Second)
+	for len(ids) > 0 {
+		select {
+		case e := <-events:
+			if e.Action == database.ActionUpdate {
+				delete(ids, e.ImageId)
+			}
+		case <-timeout:
+			t.Fatal("images not processed:", ids)
+		}
+	}
+
+	cancel()
+	<-done
+
+	images, _ := db.GetImages(gid, aid)
+	for _, i := range images {
+		if i.Status != "" {
+			t.Errorf("Assertion Failed: %+v", i)
+		}
+	}
+	if jobs, _ := db.GetJobs(""); len(jobs) != 0 {
+		t.Errorf("Assertion Failed: %+v", jobs)
+	}
+}
+
+func TestPool_RunQueued(t *testing.T) {
+	db := createTestDB()
+	gid, _ := db.CreateGallery("test-gallery", "test-user")
+	aid, _ := db.CreateAlbum(gid, "test-album", "test-user")
+
+	_, _ = db.AddImage(gid, aid, "test-user", createTestImage())
+	b := createTestImage().Bytes()
+	failing, _ := db.AddImage(gid, aid, "test-user", bytes.NewReader(b[:len(b)/2]))
+
+	n, err := New(db, 1).RunQueued()
+	if err != nil || n != 2 {
+		t.Fatal("Assertion Failed:", n, err)
+	}
+
+	failed, _ := db.GetJobs(database.JobFailed)
+	if len(failed) != 1 || failed[0].ImageId != failing {
+		t.Errorf("Assertion Failed: %+v", failed)
+	}
+	if n, _ := New(db, 1).RunQueued(); n != 0 {
+		t.Errorf("%d != %d", n, 0)
+	}
+}